@@ -0,0 +1,302 @@
+package fsm
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// analysisCache 状态转移表分析结果的缓存，首次使用时计算，转移规则变化时失效
+type analysisCache struct {
+	mu           sync.Mutex
+	sinks        atomic.Pointer[[]bool] // sinks[state]为true表示该状态是终态，见IsTerminal
+	reachability [][]bool
+}
+
+// invalidate 丢弃所有缓存的分析结果，修改转移规则后必须调用
+func (c *analysisCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sinks.Store(nil)
+	c.reachability = nil
+}
+
+// TerminalSet 获取所有终态的集合
+// 终态的定义与IsTerminal相同，集合中只包含出现在转移规则中的状态，表中未被使用的状态不计入。
+// 与IsTerminal共享同一份缓存，每次调用都返回新的集合，调用方可以随意修改
+func (t *ArrayTransitionTable) TerminalSet() map[State]bool {
+	sinks := t.sinkStates()
+	terminal := make(map[State]bool)
+	for state, used := range t.usedStates() {
+		if used && sinks[state] {
+			terminal[State(state)] = true
+		}
+	}
+	return terminal
+}
+
+// sinkStates 获取缓存的各状态是否为终态，首次使用时计算，调用方不能修改返回值
+// 命中缓存时只有一次原子读取，不需要加锁
+func (t *ArrayTransitionTable) sinkStates() []bool {
+	if sinks := t.cache.sinks.Load(); sinks != nil {
+		return *sinks
+	}
+	t.cache.mu.Lock()
+	defer t.cache.mu.Unlock()
+	if sinks := t.cache.sinks.Load(); sinks != nil {
+		return *sinks
+	}
+	sinks := make([]bool, t.maxStates)
+	for s := range t.maxStates {
+		sinks[s] = t.rowEmpty(s)
+		for p := t.Parent(State(s)); sinks[s] && p != StateInInit; p = t.parents[p] {
+			sinks[s] = t.rowEmpty(int32(p))
+		}
+	}
+	t.cache.sinks.Store(&sinks)
+	return sinks
+}
+
+// TerminalTable 可选接口：能够判断状态是否为终态的状态转移表
+type TerminalTable interface {
+	IsTerminal(state State) bool
+}
+
+// IsTerminal 判断state是否为终态，即在任何事件下都没有转移规则（包括接受并忽略的规则）
+// 有父状态时也考虑继承自祖先的规则，与GetNextState一致；超出表范围的状态返回false。
+// 所有状态的判断结果在第一次调用时一并计算并缓存，之后每次调用都是不加锁的O(1)
+func (t *ArrayTransitionTable) IsTerminal(state State) bool {
+	if state < 0 || int32(state) >= t.maxStates {
+		return false
+	}
+	return t.sinkStates()[state]
+}
+
+// rowEmpty 判断状态在所有事件下都没有转移规则
+func (t *ArrayTransitionTable) rowEmpty(state int32) bool {
+	for _, to := range t.table[state*t.maxEvents : (state+1)*t.maxEvents] {
+		if to != StateInInit {
+			return false
+		}
+	}
+	return true
+}
+
+// RangeTransitions 按状态、事件升序依次将每条转移规则交给fn，fn返回false时停止
+// 逐条生成转移规则而不分配中间切片，适合遍历规模巨大的状态转移表；小表可以直接使用Transitions
+func (t *ArrayTransitionTable) RangeTransitions(fn func(Transition) bool) {
+	for i, to := range t.table {
+		if to == StateInInit {
+			continue
+		}
+		tr := Transition{
+			From:     State(int32(i) / t.maxEvents),
+			Event:    Event(int32(i) % t.maxEvents),
+			To:       to,
+			Consume:  flagAt(t.consumed, int32(i)),
+			Internal: flagAt(t.internal, int32(i)),
+		}
+		if !fn(tr) {
+			return
+		}
+	}
+}
+
+// Transitions 按状态、事件升序获取所有转移规则
+// 返回值一次性分配，转移规则数量巨大时应使用RangeTransitions
+func (t *ArrayTransitionTable) Transitions() []Transition {
+	var transitions []Transition
+	t.RangeTransitions(func(tr Transition) bool {
+		transitions = append(transitions, tr)
+		return true
+	})
+	return transitions
+}
+
+// EventsFrom 获取指定状态下所有定义了转移规则的事件，按升序排列
+// 接受并忽略的事件也包含在内；守卫在运行时才会判断，这里不考虑
+func (t *ArrayTransitionTable) EventsFrom(state State) []Event {
+	if state < 0 || int32(state) >= t.maxStates {
+		return nil
+	}
+	return t.inheritedEvents(state, eventsInRow(t.table[int32(state)*t.maxEvents:(int32(state)+1)*t.maxEvents]))
+}
+
+// eventsInRow 获取状态转移表的一行中所有有效单元格对应的事件
+func eventsInRow(row []State) []Event {
+	var events []Event
+	for event, to := range row {
+		if to != StateInInit {
+			events = append(events, Event(event))
+		}
+	}
+	return events
+}
+
+// StatesWithEvent 获取所有定义了指定事件出边的状态，按升序排列
+// 有父状态时也包括从祖先继承了该事件规则的子状态，与EventsFrom一致
+func (t *ArrayTransitionTable) StatesWithEvent(event Event) []State {
+	if event < 0 || int32(event) >= t.maxEvents {
+		return nil
+	}
+	var states []State
+	for state := range t.maxStates {
+		if t.nextOf(State(state), event) != StateInInit {
+			states = append(states, State(state))
+		}
+	}
+	return states
+}
+
+// nextOf 获取state在event下的目标状态，与GetNextState一样沿父状态链解析，没有规则时返回StateInInit
+// 继承自祖先的接受并忽略或内部转移规则不离开state，目标状态为state自身
+func (t *ArrayTransitionTable) nextOf(state State, event Event) State {
+	index, ok := t.resolve(state, event)
+	if !ok {
+		return StateInInit
+	}
+	next := t.table[index]
+	if next != StateInInit && (flagAt(t.consumed, index) || flagAt(t.internal, index)) {
+		return state
+	}
+	return next
+}
+
+// ReachabilityMatrix 计算所有状态两两之间的可达关系
+// m[i][j]为true表示从状态i经过零次或多次转移可以到达状态j（每个状态都可达自身）。
+// 通过对每个状态做一次BFS计算，复杂度为O(V*(V+E))，最坏O(V^3)，用于离线分析，不要在热路径上调用。
+// 有父状态时也考虑继承自祖先的规则，与ReachableStates相同。
+// 结果在首次调用时计算并缓存，返回的是缓存的副本
+func (t *ArrayTransitionTable) ReachabilityMatrix() [][]bool {
+	t.cache.mu.Lock()
+	defer t.cache.mu.Unlock()
+	if t.cache.reachability == nil {
+		t.cache.reachability = t.computeReachability()
+	}
+	n := len(t.cache.reachability)
+	cells := make([]bool, n*n)
+	matrix := make([][]bool, n)
+	for i, row := range t.cache.reachability {
+		matrix[i] = cells[i*n : (i+1)*n]
+		copy(matrix[i], row)
+	}
+	return matrix
+}
+
+func (t *ArrayTransitionTable) computeReachability() [][]bool {
+	n := int(t.maxStates)
+	cells := make([]bool, n*n)
+	matrix := make([][]bool, n)
+	for i := range matrix {
+		matrix[i] = cells[i*n : (i+1)*n]
+		t.markReachable(matrix[i], State(i))
+	}
+	return matrix
+}
+
+// ReachableStates 获取从from出发经过零次或多次转移可以到达的所有状态，from自身总是包含在内
+// 通过一次BFS计算，复杂度为O(V+E)；有父状态时也考虑继承自祖先的规则，不考虑守卫。from超出表的范围时返回nil
+func (t *ArrayTransitionTable) ReachableStates(from State) map[State]bool {
+	if from < 0 || int32(from) >= t.maxStates {
+		return nil
+	}
+	reached := t.reachableFrom(from)
+	states := make(map[State]bool)
+	for state, ok := range reached {
+		if ok {
+			states[State(state)] = true
+		}
+	}
+	return states
+}
+
+// UnreachableStates 找出从from出发无法到达的状态，按升序排列
+// 用于发现定义了出边、却没有任何转移能够进入的状态；没有出现在任何转移规则中的状态编号会被忽略
+func (t *ArrayTransitionTable) UnreachableStates(from State) []State {
+	if from < 0 || int32(from) >= t.maxStates {
+		return nil
+	}
+	reached := t.reachableFrom(from)
+	var unreachable []State
+	for state, used := range t.usedStates() {
+		if used && !reached[state] {
+			unreachable = append(unreachable, State(state))
+		}
+	}
+	return unreachable
+}
+
+// reachableFrom 从from做一次BFS，标记所有可达的状态
+func (t *ArrayTransitionTable) reachableFrom(from State) []bool {
+	reached := make([]bool, t.maxStates)
+	t.markReachable(reached, from)
+	return reached
+}
+
+// markReachable 从from做一次BFS，在reached中标记所有可达的状态，各状态的规则按nextOf沿父状态链解析
+func (t *ArrayTransitionTable) markReachable(reached []bool, from State) {
+	reached[from] = true
+	queue := []State{from}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for event := range t.maxEvents {
+			if to := t.nextOf(state, Event(event)); to != StateInInit && !reached[to] {
+				reached[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+}
+
+// CanAlwaysReach 找出无法到达target的状态，按升序排列
+// 用于检查长期运行的状态机是否存在意外的死胡同，例如"每个状态最终都能回到Idle"。
+// allowedTerminals中的状态是有意设计的终态，不计入结果；没有出现在任何转移规则中的状态编号也会被忽略。
+// 有父状态时也考虑继承自祖先的规则。先建立反向图，再在反向图上从target做一次BFS计算，复杂度为O(V*E)
+func (t *ArrayTransitionTable) CanAlwaysReach(target State, allowedTerminals ...State) (bad []State) {
+	if target < 0 || int32(target) >= t.maxStates {
+		return nil
+	}
+	used := t.usedStates()
+	predecessors := make([][]State, t.maxStates)
+	for from := range t.maxStates {
+		for event := range t.maxEvents {
+			if to := t.nextOf(State(from), Event(event)); to != StateInInit {
+				predecessors[to] = append(predecessors[to], State(from))
+			}
+		}
+	}
+	canReach := make([]bool, t.maxStates)
+	canReach[target] = true
+	queue := []State{target}
+	for len(queue) > 0 {
+		to := queue[0]
+		queue = queue[1:]
+		for _, from := range predecessors[to] {
+			if !canReach[from] {
+				canReach[from] = true
+				queue = append(queue, from)
+			}
+		}
+	}
+	for state := range t.maxStates {
+		if used[state] && !canReach[state] && !slices.Contains(allowedTerminals, State(state)) {
+			bad = append(bad, State(state))
+		}
+	}
+	return bad
+}
+
+// usedStates 标记出现在任意转移规则中（作为起点或终点）的状态，从祖先继承了规则的子状态也作为起点计入
+func (t *ArrayTransitionTable) usedStates() []bool {
+	used := make([]bool, t.maxStates)
+	for state := range t.maxStates {
+		for event := range t.maxEvents {
+			if to := t.nextOf(State(state), Event(event)); to != StateInInit {
+				used[state] = true
+				used[to] = true
+			}
+		}
+	}
+	return used
+}
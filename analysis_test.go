@@ -0,0 +1,268 @@
+package fsm_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试按事件查询状态
+func TestStatesWithEvent(t *testing.T) {
+	table := createTestTransitionTable()
+
+	if got := table.StatesWithEvent(EventStop); !slices.Equal(got, []fsm.State{StateRunning, StatePaused}) {
+		t.Errorf("Unexpected states for EventStop: %v", got)
+	}
+	if got := table.StatesWithEvent(EventStart); !slices.Equal(got, []fsm.State{StateIdle}) {
+		t.Errorf("Unexpected states for EventStart: %v", got)
+	}
+	if got := table.StatesWithEvent(fsm.Event(100)); got != nil {
+		t.Errorf("Expected nil for unknown event, got %v", got)
+	}
+}
+
+// 测试枚举转移规则
+func TestRangeTransitions(t *testing.T) {
+	table := createTestTransitionTable()
+
+	all := table.Transitions()
+	if len(all) != 5 || all[0] != (fsm.Transition{From: StateIdle, Event: EventStart, To: StateRunning}) {
+		t.Errorf("Unexpected transitions: %v", all)
+	}
+
+	var streamed []fsm.Transition
+	table.RangeTransitions(func(tr fsm.Transition) bool {
+		streamed = append(streamed, tr)
+		return len(streamed) < 2
+	})
+	if !slices.Equal(streamed, all[:2]) {
+		t.Errorf("Expected stream to stop after 2 transitions, got %v", streamed)
+	}
+}
+
+// 测试可达矩阵
+func TestReachabilityMatrix(t *testing.T) {
+	m := createTestTransitionTable().ReachabilityMatrix()
+	if len(m) != 4 {
+		t.Fatalf("Expected 4x4 matrix, got %d rows", len(m))
+	}
+	want := [][]bool{
+		{true, true, true, true},    // Idle可以到达所有状态
+		{false, true, true, true},   // Running无法回到Idle
+		{false, true, true, true},   // Paused无法回到Idle
+		{false, false, false, true}, // Stopped只能到达自身
+	}
+	for i := range want {
+		if !slices.Equal(m[i], want[i]) {
+			t.Errorf("Row %d: got %v, want %v", i, m[i], want[i])
+		}
+	}
+}
+
+// 测试能否回到指定状态的检查
+func TestCanAlwaysReach(t *testing.T) {
+	table := createTestTransitionTable()
+
+	// Running、Paused、Stopped都回不到Idle
+	if bad := table.CanAlwaysReach(StateIdle); !slices.Equal(bad, []fsm.State{StateRunning, StatePaused, StateStopped}) {
+		t.Errorf("Unexpected bad states: %v", bad)
+	}
+	// 所有状态都能到达Stopped
+	if bad := table.CanAlwaysReach(StateStopped); len(bad) != 0 {
+		t.Errorf("Expected every state to reach Stopped, got %v", bad)
+	}
+	// Stopped是有意设计的终态
+	if bad := table.CanAlwaysReach(StateRunning, StateStopped); len(bad) != 0 {
+		t.Errorf("Expected only allowed terminals to miss Running, got %v", bad)
+	}
+}
+
+// 测试终态集合
+func TestTerminalSet(t *testing.T) {
+	table := createTestTransitionTable()
+	terminal := table.TerminalSet()
+	if len(terminal) != 1 || !terminal[StateStopped] {
+		t.Errorf("Expected only Stopped to be terminal, got %v", terminal)
+	}
+
+	// 返回的是新的集合，修改不影响缓存
+	terminal[StateIdle] = true
+	if table.TerminalSet()[StateIdle] || table.IsTerminal(StateIdle) {
+		t.Error("Expected cached terminal set to be isolated from callers")
+	}
+}
+
+// 测试终态判断
+func TestIsTerminal(t *testing.T) {
+	table := createTestTransitionTable()
+	compiled := table.Compile()
+	for state := StateIdle; state <= StateStopped; state++ {
+		want := state == StateStopped
+		if table.IsTerminal(state) != want || compiled.IsTerminal(state) != want {
+			t.Errorf("Expected IsTerminal(%v) = %v", state, want)
+		}
+	}
+	if table.IsTerminal(-1) || table.IsTerminal(100) || compiled.IsTerminal(100) {
+		t.Error("Expected out-of-range states not to be terminal")
+	}
+
+	f := fsm.NewFSM(0, StateRunning, table)
+	if f.IsTerminal() {
+		t.Error("Expected Running not to be terminal")
+	}
+	f.Trigger(EventStop)
+	if !f.IsTerminal() {
+		t.Error("Expected Stopped to be terminal")
+	}
+
+	// 修改规则后缓存失效
+	if err := table.AddTransition(StateStopped, EventStart, StateIdle); err != nil {
+		t.Fatal(err)
+	}
+	if f.IsTerminal() {
+		t.Error("Expected Stopped to no longer be terminal")
+	}
+
+	// 继承了父状态规则的子状态不是终态
+	withParent := createTestTransitionTable()
+	if err := withParent.SetParent(StateStopped, StateRunning); err != nil {
+		t.Fatal(err)
+	}
+	if withParent.IsTerminal(StateStopped) || withParent.Compile().IsTerminal(StateStopped) {
+		t.Error("Expected Stopped to inherit Running's transitions")
+	}
+	// TerminalSet与IsTerminal使用同一个定义
+	if terminal := withParent.TerminalSet(); len(terminal) != 0 {
+		t.Errorf("Expected TerminalSet to agree with IsTerminal, got %v", terminal)
+	}
+
+	// 不支持TerminalTable的状态转移表按可用事件判断
+	mapped := fsm.NewFSM(0, StateStopped, fsm.NewMapTransitionTable([]fsm.Transition{{From: StateIdle, Event: EventStart, To: StateStopped}}))
+	if !mapped.IsTerminal() {
+		t.Error("Expected map table state without events to be terminal")
+	}
+}
+
+// 测试运行时增删转移规则
+func TestAddRemoveTransition(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateStopped, table)
+	if !table.TerminalSet()[StateStopped] {
+		t.Fatal("Expected StateStopped to be terminal")
+	}
+
+	if err := table.AddTransition(StateStopped, EventStart, StateIdle); err != nil {
+		t.Fatal(err)
+	}
+	// 修改后缓存的分析结果失效
+	if table.TerminalSet()[StateStopped] {
+		t.Error("Expected StateStopped to no longer be terminal")
+	}
+	if !f.Trigger(EventStart) || f.CurrentState() != StateIdle {
+		t.Errorf("Expected added transition to fire, got state %d", f.CurrentState())
+	}
+
+	if !table.RemoveTransition(StateIdle, EventStart) || table.RemoveTransition(StateIdle, EventStart) {
+		t.Error("Expected RemoveTransition to report removal exactly once")
+	}
+	if f.Trigger(EventStart) {
+		t.Error("Expected removed transition to be rejected")
+	}
+
+	if err := table.AddTransition(StateIdle, fsm.Event(100), StateRunning); !errors.Is(err, fsm.ErrOutOfRange) {
+		t.Errorf("Expected ErrOutOfRange for out-of-range event, got %v", err)
+	}
+	if err := table.AddTransition(StateIdle, EventStart, fsm.State(100)); !errors.Is(err, fsm.ErrOutOfRange) {
+		t.Errorf("Expected ErrOutOfRange for out-of-range target, got %v", err)
+	}
+	if err := table.AddTransition(StateIdle, EventStart, fsm.StateInInit); !errors.Is(err, fsm.ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState, got %v", err)
+	}
+}
+
+// 测试修改接受并忽略和内部转移的单元格可以与Trigger并发进行，需配合-race运行
+func TestAddRemoveTransitionConcurrentFlags(t *testing.T) {
+	table := fsm.NewArrayTransitionTable([]fsm.Transition{
+		{From: StateIdle, Event: EventStart, To: StateRunning},
+		{From: StateRunning, Event: EventStart, Consume: true},
+		{From: StateRunning, Event: EventPause, Internal: true},
+	})
+	f := fsm.NewFSM(0, StateRunning, table)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 200 {
+			table.AddTransition(StateRunning, EventStart, StateRunning)
+			table.AddTransition(StateRunning, EventPause, StateRunning)
+			table.RemoveTransition(StateRunning, EventStart)
+			table.RemoveTransition(StateRunning, EventPause)
+		}
+	}()
+	for range 200 {
+		f.Trigger(EventStart)
+		f.Trigger(EventPause)
+	}
+	wg.Wait()
+	// 所有规则都以Running为目标，无论读到哪个版本状态都不变
+	if f.CurrentState() != StateRunning {
+		t.Errorf("Expected to stay in StateRunning, got %d", f.CurrentState())
+	}
+}
+
+// 测试从初始状态出发的可达性分析
+func TestReachableStates(t *testing.T) {
+	table := createTestTransitionTable()
+	reachable := table.ReachableStates(StateIdle)
+	if len(reachable) != 4 {
+		t.Errorf("Expected all 4 states reachable from Idle, got %v", reachable)
+	}
+	if got := table.UnreachableStates(StateIdle); len(got) != 0 {
+		t.Errorf("Expected no unreachable states, got %v", got)
+	}
+
+	// 从Paused出发回不到Idle
+	reachable = table.ReachableStates(StatePaused)
+	if reachable[StateIdle] || !reachable[StatePaused] || !reachable[StateRunning] || !reachable[StateStopped] {
+		t.Errorf("Unexpected reachable set from Paused %v", reachable)
+	}
+	if got := table.UnreachableStates(StatePaused); !slices.Equal(got, []fsm.State{StateIdle}) {
+		t.Errorf("Expected Idle to be unreachable from Paused, got %v", got)
+	}
+
+	// 定义了出边但没有入边的状态，以及没有使用的状态编号
+	orphan := fsm.NewArrayTransitionTable([]fsm.Transition{
+		{From: 0, Event: 0, To: 1},
+		{From: 3, Event: 0, To: 1},
+		{From: 5, Event: 1, To: 2},
+	})
+	if got := orphan.UnreachableStates(0); !slices.Equal(got, []fsm.State{2, 3, 5}) {
+		t.Errorf("Expected [2 3 5] unreachable, got %v", got)
+	}
+	if orphan.ReachableStates(-1) != nil || orphan.UnreachableStates(100) != nil {
+		t.Error("Expected nil for out-of-range start states")
+	}
+}
+
+// 测试分析考虑子状态从父状态继承的规则
+func TestAnalysisWithHierarchy(t *testing.T) {
+	// uiLoading和uiReady只能通过父状态uiRunning的规则到达uiStopped
+	table := createHierarchyTable(t, new([]string))
+
+	if got := table.StatesWithEvent(uiStop); !slices.Equal(got, []fsm.State{uiRunning, uiLoading, uiReady}) {
+		t.Errorf("Expected children to inherit uiStop, got %v", got)
+	}
+	if !table.ReachableStates(uiLoading)[uiStopped] {
+		t.Error("Expected uiStopped to be reachable from uiLoading")
+	}
+	if !table.ReachabilityMatrix()[uiReady][uiStopped] {
+		t.Error("Expected reachability matrix to follow the parent rule")
+	}
+	if got := table.CanAlwaysReach(uiStopped); len(got) != 0 {
+		t.Errorf("Expected every state to reach uiStopped, got %v", got)
+	}
+}
@@ -0,0 +1,41 @@
+package fsm
+
+// Args 触发事件时传入的参数，提供按下标取值并做类型断言的方法
+// Handler收到的args ...any可以直接转换后使用，例如fsm.Args(args).String(0)；
+// TransitionContext.Args本身就是Args。下标越界或类型不符时返回零值和false，而不是panic。
+// 断言是严格的，不做数值类型之间的转换：传入int64时Int返回false，应使用ArgAs[int64]
+type Args []any
+
+// Get 获取第i个参数，下标越界时返回nil
+func (a Args) Get(i int) any {
+	if i < 0 || i >= len(a) {
+		return nil
+	}
+	return a[i]
+}
+
+// String 获取第i个string类型的参数
+func (a Args) String(i int) (string, bool) {
+	return ArgAs[string](a, i)
+}
+
+// Int 获取第i个int类型的参数
+func (a Args) Int(i int) (int, bool) {
+	return ArgAs[int](a, i)
+}
+
+// Bool 获取第i个bool类型的参数
+func (a Args) Bool(i int) (bool, bool) {
+	return ArgAs[bool](a, i)
+}
+
+// Float64 获取第i个float64类型的参数
+func (a Args) Float64(i int) (float64, bool) {
+	return ArgAs[float64](a, i)
+}
+
+// ArgAs 获取第i个类型为T的参数，适合业务自定义的参数类型，T为接口时判断参数是否实现了该接口
+func ArgAs[T any](args []any, i int) (T, bool) {
+	v, ok := Args(args).Get(i).(T)
+	return v, ok
+}
@@ -0,0 +1,58 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试按下标取出参数并做类型断言
+func TestArgs(t *testing.T) {
+	args := fsm.Args{"job-1", 3, true, 1.5, errors.New("boom")}
+	if s, ok := args.String(0); !ok || s != "job-1" {
+		t.Errorf("String(0) = %q, %v", s, ok)
+	}
+	if n, ok := args.Int(1); !ok || n != 3 {
+		t.Errorf("Int(1) = %d, %v", n, ok)
+	}
+	if b, ok := args.Bool(2); !ok || !b {
+		t.Errorf("Bool(2) = %v, %v", b, ok)
+	}
+	if v, ok := args.Float64(3); !ok || v != 1.5 {
+		t.Errorf("Float64(3) = %v, %v", v, ok)
+	}
+	if err, ok := fsm.ArgAs[error](args, 4); !ok || err.Error() != "boom" {
+		t.Errorf("ArgAs[error](4) = %v, %v", err, ok)
+	}
+	// 类型不符或下标越界时返回零值和false
+	if s, ok := args.String(1); ok || s != "" {
+		t.Errorf("Expected String(1) to fail, got %q", s)
+	}
+	if n, ok := args.Int(-1); ok || n != 0 {
+		t.Errorf("Expected Int(-1) to fail, got %d", n)
+	}
+	if args.Get(len(args)) != nil {
+		t.Error("Expected nil for out-of-range Get")
+	}
+	if _, ok := fsm.Args(nil).String(0); ok {
+		t.Error("Expected nil Args to have no values")
+	}
+}
+
+// 测试回调中通过Args取出触发时传入的参数
+func TestArgsInCallbacks(t *testing.T) {
+	table := createTestTransitionTable()
+	var jobID string
+	var attempt int
+	table.RegisterCallback(fsm.BeforeEvent, StateIdle, EventStart, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		jobID, _ = fsm.Args(args).String(0)
+	})
+	table.RegisterContextCallback(fsm.AfterEvent, StateIdle, EventStart, func(tc *fsm.TransitionContext) {
+		attempt, _ = tc.Args.Int(1)
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+	if !f.Trigger(EventStart, "job-1", 2) || jobID != "job-1" || attempt != 2 {
+		t.Errorf("Unexpected args in callbacks: %q, %d", jobID, attempt)
+	}
+}
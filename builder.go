@@ -0,0 +1,139 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TableBuilder 以链式调用构建数组状态转移表
+//
+//	table, err := fsm.NewTableBuilder().
+//		From(StateIdle).On(EventStart).To(StateRunning).
+//		From(StateRunning).On(EventStop).To(StateStopped).
+//		OnEnter(StateRunning, onRunning).
+//		Build()
+//
+// 所有错误都推迟到Build时一并返回：重复定义的(from, event)、无效的状态以及无法注册的回调
+type TableBuilder struct {
+	transitions []Transition
+	callbacks   []builderCallback
+}
+
+// builderCallback 等待Build时注册的回调
+type builderCallback struct {
+	cbType  CallbackType
+	state   State
+	event   Event
+	handler Handler
+}
+
+// FromBuilder 已指定源状态、等待指定事件的转移规则
+type FromBuilder struct {
+	b    *TableBuilder
+	from State
+}
+
+// EventBuilder 已指定源状态和事件、等待指定目标状态的转移规则
+type EventBuilder struct {
+	b     *TableBuilder
+	from  State
+	event Event
+}
+
+// NewTableBuilder 创建状态转移表构建器
+func NewTableBuilder() *TableBuilder {
+	return &TableBuilder{}
+}
+
+// From 开始定义一条从指定状态出发的转移规则，可以使用AnyState
+func (b *TableBuilder) From(state State) FromBuilder {
+	return FromBuilder{b: b, from: state}
+}
+
+// On 指定触发转移的事件
+func (f FromBuilder) On(event Event) EventBuilder {
+	return EventBuilder{b: f.b, from: f.from, event: event}
+}
+
+// To 指定目标状态，完成这条转移规则
+func (e EventBuilder) To(state State) *TableBuilder {
+	e.b.transitions = append(e.b.transitions, Transition{From: e.from, Event: e.event, To: state})
+	return e.b
+}
+
+// Consume 在源状态下接受并忽略该事件，完成这条转移规则，语义见Transition.Consume
+func (e EventBuilder) Consume() *TableBuilder {
+	e.b.transitions = append(e.b.transitions, Transition{From: e.from, Event: e.event, To: e.from, Consume: true})
+	return e.b
+}
+
+// Internal 在源状态下以内部转移处理该事件，完成这条转移规则，语义见Transition.Internal
+func (e EventBuilder) Internal() *TableBuilder {
+	e.b.transitions = append(e.b.transitions, Transition{From: e.from, Event: e.event, To: e.from, Internal: true})
+	return e.b
+}
+
+// OnEnter 注册进入状态时的回调
+func (b *TableBuilder) OnEnter(state State, handler Handler) *TableBuilder {
+	return b.callback(EnterState, state, 0, handler)
+}
+
+// OnLeave 注册离开状态时的回调
+func (b *TableBuilder) OnLeave(state State, handler Handler) *TableBuilder {
+	return b.callback(LeaveState, state, 0, handler)
+}
+
+// Before 注册状态state下事件event执行前的回调
+func (b *TableBuilder) Before(state State, event Event, handler Handler) *TableBuilder {
+	return b.callback(BeforeEvent, state, event, handler)
+}
+
+// After 注册状态state下事件event执行后的回调
+func (b *TableBuilder) After(state State, event Event, handler Handler) *TableBuilder {
+	return b.callback(AfterEvent, state, event, handler)
+}
+
+// callback 记录等待Build时注册的回调
+func (b *TableBuilder) callback(cbType CallbackType, state State, event Event, handler Handler) *TableBuilder {
+	b.callbacks = append(b.callbacks, builderCallback{cbType: cbType, state: state, event: event, handler: handler})
+	return b
+}
+
+// Build 校验并创建状态转移表，然后注册所有回调
+// 同一(from, event)被定义多次时，即使目标状态相同也视为冲突，返回ErrConflictingTransition；
+// 状态无效或表过大时返回的错误与NewArrayTransitionTableChecked相同；回调的状态或事件超出表的范围时返回ErrInvalidCallback
+func (b *TableBuilder) Build() (*ArrayTransitionTable, error) {
+	if err := b.validateDuplicates(); err != nil {
+		return nil, err
+	}
+	table, err := NewArrayTransitionTableChecked(b.transitions)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, cb := range b.callbacks {
+		if err := table.RegisterCallbackChecked(cb.cbType, cb.state, cb.event, cb.handler); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// validateDuplicates 检查重复定义的(from, event)，报告每一处重复及其首次定义
+func (b *TableBuilder) validateDuplicates() error {
+	var errs []error
+	first := make(map[transitionKey]int, len(b.transitions))
+	for i, trans := range b.transitions {
+		key := transitionKey{from: trans.From, event: trans.Event}
+		if j, ok := first[key]; ok {
+			errs = append(errs, fmt.Errorf("%w: state %v event %v defined by #%d and #%d",
+				ErrConflictingTransition, trans.From, trans.Event, j, i))
+			continue
+		}
+		first[key] = i
+	}
+	return errors.Join(errs...)
+}
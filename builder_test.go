@@ -0,0 +1,67 @@
+package fsm_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试链式构建状态转移表
+func TestTableBuilder(t *testing.T) {
+	var calls []string
+	record := func(name string) fsm.Handler {
+		return func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) { calls = append(calls, name) }
+	}
+
+	table, err := fsm.NewTableBuilder().
+		From(StateIdle).On(EventStart).To(StateRunning).
+		From(StateRunning).On(EventPause).To(StatePaused).
+		From(StateRunning).On(EventStart).Consume().
+		Before(StateIdle, EventStart, record("before")).
+		OnLeave(StateIdle, record("leave")).
+		OnEnter(StateRunning, record("enter")).
+		After(StateIdle, EventStart, record("after")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	if !f.Trigger(EventStart) || !f.Trigger(EventStart) || f.CurrentState() != StateRunning {
+		t.Fatalf("Unexpected state %v", f.CurrentState())
+	}
+	if want := []string{"before", "leave", "enter", "after"}; !slices.Equal(calls, want) {
+		t.Errorf("Expected callbacks %v, got %v", want, calls)
+	}
+	if !f.Trigger(EventPause) || f.CurrentState() != StatePaused {
+		t.Errorf("Expected StatePaused, got %v", f.CurrentState())
+	}
+}
+
+// 测试构建器在Build时报告错误
+func TestTableBuilderErrors(t *testing.T) {
+	_, err := fsm.NewTableBuilder().
+		From(StateIdle).On(EventStart).To(StateRunning).
+		From(StateIdle).On(EventStart).To(StateRunning). // 即使目标相同也视为重复
+		Build()
+	if !errors.Is(err, fsm.ErrConflictingTransition) {
+		t.Errorf("Expected ErrConflictingTransition, got %v", err)
+	}
+
+	_, err = fsm.NewTableBuilder().
+		From(StateIdle).On(EventStart).To(fsm.StateInInit).
+		Build()
+	if !errors.Is(err, fsm.ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState, got %v", err)
+	}
+
+	_, err = fsm.NewTableBuilder().
+		From(StateIdle).On(EventStart).To(StateRunning).
+		OnEnter(StateStopped, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {}).
+		Build()
+	if !errors.Is(err, fsm.ErrInvalidCallback) {
+		t.Errorf("Expected ErrInvalidCallback, got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package fsm
+
+import (
+	"math/bits"
+	"slices"
+	"sync/atomic"
+)
+
+// CompiledTable 只读的编译状态转移表，为GetNextState的吞吐量优化
+// 热路径只涉及行宽和一段连续的状态数组，回调被挪到单独的冷数据结构中，
+// 查询时不会因回调切片而挤占缓存行。行宽向上取整为2的幂，下标由移位得到，
+// 负数和越界的状态/事件都会被拒绝而不会串到相邻的行。编译后的表不再接受任何修改。
+type CompiledTable struct {
+	shift     uint32                // 行宽为1<<shift
+	table     []State               // table[state<<shift|event] = nextState，补齐的单元格为StateInInit
+	consumed  []bool                // 与table布局相同，没有接受并忽略的规则时为nil
+	internal  []bool                // 与table布局相同，没有内部转移规则时为nil
+	terminal  []bool                // terminal[state]为true表示该状态是终态，编译时计算
+	callbacks *ArrayTransitionTable // 仅用于回调查询的冷数据，不包含状态数组
+}
+
+// Compile 将数组状态转移表编译为只读的CompiledTable
+// 编译时会复制状态数组、当前已注册的回调和事件优先级，之后对原表的修改不会影响编译结果
+func (t *ArrayTransitionTable) Compile() *CompiledTable {
+	callbacks := &ArrayTransitionTable{
+		maxStates:    t.maxStates,
+		maxEvents:    t.maxEvents,
+		beforeEvents: slices.Clone(t.beforeEvents),
+		afterEvents:  slices.Clone(t.afterEvents),
+		leaveStates:  slices.Clone(t.leaveStates),
+		enterStates:  slices.Clone(t.enterStates),
+		guards:       slices.Clone(t.guards),
+		rejects:      slices.Clone(t.rejects),
+		globals:      t.globals,
+		priorities:   slices.Clone(t.priorities),
+	}
+	for i := range t.ctxCallbacks {
+		callbacks.ctxCallbacks[i] = slices.Clone(t.ctxCallbacks[i])
+		callbacks.errCallbacks[i] = slices.Clone(t.errCallbacks[i])
+	}
+	if t.parents != nil {
+		// 按(state, event)注册的回调沿父状态链解析，需要一份状态数组
+		callbacks.table = slices.Clone(t.table)
+		callbacks.parents = slices.Clone(t.parents)
+	}
+
+	shift := uint32(bits.Len32(uint32(t.maxEvents - 1)))
+	table := make([]State, int(t.maxStates)<<shift)
+	for i := range table {
+		table[i] = StateInInit
+	}
+	for state := int32(0); state < t.maxStates; state++ {
+		copy(table[state<<shift:], t.table[state*t.maxEvents:(state+1)*t.maxEvents])
+	}
+	consumed := t.compileFlags(t.consumed, shift)
+	internal := t.compileFlags(t.internal, shift)
+	if t.parents != nil {
+		t.flattenParents(table, consumed, internal, shift)
+	}
+	terminal := make([]bool, t.maxStates)
+	for state := range terminal {
+		terminal[state] = !slices.ContainsFunc(table[state<<shift:(state+1)<<shift], func(to State) bool {
+			return to != StateInInit
+		})
+	}
+	return &CompiledTable{
+		shift:     shift,
+		table:     table,
+		consumed:  consumed,
+		internal:  internal,
+		terminal:  terminal,
+		callbacks: callbacks,
+	}
+}
+
+// compileFlags 将按单元格存储的标记转换为编译后的行宽布局，flags为nil时返回nil
+func (t *ArrayTransitionTable) compileFlags(flags []atomic.Bool, shift uint32) []bool {
+	if flags == nil {
+		return nil
+	}
+	out := make([]bool, int(t.maxStates)<<shift)
+	for state := int32(0); state < t.maxStates; state++ {
+		for event := int32(0); event < t.maxEvents; event++ {
+			out[state<<shift|event] = flags[state*t.maxEvents+event].Load()
+		}
+	}
+	return out
+}
+
+// GetNextState 获取下一个状态
+func (c *CompiledTable) GetNextState(from State, event Event) (State, bool) {
+	// 负数转换为无符号数后必然越界，各用一次比较即可完成事件和下标的边界检查
+	if uint32(event)>>(c.shift&31) != 0 {
+		return StateInInit, false
+	}
+	index := uint(uint32(from))<<(c.shift&31) | uint(uint32(event))
+	if index >= uint(len(c.table)) {
+		return StateInInit, false
+	}
+	return checkNext(c.table[index])
+}
+
+// EventsFrom 获取指定状态下所有定义了转移规则的事件，按升序排列
+func (c *CompiledTable) EventsFrom(state State) []Event {
+	start := uint(uint32(state)) << (c.shift & 31)
+	if start >= uint(len(c.table)) {
+		return nil
+	}
+	return eventsInRow(c.table[start : start+1<<(c.shift&31)])
+}
+
+// IsTerminal 判断state是否为终态，语义与ArrayTransitionTable.IsTerminal相同
+func (c *CompiledTable) IsTerminal(state State) bool {
+	return state >= 0 && int(state) < len(c.terminal) && c.terminal[state]
+}
+
+// NumStates 获取表中状态的数量，与编译时的ArrayTransitionTable相同
+func (c *CompiledTable) NumStates() int {
+	return len(c.terminal)
+}
+
+// EventPriority 获取事件的静态优先级，语义与ArrayTransitionTable.EventPriority相同
+func (c *CompiledTable) EventPriority(event Event) int {
+	return c.callbacks.EventPriority(event)
+}
+
+// IsConsumed 判断事件在指定状态下是否被标记为接受并忽略
+func (c *CompiledTable) IsConsumed(from State, event Event) bool {
+	if c.consumed == nil || uint32(event)>>(c.shift&31) != 0 {
+		return false
+	}
+	index := uint(uint32(from))<<(c.shift&31) | uint(uint32(event))
+	return index < uint(len(c.consumed)) && c.consumed[index]
+}
+
+// IsInternal 判断事件在指定状态下是否是内部转移
+func (c *CompiledTable) IsInternal(from State, event Event) bool {
+	if c.internal == nil || uint32(event)>>(c.shift&31) != 0 {
+		return false
+	}
+	index := uint(uint32(from))<<(c.shift&31) | uint(uint32(event))
+	return index < uint(len(c.internal)) && c.internal[index]
+}
+
+// GetCallback 获取回调函数
+func (c *CompiledTable) GetCallback(cbType CallbackType, state State, event Event) Handler {
+	return c.callbacks.GetCallback(cbType, state, event)
+}
+
+// GetGlobalCallback 获取指定类型的全局回调
+func (c *CompiledTable) GetGlobalCallback(cbType CallbackType) Handler {
+	return c.callbacks.GetGlobalCallback(cbType)
+}
+
+// GetErrCallback 获取可以中止转移的回调函数
+func (c *CompiledTable) GetErrCallback(cbType CallbackType, state State, event Event) ErrHandler {
+	return c.callbacks.GetErrCallback(cbType, state, event)
+}
+
+// GetRejectHandler 获取事件被拒绝时的回调
+func (c *CompiledTable) GetRejectHandler(state State) RejectHandler {
+	return c.callbacks.GetRejectHandler(state)
+}
+
+// GetGuard 获取转移守卫
+func (c *CompiledTable) GetGuard(state State, event Event) Guard {
+	return c.callbacks.GetGuard(state, event)
+}
+
+// Parent 获取state的父状态，没有父状态时返回StateInInit
+func (c *CompiledTable) Parent(state State) State {
+	return c.callbacks.Parent(state)
+}
+
+// GetContextCallback 获取以TransitionContext为参数的回调函数
+func (c *CompiledTable) GetContextCallback(cbType CallbackType, state State, event Event) ContextHandler {
+	return c.callbacks.GetContextCallback(cbType, state, event)
+}
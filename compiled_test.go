@@ -0,0 +1,77 @@
+package fsm_test
+
+import (
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试编译表与原表的查询结果一致
+func TestCompiledTable(t *testing.T) {
+	table := createTestTransitionTable()
+	var called bool
+	table.RegisterCallback(fsm.EnterState, StateRunning, EventStart, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		called = true
+	})
+	compiled := table.Compile()
+
+	var _ fsm.TransitionTable = compiled
+	for state := fsm.State(-1); state <= StateStopped+1; state++ {
+		for event := fsm.Event(-1); event <= EventStop+1; event++ {
+			got, gotOK := compiled.GetNextState(state, event)
+			if state < 0 || event < 0 || event > EventStop {
+				if gotOK {
+					t.Errorf("Expected out of range lookup (%d, %d) to fail", state, event)
+				}
+				continue
+			}
+			want, wantOK := table.GetNextState(state, event)
+			if want != got || wantOK != gotOK {
+				t.Errorf("GetNextState(%d, %d) = %d, %v; want %d, %v", state, event, got, gotOK, want, wantOK)
+			}
+		}
+	}
+
+	if handler := compiled.GetCallback(fsm.EnterState, StateRunning, EventStart); handler == nil {
+		t.Error("Expected compiled table to keep registered callbacks")
+	} else {
+		handler(nil, StateIdle, StateRunning, EventStart)
+	}
+	if !called {
+		t.Error("Expected compiled callback to be the registered handler")
+	}
+
+	// 编译后原表的修改不影响编译结果
+	table.RegisterCallback(fsm.EnterState, StatePaused, EventPause, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {})
+	if compiled.GetCallback(fsm.EnterState, StatePaused, EventPause) != nil {
+		t.Error("Expected compiled table to be isolated from later registrations")
+	}
+}
+
+var benchSink fsm.State
+
+// 基准测试：数组表查询性能
+func BenchmarkArrayGetNextState(b *testing.B) {
+	table := createTestTransitionTable()
+	for b.Loop() {
+		for state := StateIdle; state <= StateStopped; state++ {
+			for event := EventStart; event <= EventStop; event++ {
+				next, _ := table.GetNextState(state, event)
+				benchSink += next
+			}
+		}
+	}
+}
+
+// 基准测试：编译表查询性能
+func BenchmarkCompiledGetNextState(b *testing.B) {
+	table := createTestTransitionTable().Compile()
+	for b.Loop() {
+		for state := StateIdle; state <= StateStopped; state++ {
+			for event := EventStart; event <= EventStop; event++ {
+				next, _ := table.GetNextState(state, event)
+				benchSink += next
+			}
+		}
+	}
+}
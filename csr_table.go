@@ -0,0 +1,258 @@
+package fsm
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// CSRTransitionTable 以压缩稀疏行（CSR）存储的状态转移表，适合状态很多、每个状态只有少数事件的大型稀疏表
+// ArrayTransitionTable为每个(state, event)分配一个单元格和两个回调槽位，状态数×事件数很大而规则很少时大部分空间被浪费；
+// CSRTransitionTable只为定义了的规则分配空间：每个状态对应events中一段按事件升序排列的区间，
+// GetNextState在该区间内二分查找。状态数据都是扁平的int32切片，不包含逐单元格的指针，不会增加GC扫描的负担；
+// 回调切片在第一次注册时才分配。事件的取值不受限制（可以是负数或很大的值），状态必须是非负数，
+// 行数由最大的状态值决定。每个状态只有少数事件时查询比ArrayTransitionTable略慢，但快于MapTransitionTable
+type CSRTransitionTable struct {
+	rowStart []int32 // 状态s的规则为events[rowStart[s]:rowStart[s+1]]，长度为状态数+1
+	events   []Event // 每一行内按升序排列
+	next     []State // 与events一一对应的目标状态
+	consumed []bool  // 与events一一对应，没有接受并忽略的规则时为nil
+	internal []bool  // 与events一一对应，没有内部转移规则时为nil
+
+	beforeEvents []Handler // 与events一一对应，没有注册回调时为nil
+	afterEvents  []Handler
+	leaveStates  []Handler // 按状态存储，没有注册回调时为nil
+	enterStates  []Handler
+}
+
+// NewCSRTransitionTable 创建新的压缩稀疏行状态转移表，转移规则的语义与NewArrayTransitionTable相同
+// 通配规则（From为AnyState）在构造时展开到每个状态，具体规则优先；同一(From, Event)重复定义时以后者为准。
+// 通配规则会为每个状态各占用一个单元格，状态很多时应尽量少用。
+// 转移规则使用了StateInInit或负数的状态时panic
+func NewCSRTransitionTable(transitions []Transition) *CSRTransitionTable {
+	var maxState State = -1
+	for i, trans := range transitions {
+		if StateInInit == trans.From || StateInInit == trans.To {
+			panic(strconv.Itoa(int(StateInInit)) + " is invalid state")
+		}
+		if (trans.From < 0 && trans.From != AnyState) || trans.To < 0 || (!trans.keepsState() && trans.To == AnyState) {
+			panic(fmt.Sprintf("transition #%d %+v has invalid state for CSRTransitionTable", i, trans))
+		}
+		if trans.From != AnyState {
+			maxState = max(maxState, trans.From)
+		}
+		if !trans.keepsState() {
+			maxState = max(maxState, trans.To)
+		}
+	}
+
+	// 去重后分为具体规则和通配规则，均按(From, Event)排序，以后者为准
+	specific := make([]Transition, 0, len(transitions))
+	var wildcards []Transition
+	for _, trans := range transitions {
+		if trans.From == AnyState {
+			wildcards = append(wildcards, trans)
+		} else {
+			specific = append(specific, trans)
+		}
+	}
+	specific = sortUnique(specific)
+	wildcards = sortUnique(wildcards)
+
+	numStates := int(maxState) + 1
+	cells := len(specific) + numStates*len(wildcards)
+	t := &CSRTransitionTable{
+		rowStart: make([]int32, numStates+1),
+		events:   make([]Event, 0, cells),
+		next:     make([]State, 0, cells),
+	}
+	for state := range numStates {
+		var row []Transition
+		row, specific = splitRow(specific, State(state))
+		// 合并本状态的具体规则和通配规则，两者都按事件升序排列
+		i, j := 0, 0
+		for i < len(row) || j < len(wildcards) {
+			switch {
+			case j == len(wildcards) || (i < len(row) && row[i].Event < wildcards[j].Event):
+				t.add(row[i])
+				i++
+			case i == len(row) || wildcards[j].Event < row[i].Event:
+				wildcard := wildcards[j]
+				wildcard.From = State(state)
+				t.add(wildcard)
+				j++
+			default:
+				// 具体规则优先于通配规则
+				t.add(row[i])
+				i++
+				j++
+			}
+		}
+		t.rowStart[state+1] = int32(len(t.events))
+	}
+	return t
+}
+
+// sortUnique 将转移规则按(From, Event)稳定排序，同一(From, Event)只保留最后一条
+func sortUnique(transitions []Transition) []Transition {
+	slices.SortStableFunc(transitions, func(a, b Transition) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.Event, b.Event))
+	})
+	out := transitions[:0]
+	for i, trans := range transitions {
+		if i+1 < len(transitions) && transitions[i+1].From == trans.From && transitions[i+1].Event == trans.Event {
+			continue
+		}
+		out = append(out, trans)
+	}
+	return out
+}
+
+// splitRow 从已排序的转移规则开头取出From为state的部分，返回这部分和剩余的规则
+func splitRow(sorted []Transition, state State) (row, rest []Transition) {
+	n := 0
+	for n < len(sorted) && sorted[n].From == state {
+		n++
+	}
+	return sorted[:n], sorted[n:]
+}
+
+// add 在当前行的末尾追加一条规则，接受并忽略的规则和内部转移规则以From作为目标状态
+func (t *CSRTransitionTable) add(trans Transition) {
+	to := trans.To
+	if trans.keepsState() {
+		to = trans.From
+	}
+	appendFlag(&t.consumed, len(t.events), cap(t.events), trans.Consume)
+	appendFlag(&t.internal, len(t.events), cap(t.events), trans.Internal && !trans.Consume)
+	t.events = append(t.events, trans.Event)
+	t.next = append(t.next, to)
+}
+
+// appendFlag 追加第n个单元格的标记，flags在第一次追加true时才分配
+func appendFlag(flags *[]bool, n, capacity int, value bool) {
+	if *flags == nil {
+		if !value {
+			return
+		}
+		*flags = make([]bool, n, capacity)
+	}
+	*flags = append(*flags, value)
+}
+
+// cellIndex 获取(state, event)对应单元格的下标，没有定义时返回false
+func (t *CSRTransitionTable) cellIndex(state State, event Event) (int, bool) {
+	if state < 0 || int(state) >= len(t.rowStart)-1 {
+		return 0, false
+	}
+	start, end := t.rowStart[state], t.rowStart[state+1]
+	i, ok := slices.BinarySearch(t.events[start:end], event)
+	return int(start) + i, ok
+}
+
+// GetNextState 获取下一个状态
+func (t *CSRTransitionTable) GetNextState(from State, event Event) (State, bool) {
+	index, ok := t.cellIndex(from, event)
+	if !ok {
+		return StateInInit, false
+	}
+	return checkNext(t.next[index])
+}
+
+// IsConsumed 判断事件在指定状态下是否被标记为接受并忽略
+func (t *CSRTransitionTable) IsConsumed(from State, event Event) bool {
+	if t.consumed == nil {
+		return false
+	}
+	index, ok := t.cellIndex(from, event)
+	return ok && t.consumed[index]
+}
+
+// IsInternal 判断事件在指定状态下是否是内部转移
+func (t *CSRTransitionTable) IsInternal(from State, event Event) bool {
+	if t.internal == nil {
+		return false
+	}
+	index, ok := t.cellIndex(from, event)
+	return ok && t.internal[index]
+}
+
+// EventsFrom 获取指定状态下所有定义了转移规则的事件，按升序排列
+func (t *CSRTransitionTable) EventsFrom(state State) []Event {
+	if state < 0 || int(state) >= len(t.rowStart)-1 || t.rowStart[state] == t.rowStart[state+1] {
+		return nil
+	}
+	return slices.Clone(t.events[t.rowStart[state]:t.rowStart[state+1]])
+}
+
+// Len 获取表中单元格（展开通配规则之后的转移规则）的数量
+func (t *CSRTransitionTable) Len() int {
+	return len(t.events)
+}
+
+// NumStates 获取表中状态的数量，即构造时的numStates
+func (t *CSRTransitionTable) NumStates() int {
+	return len(t.rowStart) - 1
+}
+
+// RegisterCallback 注册回调函数，语义与ArrayTransitionTable.RegisterCallback相同，同一位置的多个回调按注册顺序执行
+// BeforeEvent/AfterEvent回调只能注册在定义了转移规则的(state, event)上，其余的注册与未知的回调类型一样被忽略
+func (t *CSRTransitionTable) RegisterCallback(cbType CallbackType, state State, event Event, handler Handler) {
+	var slots *[]Handler
+	var index int
+	switch cbType {
+	case BeforeEvent, AfterEvent:
+		i, ok := t.cellIndex(state, event)
+		if !ok {
+			return
+		}
+		slots, index = &t.beforeEvents, i
+		if cbType == AfterEvent {
+			slots = &t.afterEvents
+		}
+		if *slots == nil {
+			*slots = make([]Handler, len(t.events))
+		}
+	case LeaveState, EnterState:
+		if state < 0 || int(state) >= len(t.rowStart)-1 {
+			return
+		}
+		slots, index = &t.leaveStates, int(state)
+		if cbType == EnterState {
+			slots = &t.enterStates
+		}
+		if *slots == nil {
+			*slots = make([]Handler, len(t.rowStart)-1)
+		}
+	default:
+		return
+	}
+	(*slots)[index] = chainHandlers((*slots)[index], handler)
+}
+
+// GetCallback 获取回调函数
+func (t *CSRTransitionTable) GetCallback(cbType CallbackType, state State, event Event) Handler {
+	switch cbType {
+	case BeforeEvent, AfterEvent:
+		slots := t.beforeEvents
+		if cbType == AfterEvent {
+			slots = t.afterEvents
+		}
+		if slots == nil {
+			return nil
+		}
+		if index, ok := t.cellIndex(state, event); ok {
+			return slots[index]
+		}
+	case LeaveState, EnterState:
+		slots := t.leaveStates
+		if cbType == EnterState {
+			slots = t.enterStates
+		}
+		if state >= 0 && int(state) < len(slots) {
+			return slots[state]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package fsm_test
+
+import (
+	"runtime"
+	"slices"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试压缩稀疏行状态转移表对稀疏事件、重复规则和回调的处理
+func TestCSRTransitionTable(t *testing.T) {
+	const eventHuge fsm.Event = 1_000_000_000
+	table := fsm.NewCSRTransitionTable([]fsm.Transition{
+		{From: StatePaused, Event: eventHuge, To: StateIdle},
+		{From: StateIdle, Event: EventStart, To: StateStopped},
+		// 同一(From, Event)重复定义时以后者为准
+		{From: StateIdle, Event: EventStart, To: StateRunning},
+		{From: StateRunning, Event: -1, To: StatePaused},
+		{From: fsm.AnyState, Event: EventStop, To: StateStopped},
+		{From: StateStopped, Event: EventStop, Consume: true},
+	})
+	if table.Len() != 7 {
+		t.Errorf("Expected 7 cells, got %d", table.Len())
+	}
+	if got := table.EventsFrom(StatePaused); !slices.Equal(got, []fsm.Event{EventStop, eventHuge}) {
+		t.Errorf("Unexpected events from Paused: %v", got)
+	}
+
+	var entered []fsm.State
+	var before int
+	table.RegisterCallback(fsm.EnterState, StatePaused, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		entered = append(entered, to)
+	})
+	table.RegisterCallback(fsm.BeforeEvent, StatePaused, eventHuge, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		before++
+	})
+	// 没有定义转移规则的(state, event)上的回调被忽略
+	table.RegisterCallback(fsm.BeforeEvent, StatePaused, EventStart, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {})
+	if table.GetCallback(fsm.BeforeEvent, StatePaused, EventStart) != nil {
+		t.Error("Expected callback on undefined cell to be ignored")
+	}
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	for _, event := range []fsm.Event{EventStart, -1, eventHuge} {
+		if !f.Trigger(event) {
+			t.Fatalf("Trigger(%d) failed in state %d", event, f.CurrentState())
+		}
+	}
+	if f.CurrentState() != StateIdle || before != 1 || !slices.Equal(entered, []fsm.State{StatePaused}) {
+		t.Errorf("Unexpected state %d, before %d, entered %v", f.CurrentState(), before, entered)
+	}
+	if !f.Trigger(EventStop) || f.TriggerDetailed(EventStop) != fsm.Consumed {
+		t.Error("Expected wildcard stop followed by consumed stop")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for negative state")
+		}
+	}()
+	fsm.NewCSRTransitionTable([]fsm.Transition{{From: -1, Event: EventStart, To: StateIdle}})
+}
+
+// 稀疏表的规模：sparseStates个状态、sparseEvents种事件，每个状态只有sparseDegree个事件
+const (
+	sparseStates = 2000
+	sparseEvents = 500
+	sparseDegree = 4
+)
+
+// createSparseTransitions 创建每个状态只有少数事件的大型转移规则集
+func createSparseTransitions() []fsm.Transition {
+	transitions := make([]fsm.Transition, 0, sparseStates*sparseDegree)
+	for state := range sparseStates {
+		for k := range sparseDegree {
+			transitions = append(transitions, fsm.Transition{
+				From:  fsm.State(state),
+				Event: fsm.Event((state*7 + k*131) % sparseEvents),
+				To:    fsm.State((state + k + 1) % sparseStates),
+			})
+		}
+	}
+	return transitions
+}
+
+// retainedBytes 测量build返回的对象在GC之后仍然占用的堆内存
+func retainedBytes(build func() any) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+// 基准测试：稀疏表在各实现下的内存占用
+// B/op包括构造时的临时分配，table-B/op是构造完成后一张表实际占用的内存
+func BenchmarkSparseTableMemory(b *testing.B) {
+	transitions := createSparseTransitions()
+	for _, name := range []string{"Array", "Map", "CSR"} {
+		build := tableImpls[name]
+		b.Run(name, func(b *testing.B) {
+			retained := retainedBytes(func() any { return build(transitions) })
+			b.ReportAllocs()
+			for b.Loop() {
+				build(transitions)
+			}
+			b.ReportMetric(float64(retained), "table-B/op")
+		})
+	}
+}
+
+// 基准测试：稀疏表在各实现下的查询性能
+func BenchmarkSparseGetNextState(b *testing.B) {
+	transitions := createSparseTransitions()
+	for _, name := range []string{"Array", "Map", "CSR"} {
+		table := tableImpls[name](transitions)
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				for _, trans := range transitions {
+					next, _ := table.GetNextState(trans.From, trans.Event)
+					benchSink += next
+				}
+			}
+		})
+	}
+}
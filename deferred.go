@@ -0,0 +1,134 @@
+package fsm
+
+import (
+	"context"
+	"maps"
+	"slices"
+)
+
+// DefaultDeferredLimit 延迟队列默认最多保留的事件数量，见SetDeferredLimit
+const DefaultDeferredLimit = 1024
+
+// eventSet 事件的集合
+type eventSet map[Event]bool
+
+// SetDeferrable 设置事件是否可延迟：可延迟的事件在当前状态下没有转移规则时，Trigger不拒绝它，
+// 而是将其放入延迟队列并返回Deferred（Trigger返回true），等到状态机进入它有效的状态后再自动触发，
+// 不执行拒绝回调，严格模式下也不panic。延迟队列的处理方式见PostDeferred。
+// 只影响没有转移规则的情况，被守卫否决、被中止或状态机被冻结时仍按原来的方式处理
+func (f *FSM) SetDeferrable(event Event, deferrable bool) {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	next := make(eventSet)
+	if old := f.deferrable.Load(); old != nil {
+		maps.Copy(next, *old)
+	}
+	if deferrable {
+		next[event] = true
+	} else {
+		delete(next, event)
+	}
+	if len(next) == 0 {
+		f.deferrable.Store(nil)
+		return
+	}
+	f.deferrable.Store(&next)
+}
+
+// SetDeferredLimit 设置延迟队列最多保留的事件数量，默认为DefaultDeferredLimit，limit<=0表示不限制
+// 一直没有进入有效状态的事件会永远留在队列中，不限制时队列可能无限增长；
+// 队列已满时新的延迟事件按普通的拒绝处理（执行拒绝回调，严格模式下panic），已在队列中的事件不受影响；
+// 限制只作用于确实需要延迟的事件，当前状态下有效的事件总是直接触发
+func (f *FSM) SetDeferredLimit(limit int) {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	if limit <= 0 {
+		limit = -1
+	}
+	f.deferredLimit = limit
+}
+
+// PostDeferred 触发事件，事件在当前状态下没有转移规则时放入延迟队列，而不是拒绝
+// 不要求事件被SetDeferrable标记。每次触发（包括延迟事件自己的转移）、超时转移、TriggerSequence、
+// TriggerAll和ResetWithCallbacks完成之后，在释放Event锁、处理完回调中重入触发的事件之后重新检查延迟队列：
+// 按投递顺序找到第一个在当前状态下有转移规则的事件，将其移出队列并触发，状态因此改变后再从队首重新检查，
+// 直到没有可以触发的事件为止。因此延迟事件之间保持投递顺序，但会排在之后投递、立即有效的事件之后；
+// 被移出队列的事件只触发一次，此时被守卫否决或因并发的转移而不再有效时按普通的结果处理，不会再次进入队列。
+// Reset不是转移，不会触发重新检查；状态机被冻结期间延迟队列保持不变。
+// 事件在当前状态下已经有转移规则时直接触发，不进入延迟队列，也不受SetDeferredLimit的限制；
+// 检查与触发之间状态被并发的转移改变时按普通的结果处理。
+// 在本状态机的回调中调用时与Trigger一样放入重入队列，在当前转移完成后再按上述规则处理
+func (f *FSM) PostDeferred(event Event, args ...any) {
+	if f.inOwnTransition() && f.deferReentrant(postedEvent{event: event, args: args, post: true}) {
+		return
+	}
+	ctx := context.Background()
+	f.postDeferred(ctx, event, args, false)
+	f.drainReentrant(ctx)
+}
+
+// postDeferred 事件在当前状态下有转移规则时直接触发，否则放入延迟队列，队列已满时按普通的拒绝处理
+// 不处理重入队列，nested的含义与dispatch相同
+func (f *FSM) postDeferred(ctx context.Context, event Event, args []any, nested bool) {
+	if f.hasRule(f.CurrentState(), event) {
+		f.dispatch(ctx, event, args, nested)
+		return
+	}
+	if !f.pushDeferred(event, args) {
+		f.reject(f.CurrentState(), event, args)
+	}
+}
+
+// hasRule 判断事件在state下是否有目标状态有效的转移规则
+func (f *FSM) hasRule(state State, event Event) bool {
+	next, ok := f.transitionTable.GetNextState(state, event)
+	_, valid := checkNext(next)
+	return ok && valid
+}
+
+// DeferredEvents 获取延迟队列中等待处理的事件数量
+func (f *FSM) DeferredEvents() int {
+	return int(f.deferredCount.Load())
+}
+
+// isDeferrable 判断事件是否被SetDeferrable标记为可延迟
+func (f *FSM) isDeferrable(event Event) bool {
+	deferrable := f.deferrable.Load()
+	return deferrable != nil && (*deferrable)[event]
+}
+
+// pushDeferred 将事件放入延迟队列，队列已满时返回false
+func (f *FSM) pushDeferred(event Event, args []any) bool {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	limit := f.deferredLimit
+	if limit == 0 {
+		limit = DefaultDeferredLimit
+	}
+	if limit > 0 && len(f.deferred) >= limit {
+		return false
+	}
+	f.deferred = append(f.deferred, postedEvent{event: event, args: args})
+	f.deferredCount.Add(1)
+	return true
+}
+
+// popDeferred 取出延迟队列中第一个在当前状态下有转移规则的事件，没有时返回false
+// 没有延迟事件时只有一次原子读取
+func (f *FSM) popDeferred() (postedEvent, bool) {
+	if f.deferredCount.Load() == 0 || f.paused.Load() {
+		return postedEvent{}, false
+	}
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	current := f.CurrentState()
+	for i, posted := range f.deferred {
+		if !f.hasRule(current, posted.event) {
+			continue
+		}
+		f.deferred = slices.Delete(f.deferred, i, i+1)
+		f.deferredCount.Add(-1)
+		return posted, true
+	}
+	return postedEvent{}, false
+}
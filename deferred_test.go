@@ -0,0 +1,87 @@
+package fsm_test
+
+import (
+	"slices"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试延迟事件在进入有效状态后按投递顺序自动触发
+func TestPostDeferred(t *testing.T) {
+	table := createTestTransitionTable()
+	var events []fsm.Event
+	table.RegisterGlobalCallback(fsm.BeforeEvent, func(_ *fsm.FSM, _, _ fsm.State, event fsm.Event, _ ...any) {
+		events = append(events, event)
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+
+	// 当前有效的事件立即触发
+	f.PostDeferred(EventStart)
+	if f.CurrentState() != StateRunning || f.DeferredEvents() != 0 {
+		t.Fatalf("Expected immediate transition, got state %d with %d deferred", f.CurrentState(), f.DeferredEvents())
+	}
+	// EventResume在StateRunning中无效，等到StatePaused后才触发
+	f.PostDeferred(EventResume)
+	f.PostDeferred(EventStart)
+	if f.DeferredEvents() != 2 {
+		t.Fatalf("Expected 2 deferred events, got %d", f.DeferredEvents())
+	}
+	f.Trigger(EventPause)
+	// EventPause -> Resume（延迟）-> 回到StateRunning，EventStart仍然无效
+	if f.CurrentState() != StateRunning || f.DeferredEvents() != 1 {
+		t.Errorf("Expected deferred resume to run, got state %d with %d deferred", f.CurrentState(), f.DeferredEvents())
+	}
+	if want := []fsm.Event{EventStart, EventPause, EventResume}; !slices.Equal(events, want) {
+		t.Errorf("Expected %v, got %v", want, events)
+	}
+}
+
+// 测试可延迟的事件和延迟队列的上限
+func TestDeferrableAndLimit(t *testing.T) {
+	table := createTestTransitionTable()
+	rejected := 0
+	table.RegisterRejectHandler(StateIdle, func(*fsm.FSM, fsm.State, fsm.Event, ...any) {
+		rejected++
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.SetDeferrable(EventStop, true)
+	f.SetDeferredLimit(1)
+
+	if r := f.TriggerDetailed(EventStop); r != fsm.Deferred || rejected != 0 {
+		t.Errorf("Expected EventStop to be deferred without rejection, got %d", r)
+	}
+	// 未标记的事件照常拒绝
+	if f.Trigger(EventPause) || rejected != 1 {
+		t.Errorf("Expected EventPause to be rejected, rejected %d", rejected)
+	}
+	// 队列已满，按普通的拒绝处理
+	if f.TriggerDetailed(EventStop) != fsm.Rejected || rejected != 2 {
+		t.Errorf("Expected full deferred queue to reject, rejected %d", rejected)
+	}
+	// 队列已满时当前有效的事件仍然直接触发，不受上限影响
+	f.PostDeferred(EventStart)
+	if f.CurrentState() != StateStopped || f.DeferredEvents() != 0 || rejected != 2 {
+		t.Errorf("Expected posted EventStart and deferred EventStop to run, got state %d, rejected %d", f.CurrentState(), rejected)
+	}
+}
+
+// 测试回调中投递的延迟事件在当前转移完成后再决定触发还是延迟
+func TestPostDeferredInCallback(t *testing.T) {
+	table := createTestTransitionTable()
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		if from == StateIdle {
+			// EventResume在Running下无效需要延迟，EventPause有效直接触发
+			f.PostDeferred(EventResume)
+			f.PostDeferred(EventPause)
+		}
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.SetDeferredLimit(1)
+
+	f.Trigger(EventStart)
+	// EventPause进入Paused后，延迟的EventResume随即生效
+	if f.CurrentState() != StateRunning || f.DeferredEvents() != 0 {
+		t.Errorf("Expected posted events to run in order, got state %d with %d deferred", f.CurrentState(), f.DeferredEvents())
+	}
+}
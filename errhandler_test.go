@@ -0,0 +1,54 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试ErrHandler中止转移
+func TestErrHandlerAbortsTransition(t *testing.T) {
+	errNotReady := errors.New("not ready")
+	table := createTestTransitionTable()
+	ready := false
+	entered := 0
+	table.RegisterErrCallback(fsm.BeforeEvent, StateIdle, EventStart, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) error {
+		if !ready {
+			return errNotReady
+		}
+		return nil
+	})
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		entered++
+	})
+
+	for name, tt := range map[string]fsm.TransitionTable{"Array": table, "Compiled": table.Compile()} {
+		t.Run(name, func(t *testing.T) {
+			ready, entered = false, 0
+			f := fsm.NewFSM(0, StateIdle, tt)
+			if ok, err := f.TriggerE(EventStart); ok || !errors.Is(err, errNotReady) {
+				t.Errorf("Expected abort with errNotReady, got %v, %v", ok, err)
+			}
+			if f.CurrentState() != StateIdle || entered != 0 || f.TriggerDetailed(EventStart) != fsm.Aborted {
+				t.Errorf("Expected aborted transition to leave state unchanged, got %d", f.CurrentState())
+			}
+			// Trigger忽略错误，只返回false
+			if f.Trigger(EventStart) {
+				t.Error("Expected Trigger to return false on abort")
+			}
+
+			ready = true
+			if ok, err := f.TriggerE(EventStart); !ok || err != nil || entered != 1 {
+				t.Errorf("Expected successful transition, got %v, %v", ok, err)
+			}
+			if ok, err := f.TriggerE(EventStart); ok || err != nil {
+				t.Errorf("Expected plain rejection without error, got %v, %v", ok, err)
+			}
+			f.Pause()
+			if _, err := f.TriggerE(EventPause); !errors.Is(err, fsm.ErrPaused) {
+				t.Errorf("Expected ErrPaused, got %v", err)
+			}
+		})
+	}
+}
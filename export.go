@@ -0,0 +1,132 @@
+package fsm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ToDOT 以Graphviz DOT格式输出状态转移表，可以直接交给dot -Tpng渲染
+// 每个出现在转移规则中的状态输出一个节点，每条转移规则输出一条以事件值为标签的边
+func (t *ArrayTransitionTable) ToDOT(w io.Writer) error {
+	return t.ToDOTNamed(w, nil, nil)
+}
+
+// ToDOTNamed 与ToDOT相同，但使用可读的名称作为节点和边的标签
+// 名称映射可以为nil，也可以只包含部分状态或事件，缺失的项仍以数值显示
+func (t *ArrayTransitionTable) ToDOTNamed(w io.Writer, stateNames map[State]string, eventNames map[Event]string) error {
+	var b strings.Builder
+	b.WriteString("digraph fsm {\n")
+	for state, used := range t.usedStates() {
+		if !used {
+			continue
+		}
+		id := dotStateID(State(state))
+		if name, ok := stateNames[State(state)]; ok {
+			fmt.Fprintf(&b, "\t%q [label=%q];\n", id, name)
+		} else {
+			fmt.Fprintf(&b, "\t%q;\n", id)
+		}
+	}
+	t.writeDOTEdges(&b, eventNames)
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// StateNamer 获取状态的可读名称
+type StateNamer func(state State) string
+
+// EventNamer 获取事件的可读名称
+type EventNamer func(event Event) string
+
+// ToMermaid 以Mermaid stateDiagram-v2格式输出状态转移表，便于嵌入Markdown文档
+// 转移规则按状态、事件升序输出为"From --> To : Event"，输出是确定的，适合纳入版本管理。
+// names和events可以为nil，此时以数值显示；指定initial时额外输出"[*] --> 初始状态"
+func (t *ArrayTransitionTable) ToMermaid(names StateNamer, events EventNamer, initial ...State) string {
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	for _, state := range initial {
+		fmt.Fprintf(&b, "    [*] --> %s\n", mermaidStateID(state))
+	}
+	if names != nil {
+		for state, used := range t.usedStates() {
+			if used {
+				fmt.Fprintf(&b, "    %s : %s\n", mermaidStateID(State(state)), names(State(state)))
+			}
+		}
+	}
+	t.writeMermaidEdges(&b, events)
+	return b.String()
+}
+
+// ToDOTLive 以Graphviz DOT格式输出状态机的转移图，并突出显示状态机的当前状态
+// 当前状态在开始时原子地读取一次，输出的是该时刻的一致快照。
+// 状态转移表不是ArrayTransitionTable时无法枚举转移规则，只输出当前状态节点
+func ToDOTLive(f *FSM, w io.Writer) error {
+	current := f.CurrentState()
+	var b strings.Builder
+	b.WriteString("digraph fsm {\n")
+	if t, ok := f.transitionTable.(*ArrayTransitionTable); ok {
+		t.writeDOTEdges(&b, nil)
+	}
+	fmt.Fprintf(&b, "\t%q [style=filled, fillcolor=lightblue];\n", dotStateID(current))
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ToMermaidLive 以Mermaid stateDiagram-v2格式输出状态机的转移图，当前状态使用current样式
+// 当前状态在开始时原子地读取一次，输出的是该时刻的一致快照。
+// 状态转移表不是ArrayTransitionTable时无法枚举转移规则，只输出当前状态节点
+func ToMermaidLive(f *FSM, w io.Writer) error {
+	current := f.CurrentState()
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	if t, ok := f.transitionTable.(*ArrayTransitionTable); ok {
+		t.writeMermaidEdges(&b, nil)
+	} else {
+		fmt.Fprintf(&b, "    %s\n", mermaidStateID(current))
+	}
+	b.WriteString("    classDef current fill:#add8e6,font-weight:bold\n")
+	fmt.Fprintf(&b, "    class %s current\n", mermaidStateID(current))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeDOTEdges 按状态、事件升序输出所有转移规则，接受并忽略的事件以虚线自环表示
+// eventNames中没有的事件以数值作为标签
+func (t *ArrayTransitionTable) writeDOTEdges(b *strings.Builder, eventNames map[Event]string) {
+	t.RangeTransitions(func(tr Transition) bool {
+		style := ""
+		if tr.Consume {
+			style = ", style=dashed"
+		}
+		label, ok := eventNames[tr.Event]
+		if !ok {
+			label = fmt.Sprint(int32(tr.Event))
+		}
+		fmt.Fprintf(b, "\t%q -> %q [label=%q%s];\n", dotStateID(tr.From), dotStateID(tr.To), label, style)
+		return true
+	})
+}
+
+// writeMermaidEdges 按状态、事件升序输出所有转移规则，events为nil时以事件值作为标签
+func (t *ArrayTransitionTable) writeMermaidEdges(b *strings.Builder, events EventNamer) {
+	t.RangeTransitions(func(tr Transition) bool {
+		label := fmt.Sprint(int32(tr.Event))
+		if events != nil {
+			label = events(tr.Event)
+		}
+		fmt.Fprintf(b, "    %s --> %s : %s\n", mermaidStateID(tr.From), mermaidStateID(tr.To), label)
+		return true
+	})
+}
+
+func dotStateID(state State) string {
+	return fmt.Sprint(int32(state))
+}
+
+func mermaidStateID(state State) string {
+	return fmt.Sprintf("S%d", int32(state))
+}
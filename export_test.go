@@ -0,0 +1,164 @@
+package fsm_test
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试状态转移表的DOT输出
+func TestToDOT(t *testing.T) {
+	table := createTestTransitionTable()
+
+	var b strings.Builder
+	if err := table.ToDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "digraph fsm {\n\t\"0\";\n\t\"1\";\n") || !strings.Contains(b.String(), "\t\"0\" -> \"1\" [label=\"0\"];\n") {
+		t.Errorf("Unexpected DOT output:\n%s", b.String())
+	}
+
+	b.Reset()
+	states := map[fsm.State]string{StateIdle: "Idle", StateRunning: "Running"}
+	events := map[fsm.Event]string{EventStart: "start"}
+	if err := table.ToDOTNamed(&b, states, events); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, line := range []string{
+		"\t\"0\" [label=\"Idle\"];\n",
+		"\t\"2\";\n", // 没有名称的状态仍然输出节点
+		"\t\"0\" -> \"1\" [label=\"start\"];\n",
+		"\t\"1\" -> \"2\" [label=\"1\"];\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected %q in DOT output:\n%s", line, out)
+		}
+	}
+}
+
+// 测试带当前状态标记的DOT输出
+func TestToDOTLive(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	f.Trigger(EventStart)
+
+	var b strings.Builder
+	if err := fsm.ToDOTLive(f, &b); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph fsm {
+	"0" -> "1" [label="0"];
+	"1" -> "2" [label="1"];
+	"1" -> "3" [label="3"];
+	"2" -> "1" [label="2"];
+	"2" -> "3" [label="3"];
+	"1" [style=filled, fillcolor=lightblue];
+}
+`
+	if b.String() != want {
+		t.Errorf("Unexpected DOT output:\n%s", b.String())
+	}
+}
+
+// 测试状态转移表的Mermaid输出
+func TestToMermaid(t *testing.T) {
+	table := createTestTransitionTable()
+	stateNames := []string{"Idle", "Running", "Paused", "Stopped"}
+	eventNames := []string{"start", "pause", "resume", "stop"}
+
+	out := table.ToMermaid(
+		func(s fsm.State) string { return stateNames[s] },
+		func(e fsm.Event) string { return eventNames[e] },
+		StateIdle,
+	)
+	want := `stateDiagram-v2
+    [*] --> S0
+    S0 : Idle
+    S1 : Running
+    S2 : Paused
+    S3 : Stopped
+    S0 --> S1 : start
+    S1 --> S2 : pause
+    S1 --> S3 : stop
+    S2 --> S1 : resume
+    S2 --> S3 : stop
+`
+	if out != want {
+		t.Errorf("Unexpected Mermaid output:\n%s", out)
+	}
+
+	if out := table.ToMermaid(nil, nil); !strings.HasPrefix(out, "stateDiagram-v2\n    S0 --> S1 : 0\n") {
+		t.Errorf("Unexpected unnamed Mermaid output:\n%s", out)
+	}
+}
+
+// 测试带当前状态标记的Mermaid输出
+func TestToMermaidLive(t *testing.T) {
+	f := fsm.NewFSM(0, StatePaused, createTestTransitionTable())
+
+	var b strings.Builder
+	if err := fsm.ToMermaidLive(f, &b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.HasPrefix(out, "stateDiagram-v2\n    S0 --> S1 : 0\n") {
+		t.Errorf("Unexpected Mermaid output:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "    class S2 current\n") {
+		t.Errorf("Expected current state marker, got:\n%s", out)
+	}
+
+	// 无法枚举转移规则的表只输出当前状态
+	b.Reset()
+	compiled := fsm.NewFSM(0, StateIdle, createTestTransitionTable().Compile())
+	if err := fsm.ToMermaidLive(compiled, &b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "-->") || !strings.Contains(b.String(), "class S0 current") {
+		t.Errorf("Unexpected fallback output:\n%s", b.String())
+	}
+}
+
+// 测试状态转移表的JSON导出和重建
+func TestTableJSONRoundTrip(t *testing.T) {
+	table := fsm.NewArrayTransitionTable(append(testTransitions,
+		fsm.Transition{From: StateStopped, Event: EventStop, Consume: true}))
+
+	data, err := json.Marshal(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `{"maxStates":4,"maxEvents":4,"transitions":[{"from":0,"event":0,"to":1},`) ||
+		!strings.Contains(string(data), `{"from":3,"event":3,"to":3,"consume":true}`) {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+
+	var restored fsm.ArrayTransitionTable
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(restored.Transitions(), table.Transitions()) {
+		t.Errorf("Expected %v, got %v", table.Transitions(), restored.Transitions())
+	}
+	if again, _ := json.Marshal(&restored); string(again) != string(data) {
+		t.Errorf("Expected stable output, got %s", again)
+	}
+	f := fsm.NewFSM(0, StateIdle, &restored)
+	if !f.Trigger(EventStart) || f.CurrentState() != StateRunning {
+		t.Errorf("Expected restored table to be usable, got %v", f.CurrentState())
+	}
+
+	for _, doc := range []string{
+		`{"maxStates":-1,"maxEvents":1,"transitions":[]}`,
+		`{"maxStates":1,"maxEvents":1,"transitions":[{"from":0,"event":0,"to":1}]}`,
+		`{"maxStates":2,"maxEvents":1,"transitions":[{"from":0,"event":0,"to":1},{"from":0,"event":0,"to":0}]}`,
+	} {
+		if err := json.Unmarshal([]byte(doc), &restored); !errors.Is(err, fsm.ErrInvalidDefinition) {
+			t.Errorf("Expected ErrInvalidDefinition for %s, got %v", doc, err)
+		}
+	}
+}
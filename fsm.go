@@ -1,11 +1,16 @@
 package fsm
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"math"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // State 表示状态机的状态类型
@@ -13,8 +18,21 @@ type State int32
 
 const (
 	StateInInit State = math.MaxInt32
+	// AnyState 通配状态，只能用作Transition.From，表示该事件在任意状态下都按此规则转移
+	// 更具体的规则优先：某个状态显式定义了同一事件的转移时，以显式定义为准，与规则的先后顺序无关
+	AnyState State = math.MaxInt32 - 1
 )
 
+// checkNext 将状态数组中存储的值转换为GetNextState的返回值
+// StateInInit是所有状态转移表通用的"无状态"哨兵：查不到转移规则时必须返回(StateInInit, false)，
+// 且状态机永远不会进入StateInInit。所有TransitionTable实现和Trigger都通过它解释查询结果
+func checkNext(next State) (State, bool) {
+	if next == StateInInit {
+		return StateInInit, false
+	}
+	return next, true
+}
+
 // Event 表示状态机的事件类型
 type Event int32
 
@@ -23,11 +41,51 @@ type Transition struct {
 	From  State
 	Event Event
 	To    State
+	// Consume 为true时表示在From状态下接受并忽略该事件：不改变状态、不执行任何回调，
+	// Trigger返回true，此时To被忽略。与自转移不同，自转移会执行完整的回调流程
+	Consume bool
+	// Internal 为true时表示内部转移：状态保持为From，只执行BeforeEvent/AfterEvent回调，
+	// 不离开也不重新进入该状态，即不执行LeaveState/EnterState回调，停留时长和超时也不会重新计算，此时To被忽略。
+	// 不设置时，To等于From的转移是外部自转移，会像普通转移一样离开并重新进入该状态。Consume优先于Internal
+	Internal bool
+}
+
+// keepsState 转移规则是否总是保持源状态，此时To被忽略
+func (trans Transition) keepsState() bool {
+	return trans.Consume || trans.Internal
 }
 
 // Handler 业务逻辑处理函数类型
 type Handler func(fsm *FSM, from State, to State, event Event, args ...any)
 
+// TransitionContext 一次状态转移的完整上下文
+type TransitionContext struct {
+	FSM   *FSM
+	From  State
+	To    State
+	Event Event
+	Args  Args
+	Seq   uint64          // 本次转移的序号，即状态机第几次成功转移，从1开始
+	Ctx   context.Context // 触发事件时携带的上下文，未指定时为context.Background()
+
+	stopped bool // 是否已停止传播
+}
+
+// StopPropagation 停止本次转移中其余回调的执行，状态转移本身仍会正常提交
+// 停止后当前阶段剩余的回调以及之后所有阶段（LeaveState、EnterState、AfterEvent）的回调都不再执行，
+// 类似UI框架中事件冒泡的stopPropagation
+func (tc *TransitionContext) StopPropagation() {
+	tc.stopped = true
+}
+
+// ContextHandler 以TransitionContext为参数的回调函数类型
+// 新增上下文字段时无需再修改回调函数签名
+type ContextHandler func(tc *TransitionContext)
+
+// ErrHandler 可以返回错误的回调函数，只用于BeforeEvent和LeaveState阶段
+// 返回非nil错误时中止转移：状态保持不变，后续阶段的回调都不会执行，错误通过TriggerE返回
+type ErrHandler func(fsm *FSM, from State, to State, event Event, args ...any) error
+
 // CallbackType 回调类型
 type CallbackType int
 
@@ -38,12 +96,75 @@ const (
 	EnterState
 )
 
+// String 返回回调类型的名称，未知的回调类型返回"CallbackType(N)"
+func (c CallbackType) String() string {
+	switch c {
+	case BeforeEvent:
+		return "BeforeEvent"
+	case AfterEvent:
+		return "AfterEvent"
+	case LeaveState:
+		return "LeaveState"
+	case EnterState:
+		return "EnterState"
+	}
+	return "CallbackType(" + strconv.Itoa(int(c)) + ")"
+}
+
 // TransitionTable 状态转移表接口
 type TransitionTable interface {
 	GetNextState(from State, event Event) (State, bool)
 	GetCallback(cbType CallbackType, state State, event Event) Handler
 }
 
+// ConsumeTable 可选接口：支持接受并忽略事件的状态转移表
+type ConsumeTable interface {
+	IsConsumed(from State, event Event) bool
+}
+
+// InternalTable 可选接口：支持内部转移（见Transition.Internal）的状态转移表
+type InternalTable interface {
+	IsInternal(from State, event Event) bool
+}
+
+// EventPriorityTable 可选接口：支持静态事件优先级的状态转移表
+type EventPriorityTable interface {
+	EventPriority(event Event) int
+}
+
+// RejectHandler 事件因当前状态下没有转移规则而被拒绝时调用的回调，可用于统计或记录无效事件
+type RejectHandler func(fsm *FSM, from State, event Event, args ...any)
+
+// RejectTable 可选接口：支持拒绝回调的状态转移表
+type RejectTable interface {
+	GetRejectHandler(state State) RejectHandler
+}
+
+// ErrCallbackTable 可选接口：支持ErrHandler回调的状态转移表
+type ErrCallbackTable interface {
+	GetErrCallback(cbType CallbackType, state State, event Event) ErrHandler
+}
+
+// GlobalCallbackTable 可选接口：支持对所有转移生效的全局回调的状态转移表
+type GlobalCallbackTable interface {
+	GetGlobalCallback(cbType CallbackType) Handler
+}
+
+// EventsFromTable 可选接口：能够枚举指定状态下可用事件的状态转移表
+type EventsFromTable interface {
+	EventsFrom(state State) []Event
+}
+
+// ContextCallbackTable 可选接口：支持ContextHandler回调的状态转移表
+type ContextCallbackTable interface {
+	GetContextCallback(cbType CallbackType, state State, event Event) ContextHandler
+}
+
+// StateCountTable 可选接口：状态数量固定的状态转移表，合法的状态为[0, NumStates())
+type StateCountTable interface {
+	NumStates() int
+}
+
 // ArrayTransitionTable 基于数组的状态转移表，GC友好
 type ArrayTransitionTable struct {
 	maxStates    int32
@@ -53,54 +174,184 @@ type ArrayTransitionTable struct {
 	afterEvents  []Handler
 	leaveStates  []Handler
 	enterStates  []Handler
+	ctxCallbacks [4][]ContextHandler // 按CallbackType索引，首次注册时分配
+	consumed     []atomic.Bool       // 被标记为接受并忽略的(state, event)，没有此类规则时为nil
+	cache        analysisCache       // 终态集合、可达矩阵等分析结果的缓存
+	priorities   []int               // 各事件的静态优先级，未设置过时为nil
+	guards       []Guard             // 按(state, event)存储的转移守卫，首次注册时分配
+	errCallbacks [4][]ErrHandler     // 按CallbackType索引，首次注册时分配
+	mu           sync.Mutex          // 串行化AddTransition/RemoveTransition
+	rejects      []RejectHandler     // 按state存储的拒绝回调，首次注册时分配
+	parents      []State             // 各状态的父状态，没有时为StateInInit，首次设置时分配
+	globals      [4]Handler          // 按CallbackType索引的全局回调
+	internal     []atomic.Bool       // 被标记为内部转移的(state, event)，没有此类规则时为nil
 }
 
 // NewArrayTransitionTable 创建新的数组状态转移表
+// 这是"must"风格的构造函数：转移规则使用了StateInInit或者表过大时直接panic，
+// 适合转移规则在代码中写死的场景；从配置等外部输入加载时应使用NewArrayTransitionTableChecked。
+// transitions为空时得到一个1个状态x1个事件、没有任何规则的表，它会拒绝所有事件，
+// 只适合之后通过AddTransition填充规则的场景
 func NewArrayTransitionTable(transitions []Transition) *ArrayTransitionTable {
-	maxStates, maxEvents := getMaxStatesAndEvents(transitions)
-	t := &ArrayTransitionTable{
-		maxStates:    maxStates,
-		maxEvents:    maxEvents,
-		table:        make([]State, maxStates*maxEvents),
-		beforeEvents: make([]Handler, maxStates*maxEvents),
-		afterEvents:  make([]Handler, maxStates*maxEvents),
-		leaveStates:  make([]Handler, maxStates),
-		enterStates:  make([]Handler, maxStates),
+	return newArrayTransitionTable(transitions, StateInInit)
+}
+
+// NewArrayTransitionTableWithDefault 创建新的数组状态转移表，未定义转移规则的(state, event)都转移到defaultTo
+// 适合按设计对所有输入都有响应的状态机，例如将所有未预期的事件统一导向错误状态。
+// 这些默认转移与普通转移一样会执行回调，也会出现在Transitions等枚举结果中
+func NewArrayTransitionTableWithDefault(transitions []Transition, defaultTo State) *ArrayTransitionTable {
+	if defaultTo == StateInInit || defaultTo < 0 {
+		panic("NewArrayTransitionTableWithDefault: invalid default state " + strconv.Itoa(int(defaultTo)))
 	}
+	return newArrayTransitionTable(transitions, defaultTo)
+}
 
-	// 初始化表格，默认无效状态
-	for i := range t.table {
-		t.table[i] = StateInInit
+// newArrayTransitionTable 创建数组状态转移表，未定义的单元格填充为defaultTo
+func newArrayTransitionTable(transitions []Transition, defaultTo State) *ArrayTransitionTable {
+	maxStates, maxEvents := getMaxStatesAndEvents(transitions)
+	if defaultTo != StateInInit {
+		// 默认目标状态也必须是表中的状态
+		maxStates = max(maxStates, int32(defaultTo)+1)
 	}
+	t := &ArrayTransitionTable{}
+	t.allocate(maxStates, maxEvents, defaultTo)
 
-	// 填充转移规则
-	for _, trans := range transitions {
-		if StateInInit == trans.From || StateInInit == trans.To {
-			panic(strconv.Itoa(int(StateInInit)) + " is invalid state")
+	// 先将通配规则展开到每一行，再填充具体规则，保证具体规则优先
+	for i, trans := range transitions {
+		if trans.From != AnyState {
+			continue
 		}
-		index := int32(trans.From)*maxEvents + int32(trans.Event)
-		if index < int32(len(t.table)) {
-			t.table[index] = trans.To
+		for state := range maxStates {
+			wildcard := trans
+			wildcard.From = State(state)
+			t.fill(i, wildcard)
+		}
+	}
+	for i, trans := range transitions {
+		if trans.From != AnyState {
+			t.fill(i, trans)
 		}
 	}
 
 	return t
 }
 
+// allocate 按指定的大小分配状态数组和回调数组，所有单元格初始化为defaultTo
+func (t *ArrayTransitionTable) allocate(maxStates, maxEvents int32, defaultTo State) {
+	t.maxStates = maxStates
+	t.maxEvents = maxEvents
+	t.table = make([]State, maxStates*maxEvents)
+	t.beforeEvents = make([]Handler, maxStates*maxEvents)
+	t.afterEvents = make([]Handler, maxStates*maxEvents)
+	t.leaveStates = make([]Handler, maxStates)
+	t.enterStates = make([]Handler, maxStates)
+
+	// 初始化表格，默认无效状态或指定的默认目标状态
+	for i := range t.table {
+		t.table[i] = defaultTo
+	}
+}
+
+// fill 将第i条转移规则填入表中
+func (t *ArrayTransitionTable) fill(i int, trans Transition) {
+	if StateInInit == trans.From || StateInInit == trans.To {
+		panic(strconv.Itoa(int(StateInInit)) + " is invalid state")
+	}
+	// 表的大小由同一组转移规则计算得出，放不进去的规则（如负数的状态或事件）说明配置有误，
+	// 直接panic，而不是静默丢弃后在运行时表现为"转移不被允许"
+	index, ok := t.cellIndex(trans.From, trans.Event)
+	if !ok || trans.To < 0 || (!trans.keepsState() && int32(trans.To) >= t.maxStates) {
+		panic(fmt.Sprintf("transition #%d %+v does not fit in %d states x %d events table, use NewArrayTransitionTableChecked or MapTransitionTable",
+			i, trans, t.maxStates, t.maxEvents))
+	}
+	// 同时覆盖之前的接受并忽略规则和内部转移规则（例如通配规则）
+	setFlag(&t.consumed, len(t.table), index, trans.Consume)
+	setFlag(&t.internal, len(t.table), index, trans.Internal && !trans.Consume)
+	if trans.keepsState() {
+		t.table[index] = trans.From
+	} else {
+		t.table[index] = trans.To
+	}
+}
+
+// setFlag 设置按单元格存储的标记，flags在第一次设置为true时才按size分配
+// 标记与单元格一样按原子操作访问，允许与AddTransition/RemoveTransition并发读取
+func setFlag(flags *[]atomic.Bool, size int, index int32, value bool) {
+	if *flags == nil {
+		if !value {
+			return
+		}
+		*flags = make([]atomic.Bool, size)
+	}
+	(*flags)[index].Store(value)
+}
+
+// flagAt 读取第index个单元格的标记，flags为nil时返回false
+func flagAt(flags []atomic.Bool, index int32) bool {
+	return index < int32(len(flags)) && flags[index].Load()
+}
+
+// cloneFlags 复制按单元格存储的标记
+func cloneFlags(flags []atomic.Bool) []atomic.Bool {
+	if flags == nil {
+		return nil
+	}
+	clone := make([]atomic.Bool, len(flags))
+	for i := range flags {
+		clone[i].Store(flags[i].Load())
+	}
+	return clone
+}
+
+// NewArrayTransitionTableChecked 创建新的数组状态转移表，并在构造前校验转移规则
+// 转移规则使用了StateInInit时返回指明规则下标的错误，而不是panic；
+// 同一(From, Event)存在不一致的重复定义时返回错误，而不是让后者静默覆盖前者；
+// 单元格数量超过MaxTableCells时返回错误，而不是尝试分配巨大的数组；
+// transitions为空时返回ErrNoTransitions，而不是得到一个拒绝所有事件的表
+func NewArrayTransitionTableChecked(transitions []Transition) (*ArrayTransitionTable, error) {
+	if len(transitions) == 0 {
+		return nil, ErrNoTransitions
+	}
+	if err := validateStates(transitions); err != nil {
+		return nil, err
+	}
+	if err := ValidateNoConflicts(transitions); err != nil {
+		return nil, err
+	}
+	if err := validateTableSize(transitions); err != nil {
+		return nil, err
+	}
+	return NewArrayTransitionTable(transitions), nil
+}
+
+// getMaxStatesAndEvents 计算容纳所有转移规则所需的状态数和事件数
+// 单元格总数超出int32范围时panic，而不是在乘法溢出后分配一个错误大小的数组
 func getMaxStatesAndEvents(transitions []Transition) (maxStates, maxEvents int32) {
+	states, events := tableDims(transitions)
+	if states*events > math.MaxInt32 {
+		panic(fmt.Sprintf("transition table of %d states x %d events overflows int32, use NewArrayTransitionTableChecked or MapTransitionTable",
+			states, events))
+	}
+	return int32(states), int32(events)
+}
+
+// tableDims 以int64计算容纳所有转移规则所需的状态数和事件数，不会溢出
+func tableDims(transitions []Transition) (states, events int64) {
+	var maxState, maxEvent int64
 	for _, trans := range transitions {
-		if trans.From > State(maxStates) {
-			maxStates = int32(trans.From)
+		// 通配状态不占用单独的行
+		if trans.From != AnyState {
+			maxState = max(maxState, int64(trans.From))
 		}
-		if trans.To > State(maxStates) {
-			maxStates = int32(trans.To)
-		}
-		if trans.Event > Event(maxEvents) {
-			maxEvents = int32(trans.Event)
+		if !trans.keepsState() {
+			maxState = max(maxState, int64(trans.To))
 		}
+		maxEvent = max(maxEvent, int64(trans.Event))
 	}
-	return maxStates + 1, maxEvents + 1
+	return maxState + 1, maxEvent + 1
 }
+
+// PrintTable 打印状态转移表的所有单元格，状态和事件以String的结果显示
 func (t *ArrayTransitionTable) PrintTable() {
 	fmt.Println("Transition Table:")
 	fmt.Println("From\tEvent\tTo")
@@ -108,88 +359,588 @@ func (t *ArrayTransitionTable) PrintTable() {
 		from := State(int32(i) / t.maxEvents)
 		event := Event(int32(i) % t.maxEvents)
 		to := t.table[i]
-		fmt.Printf("%d\t%d\t%d\n", from, event, to)
+		fmt.Printf("%v\t%v\t%v\n", from, event, to)
 	}
 }
 
 // RegisterCallback 注册回调函数
+// BeforeEvent/AfterEvent回调按(state, event)注册；LeaveState/EnterState回调只按state注册，
+// event参数会被忽略，即同一状态的离开/进入回调对所有事件都生效。
+// 同一位置可以注册多个回调，触发时按注册顺序依次执行；某个回调panic时后面的回调不再执行。
+// 需要替换已有的回调时使用SetCallback，需要在误用时得到错误提示可以使用RegisterCallbackChecked
 func (t *ArrayTransitionTable) RegisterCallback(cbType CallbackType, state State, event Event, handler Handler) {
+	if index, _, ok := t.callbackIndex(cbType, state, event); ok {
+		slot := &t.handlers(cbType)[index]
+		*slot = chainHandlers(*slot, handler)
+	}
+}
+
+// SetCallback 设置回调函数，替换同一位置已经注册的所有Handler，传入nil表示取消
+// 同一位置的ContextHandler和ErrHandler不受影响
+func (t *ArrayTransitionTable) SetCallback(cbType CallbackType, state State, event Event, handler Handler) {
+	if index, _, ok := t.callbackIndex(cbType, state, event); ok {
+		t.handlers(cbType)[index] = handler
+	}
+}
+
+// ClearCallbacks 移除同一位置注册的所有回调，包括Handler、ContextHandler和ErrHandler
+func (t *ArrayTransitionTable) ClearCallbacks(cbType CallbackType, state State, event Event) {
+	index, _, ok := t.callbackIndex(cbType, state, event)
+	if !ok {
+		return
+	}
+	t.handlers(cbType)[index] = nil
+	if handlers := t.ctxCallbacks[cbType]; index < int32(len(handlers)) {
+		handlers[index] = nil
+	}
+	if handlers := t.errCallbacks[cbType]; index < int32(len(handlers)) {
+		handlers[index] = nil
+	}
+}
+
+// RegisterGlobalCallback 注册对所有转移生效的全局回调，未知的回调类型被忽略
+// 全局回调在同一阶段的具体回调之前执行，多次注册时按注册顺序依次执行；
+// 有父状态时，LeaveState/EnterState全局回调对沿层次结构离开/进入的每个状态各执行一次
+func (t *ArrayTransitionTable) RegisterGlobalCallback(cbType CallbackType, handler Handler) {
+	if cbType < 0 || int(cbType) >= len(t.globals) {
+		return
+	}
+	t.globals[cbType] = chainHandlers(t.globals[cbType], handler)
+}
+
+// RegisterGlobalStateCallback 注册进入或离开任意状态时执行的全局回调，cbType只能是EnterState或LeaveState，
+// 其他类型返回ErrInvalidCallback。与RegisterGlobalCallback注册到同一位置，执行顺序也相同：
+// 在该状态自己的LeaveState/EnterState回调之前执行，之后新增的状态同样生效。
+// 回调的from和to即离开和进入的状态，适合统一记录"进入了状态X"之类的日志
+func (t *ArrayTransitionTable) RegisterGlobalStateCallback(cbType CallbackType, handler Handler) error {
+	if cbType != LeaveState && cbType != EnterState {
+		return fmt.Errorf("%w: global state callback type %d must be LeaveState or EnterState", ErrInvalidCallback, cbType)
+	}
+	t.RegisterGlobalCallback(cbType, handler)
+	return nil
+}
+
+// GetGlobalCallback 获取指定类型的全局回调，没有注册时返回nil
+func (t *ArrayTransitionTable) GetGlobalCallback(cbType CallbackType) Handler {
+	if cbType < 0 || int(cbType) >= len(t.globals) {
+		return nil
+	}
+	return t.globals[cbType]
+}
+
+// chainHandlers 将next追加到first之后，返回依次执行两者的回调
+// 同一位置只有一个回调时不做包装，调用开销与单个回调相同
+func chainHandlers(first, next Handler) Handler {
+	if first == nil {
+		return next
+	}
+	if next == nil {
+		return first
+	}
+	return func(fsm *FSM, from State, to State, event Event, args ...any) {
+		first(fsm, from, to, event, args...)
+		next(fsm, from, to, event, args...)
+	}
+}
+
+// chainContextHandlers 与chainHandlers相同，用于ContextHandler
+func chainContextHandlers(first, next ContextHandler) ContextHandler {
+	if first == nil {
+		return next
+	}
+	if next == nil {
+		return first
+	}
+	return func(tc *TransitionContext) {
+		first(tc)
+		next(tc)
+	}
+}
+
+// chainErrHandlers 与chainHandlers相同，用于ErrHandler；first返回错误时不再执行next
+func chainErrHandlers(first, next ErrHandler) ErrHandler {
+	if first == nil {
+		return next
+	}
+	if next == nil {
+		return first
+	}
+	return func(fsm *FSM, from State, to State, event Event, args ...any) error {
+		if err := first(fsm, from, to, event, args...); err != nil {
+			return err
+		}
+		return next(fsm, from, to, event, args...)
+	}
+}
+
+// handlers 获取指定类型回调的存储数组
+func (t *ArrayTransitionTable) handlers(cbType CallbackType) []Handler {
 	switch cbType {
 	case BeforeEvent:
-		index := int32(state)*t.maxEvents + int32(event)
-		if index < int32(len(t.beforeEvents)) {
-			t.beforeEvents[index] = handler
-		}
+		return t.beforeEvents
 	case AfterEvent:
-		index := int32(state)*t.maxEvents + int32(event)
-		if index < int32(len(t.afterEvents)) {
-			t.afterEvents[index] = handler
-		}
+		return t.afterEvents
 	case LeaveState:
-		if int32(state) < int32(len(t.leaveStates)) {
-			t.leaveStates[state] = handler
-		}
+		return t.leaveStates
 	case EnterState:
-		if int32(state) < int32(len(t.enterStates)) {
-			t.enterStates[state] = handler
+		return t.enterStates
+	}
+	return nil
+}
+
+// cellIndex 计算(state, event)在状态数组中的下标，超出表的范围时ok为false
+func (t *ArrayTransitionTable) cellIndex(state State, event Event) (index int32, ok bool) {
+	if state < 0 || event < 0 || int32(state) >= t.maxStates || int32(event) >= t.maxEvents {
+		return -1, false
+	}
+	return int32(state)*t.maxEvents + int32(event), true
+}
+
+// callbackIndex 计算指定类型回调在回调数组中的下标，以及该类型回调数组的长度
+// Before/After事件回调按(state, event)存储，Leave/Enter状态回调仅按state存储；
+// 回调类型未知（此时size为0）或超出表的范围时ok为false
+func (t *ArrayTransitionTable) callbackIndex(cbType CallbackType, state State, event Event) (index int32, size int32, ok bool) {
+	switch cbType {
+	case BeforeEvent, AfterEvent:
+		index, ok = t.cellIndex(state, event)
+		return index, t.maxStates * t.maxEvents, ok
+	case LeaveState, EnterState:
+		if state < 0 || int32(state) >= t.maxStates {
+			return -1, t.maxStates, false
 		}
+		return int32(state), t.maxStates, true
+	}
+	return -1, 0, false
+}
+
+// RegisterContextCallback 注册以TransitionContext为参数的回调函数
+// 同一位置的Handler和ContextHandler互不覆盖，触发时先执行Handler，再执行ContextHandler；
+// 与RegisterCallback一样，同一位置可以注册多个ContextHandler，按注册顺序依次执行
+func (t *ArrayTransitionTable) RegisterContextCallback(cbType CallbackType, state State, event Event, handler ContextHandler) {
+	index, size, ok := t.callbackIndex(cbType, state, event)
+	if !ok {
+		return
+	}
+	if t.ctxCallbacks[cbType] == nil {
+		t.ctxCallbacks[cbType] = make([]ContextHandler, size)
+	}
+	slot := &t.ctxCallbacks[cbType][index]
+	*slot = chainContextHandlers(*slot, handler)
+}
+
+// GetContextCallback 获取以TransitionContext为参数的回调函数
+func (t *ArrayTransitionTable) GetContextCallback(cbType CallbackType, state State, event Event) ContextHandler {
+	index, ok := t.resolveCallback(cbType, state, event)
+	if !ok {
+		return nil
+	}
+	handlers := t.ctxCallbacks[cbType]
+	if index < int32(len(handlers)) {
+		return handlers[index]
+	}
+	return nil
+}
+
+// RegisterErrCallback 注册可以中止转移的回调函数，cbType只能是BeforeEvent或LeaveState，其他类型被忽略
+// 同一位置的Handler、ContextHandler和ErrHandler互不覆盖，ErrHandler最后执行；
+// 同一位置可以注册多个ErrHandler，按注册顺序依次执行，某个ErrHandler返回错误时后面的不再执行
+func (t *ArrayTransitionTable) RegisterErrCallback(cbType CallbackType, state State, event Event, handler ErrHandler) {
+	if cbType != BeforeEvent && cbType != LeaveState {
+		return
+	}
+	index, size, ok := t.callbackIndex(cbType, state, event)
+	if !ok {
+		return
+	}
+	if t.errCallbacks[cbType] == nil {
+		t.errCallbacks[cbType] = make([]ErrHandler, size)
+	}
+	slot := &t.errCallbacks[cbType][index]
+	*slot = chainErrHandlers(*slot, handler)
+}
+
+// GetErrCallback 获取可以中止转移的回调函数
+func (t *ArrayTransitionTable) GetErrCallback(cbType CallbackType, state State, event Event) ErrHandler {
+	index, ok := t.resolveCallback(cbType, state, event)
+	if !ok {
+		return nil
+	}
+	handlers := t.errCallbacks[cbType]
+	if index < int32(len(handlers)) {
+		return handlers[index]
+	}
+	return nil
+}
+
+// RegisterRejectHandler 注册state状态下事件被拒绝时的回调，传入nil表示取消
+// 只有当前状态下没有转移规则的事件才会触发该回调；被守卫否决、被冻结或被取消的事件不会触发。
+// 回调在不持有Event锁的情况下执行，可能被多个goroutine并发调用
+func (t *ArrayTransitionTable) RegisterRejectHandler(state State, handler RejectHandler) {
+	if state < 0 || int32(state) >= t.maxStates {
+		return
+	}
+	if t.rejects == nil {
+		t.rejects = make([]RejectHandler, t.maxStates)
 	}
+	t.rejects[state] = handler
+}
+
+// GetRejectHandler 获取state状态下事件被拒绝时的回调
+func (t *ArrayTransitionTable) GetRejectHandler(state State) RejectHandler {
+	if state < 0 || int(state) >= len(t.rejects) {
+		return nil
+	}
+	return t.rejects[state]
 }
 
 // GetNextState 获取下一个状态
 func (t *ArrayTransitionTable) GetNextState(from State, event Event) (State, bool) {
-	index := int32(from)*t.maxEvents + int32(event)
-	if index >= int32(len(t.table)) || t.table[index] == StateInInit {
+	index, ok := t.cellIndex(from, event)
+	if !ok {
 		return StateInInit, false
 	}
-	return t.table[index], true
+	// 原子读取，允许与AddTransition/RemoveTransition并发
+	next := State(atomic.LoadInt32((*int32)(&t.table[index])))
+	if next == StateInInit && t.parents != nil {
+		// 自身没有规则时沿父状态链查找
+		index, _ = t.resolve(from, event)
+		next = State(atomic.LoadInt32((*int32)(&t.table[index])))
+	}
+	return checkNext(next)
+}
+
+// AddTransition 在运行时添加或覆盖一条转移规则，可以用于增量构建状态机或热修复规则
+// 表的大小在构造时就已确定，(from, event)或to超出范围时返回ErrOutOfRange而不是静默忽略，
+// to为StateInInit时返回ErrInvalidState。
+// 修改可以与Trigger并发进行：正在执行的转移使用修改前的规则，之后的触发立即看到新规则。
+// 分析与导出（TerminalSet、Transitions、ToDOT等）以及Compile不应与修改并发调用
+func (t *ArrayTransitionTable) AddTransition(from State, event Event, to State) error {
+	if to == StateInInit {
+		return fmt.Errorf("%w: transition %v --%v--> %v uses reserved StateInInit", ErrInvalidState, from, event, to)
+	}
+	index, ok := t.cellIndex(from, event)
+	if !ok || to < 0 || int32(to) >= t.maxStates {
+		return fmt.Errorf("%w: transition %v --%v--> %v outside %d states x %d events",
+			ErrOutOfRange, from, event, to, t.maxStates, t.maxEvents)
+	}
+	t.setCell(index, to)
+	return nil
+}
+
+// RemoveTransition 在运行时删除一条转移规则，之后该事件在from状态下被拒绝
+// 返回是否删除了已有的规则，(from, event)超出范围或本来就没有规则时返回false
+func (t *ArrayTransitionTable) RemoveTransition(from State, event Event) bool {
+	index, ok := t.cellIndex(from, event)
+	if !ok || State(atomic.LoadInt32((*int32)(&t.table[index]))) == StateInInit {
+		return false
+	}
+	t.setCell(index, StateInInit)
+	return true
+}
+
+// Clone 深拷贝状态转移表，得到一个可以独立修改的副本
+// 复制转移规则、接受并忽略的标记、回调、守卫、拒绝回调、事件优先级和父状态，之后对任意一方的
+// AddTransition/RemoveTransition、回调注册等修改都不会影响另一方；回调函数本身以及它们捕获的变量仍然共享。
+// 适合从共享的模板派生出个别规则不同的变体，例如A/B测试或按租户定制。分析结果的缓存不会被复制
+func (t *ArrayTransitionTable) Clone() *ArrayTransitionTable {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clone := &ArrayTransitionTable{
+		maxStates:    t.maxStates,
+		maxEvents:    t.maxEvents,
+		table:        make([]State, len(t.table)),
+		beforeEvents: slices.Clone(t.beforeEvents),
+		afterEvents:  slices.Clone(t.afterEvents),
+		leaveStates:  slices.Clone(t.leaveStates),
+		enterStates:  slices.Clone(t.enterStates),
+		consumed:     cloneFlags(t.consumed),
+		internal:     cloneFlags(t.internal),
+		priorities:   slices.Clone(t.priorities),
+		guards:       slices.Clone(t.guards),
+		rejects:      slices.Clone(t.rejects),
+		parents:      slices.Clone(t.parents),
+		globals:      t.globals,
+	}
+	// 单元格可能正在被并发的Trigger读取，与AddTransition一样按原子操作访问
+	for i := range t.table {
+		clone.table[i] = State(atomic.LoadInt32((*int32)(&t.table[i])))
+	}
+	for i := range t.ctxCallbacks {
+		clone.ctxCallbacks[i] = slices.Clone(t.ctxCallbacks[i])
+		clone.errCallbacks[i] = slices.Clone(t.errCallbacks[i])
+	}
+	return clone
+}
+
+// setCell 修改单元格并丢弃分析缓存，新规则总是普通转移而不是接受并忽略或内部转移
+func (t *ArrayTransitionTable) setCell(index int32, to State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.consumed != nil {
+		t.consumed[index].Store(false)
+	}
+	if t.internal != nil {
+		t.internal[index].Store(false)
+	}
+	atomic.StoreInt32((*int32)(&t.table[index]), int32(to))
+	t.cache.invalidate()
+}
+
+// IsConsumed 判断事件在指定状态下是否被标记为接受并忽略
+func (t *ArrayTransitionTable) IsConsumed(from State, event Event) bool {
+	index, ok := t.resolve(from, event)
+	return ok && flagAt(t.consumed, index)
+}
+
+// IsInternal 判断事件在指定状态下是否是内部转移
+func (t *ArrayTransitionTable) IsInternal(from State, event Event) bool {
+	index, ok := t.resolve(from, event)
+	return ok && flagAt(t.internal, index)
+}
+
+// NumStates 获取表中状态的数量，即构造时的maxStates
+func (t *ArrayTransitionTable) NumStates() int {
+	return int(t.maxStates)
 }
 
 // GetCallback 获取回调函数
 func (t *ArrayTransitionTable) GetCallback(cbType CallbackType, state State, event Event) Handler {
-	switch cbType {
-	case BeforeEvent:
-		index := int32(state)*t.maxEvents + int32(event)
-		if index < int32(len(t.beforeEvents)) {
-			return t.beforeEvents[index]
-		}
-	case AfterEvent:
-		index := int32(state)*t.maxEvents + int32(event)
-		if index < int32(len(t.afterEvents)) {
-			return t.afterEvents[index]
-		}
-	case LeaveState:
-		if int32(state) < int32(len(t.leaveStates)) {
-			return t.leaveStates[state]
-		}
-	case EnterState:
-		if int32(state) < int32(len(t.enterStates)) {
-			return t.enterStates[state]
-		}
+	if index, ok := t.resolveCallback(cbType, state, event); ok {
+		return t.handlers(cbType)[index]
 	}
 	return nil
 }
 
 // FSM 有限状态机实例
 type FSM struct {
-	id              uint32 // 状态机ID，用于标识
-	state           int32  // 使用int32保证原子操作
-	transitionTable *ArrayTransitionTable
-	eventLock       sync.Mutex // Event锁
+	id               uint32 // 状态机ID，用于标识
+	token            uint64 // 进程内唯一的标识，转移时被编码进调用栈，用于识别重入触发
+	state            int32  // 使用int32保证原子操作
+	statePtr         *int32 // 实际存放状态的位置，默认指向state，NewFSMAt可指定为外部地址
+	transitionTable  TransitionTable
+	initialState     State                // 创建时的初始状态，池中的实例为池的初始状态
+	eventLock        sync.Mutex           // Event锁
+	casRetries       atomic.Int64         // CAS失败重试次数，用于衡量竞争程度
+	skipPreCheck     atomic.Bool          // 是否跳过加锁前的无锁预检查
+	seq              atomic.Uint64        // 成功转移的次数
+	enteredAt        atomic.Int64         // 进入当前状态的时间，见monotonicNow
+	pool             *FsmPool             // 所属的状态机池，独立创建的状态机为nil
+	poolIndex        int                  // 在所属状态机池中的槽位下标
+	syncPool         *SyncFsmPool         // 创建该实例的SyncFsmPool，其他实例为nil
+	pooled           atomic.Bool          // 是否已从所属状态机池中分配，在池锁保护下写入，可无锁读取
+	releasing        atomic.Bool          // 在本状态机的回调中被释放，等待转移结束后放回空闲列表，见finishRelease
+	consumedRejected atomic.Bool          // Trigger对被接受并忽略的事件是否返回false
+	listeners        []transitionListener // 实例级转移监听器，在Event锁保护下读写
+	queueLock        sync.Mutex           // 事件队列锁，与Event锁相互独立
+	queue            []postedEvent        // 通过Post投递、等待处理的事件
+	queueMode        QueueMode            // 事件队列模式
+	paused           atomic.Bool          // 是否被Pause冻结，在queueLock保护下写入
+	queueWhilePaused bool                 // 冻结期间是否将事件放入队列，在queueLock保护下读写
+	history          *historyRing         // 最近的转移记录，未开启时为nil，在Event锁保护下读写
+	strict           atomic.Bool          // 事件被拒绝时是否panic
+	attemptHook      atomic.Pointer[AttemptHook]
+	dataLock         sync.Mutex                  // 业务数据锁，与Event锁相互独立，回调中也可以读写业务数据
+	data             any                         // 调用方附加的业务数据
+	timeouts         map[State]timeoutRule       // 状态超时规则，未设置时为nil，在Event锁保护下读写
+	timer            *time.Timer                 // 当前状态的超时计时器，在Event锁保护下读写
+	timerGen         uint64                      // 计时器的代数，每次重新计时或停止计时时递增，在Event锁保护下读写
+	firing           atomic.Bool                 // 是否正在执行转移（持有Event锁），用于识别回调中的重入触发
+	reentrant        []postedEvent               // 回调中重入触发、等待当前转移完成后处理的事件，在queueLock保护下读写
+	reentrantCount   atomic.Int32                // len(reentrant)，供转移完成后无锁判断是否有重入触发的事件
+	deferred         []postedEvent               // 等待进入有效状态的延迟事件，在queueLock保护下读写
+	deferredLimit    int                         // 延迟队列的上限，0表示DefaultDeferredLimit，-1表示不限制，在queueLock保护下读写
+	deferredCount    atomic.Int32                // len(deferred)，供转移完成后无锁判断是否有延迟事件
+	deferrable       atomic.Pointer[eventSet]    // 被标记为可延迟的事件，写时复制，没有时为nil
+	observers        atomic.Pointer[[]Observer]  // 转移观察者，写时复制，没有观察者时为nil
+	metrics          atomic.Pointer[MetricsSink] // 指标接收方，未设置时为nil
+	trace            atomic.Pointer[TraceFunc]   // 转移追踪函数，未设置时为nil
+	lockFree         atomic.Bool                 // 是否为没有副作用的转移启用无锁快速路径
+	needsLock        atomic.Bool                 // 是否启用了实例级监听器、转移历史或超时规则，它们只能在Event锁内执行
+	exclusive        atomic.Bool                 // 是否有持锁的一方正在执行转移，期间无锁转移退回到持锁路径
+	lockFreeActive   atomic.Int32                // 正在进行的无锁转移数量
+	waitLock         sync.Mutex                  // 保护waiters，与Event锁相互独立
+	waiters          []*stateWaiter              // 正在WaitForState的调用
+	waiterCount      atomic.Int32                // len(waiters)，供转移时无锁判断是否有等待者
+	subscribeDrops   atomic.Uint64               // Subscribe的通道已满而丢弃的通知数量
+	panicPolicy      atomic.Int32                // PanicPolicy
+	recoverFunc      atomic.Pointer[RecoverFunc] // 恢复回调panic后调用的函数，未设置时为nil
+}
+
+// AttemptHook 每次触发事件时最先调用的钩子，state为调用时读取到的当前状态
+type AttemptHook func(state State, event Event)
+
+// transitionListener 实例级转移监听器，在每次成功转移的所有回调执行完之后调用，调用时持有Event锁
+type transitionListener func(tc TransitionContext)
+
+// addListener 注册实例级转移监听器
+func (f *FSM) addListener(listener transitionListener) {
+	f.eventLock.Lock()
+	defer f.eventLock.Unlock()
+	f.listeners = append(f.listeners, listener)
+	f.updateNeedsLock()
+}
+
+// clockBase 单调时钟的基准时间
+var clockBase = time.Now()
+
+// monotonicNow 获取相对clockBase的单调时间（纳秒），不受系统时间调整影响
+func monotonicNow() int64 {
+	return int64(time.Since(clockBase))
 }
 
 // NewFSM 创建新的状态机实例
-func NewFSM(id uint32, initialState State, transitionTable *ArrayTransitionTable) *FSM {
-	return &FSM{
-		state:           int32(initialState),
+func NewFSM(id uint32, initialState State, transitionTable TransitionTable) *FSM {
+	f := &FSM{}
+	f.init(id, initialState, transitionTable, monotonicNow())
+	return f
+}
+
+// NewFSMWithData 创建新的状态机实例，并附加业务数据，之后可以通过Data读取
+func NewFSMWithData(id uint32, initialState State, transitionTable TransitionTable, data any) *FSM {
+	f := NewFSM(id, initialState, transitionTable)
+	f.data = data
+	return f
+}
+
+// NewFSMs 批量创建共享同一状态转移表的状态机实例，ID依次为idBase, idBase+1, ...
+// 所有实例分配在一段连续的内存中，只需两次内存分配，适合自行管理生命周期的批量场景。
+// 与FsmPool不同，这里没有分配/释放机制；只要返回的切片或其中任一指针仍被引用，
+// 这段内存就不会被回收，指针始终有效。
+func NewFSMs(n int, idBase uint32, initialState State, transitionTable TransitionTable) []*FSM {
+	backing := make([]FSM, n)
+	fsms := make([]*FSM, n)
+	now := monotonicNow()
+	for i := range backing {
+		backing[i].init(idBase+uint32(i), initialState, transitionTable, now)
+		fsms[i] = &backing[i]
+	}
+	return fsms
+}
+
+// fsmTokens 为每个状态机分配互不相同的标识，见FSM.token
+var fsmTokens atomic.Uint64
+
+// init 初始化状态机，f必须已经位于其最终的内存地址
+func (f *FSM) init(id uint32, initialState State, transitionTable TransitionTable, now int64) {
+	f.id = id
+	f.token = fsmTokens.Add(1)
+	f.initialState = initialState
+	f.state = int32(initialState)
+	f.statePtr = &f.state
+	f.transitionTable = transitionTable
+	f.enteredAt.Store(now)
+}
+
+// NewFSMAt 创建使用外部原子状态字的状态机实例
+// statePtr由调用方提供，便于将状态字按缓存行对齐或填充到自定义结构体中以避免伪共享。
+// statePtr在状态机的整个生命周期内必须保持有效，且不能被多个状态机共享，
+// 调用方也不应绕过状态机直接写入该地址。
+func NewFSMAt(id uint32, initialState State, transitionTable TransitionTable, statePtr *int32) *FSM {
+	if statePtr == nil {
+		panic("NewFSMAt: statePtr must not be nil")
+	}
+	atomic.StoreInt32(statePtr, int32(initialState))
+	f := &FSM{
+		statePtr:        statePtr,
 		transitionTable: transitionTable,
 		id:              id,
+		token:           fsmTokens.Add(1),
+		initialState:    initialState,
 	}
+	f.enteredAt.Store(monotonicNow())
+	return f
 }
 
 // CurrentState 获取当前状态（原子读取）
 func (f *FSM) CurrentState() State {
-	return State(atomic.LoadInt32(&f.state))
+	return State(atomic.LoadInt32(f.statePtr))
+}
+
+// CloneDeep 复制出一个行为一致且相互独立的状态机实例
+// 复制当前状态、初始状态和全部实例级配置：SetSkipPreCheck、SetConsumedResult、SetStrict、SetLockFree、
+// SetAttemptHook、SetMetrics、SetTrace、AddObserver添加的观察者、SetPanicPolicy、SetRecover、SetHistorySize、
+// SetTimeout、SetQueueMode、SetQueueWhilePaused、SetDeferrable和SetDeferredLimit，超时从复制时开始重新计时。
+// 绑定到原实例的Subscribe订阅和Mirror不会复制；Pause冻结、事件队列、延迟队列和转移历史记录属于运行时状态，
+// 也不会复制，新实例总是处于未冻结、队列为空的状态。不可变的状态转移表在两者之间共享；
+// copyData不为nil时用它复制业务数据，否则两者共享同一个业务数据值（指针类型的数据会指向同一个对象）。
+// 转移次数、CAS重试次数等统计信息以及停留时长在新实例上重新开始计数。
+// 新实例总是使用自身内部的状态字，即使原实例是通过NewFSMAt创建的
+func (f *FSM) CloneDeep(newID uint32, copyData func(data any) any) *FSM {
+	f.eventLock.Lock()
+	defer f.eventLock.Unlock()
+
+	clone := NewFSM(newID, f.CurrentState(), f.transitionTable)
+	clone.initialState = f.initialState
+	clone.skipPreCheck.Store(f.skipPreCheck.Load())
+	clone.consumedRejected.Store(f.consumedRejected.Load())
+	clone.strict.Store(f.strict.Load())
+	clone.lockFree.Store(f.lockFree.Load())
+	clone.attemptHook.Store(f.attemptHook.Load())
+	clone.metrics.Store(f.metrics.Load())
+	clone.trace.Store(f.trace.Load())
+	clone.panicPolicy.Store(f.panicPolicy.Load())
+	clone.recoverFunc.Store(f.recoverFunc.Load())
+	if observers := f.observers.Load(); observers != nil {
+		// 订阅绑定在原实例上，只复制调用方添加的观察者
+		for _, o := range *observers {
+			if _, ok := o.(*subscription); !ok {
+				clone.AddObserver(o)
+			}
+		}
+	}
+	if f.history != nil {
+		clone.history = (*historyRing)(nil).reset(len(f.history.entries))
+	}
+	if f.timeouts != nil {
+		clone.timeouts = maps.Clone(f.timeouts)
+		clone.armTimeout(clone.CurrentState())
+	}
+	clone.updateNeedsLock()
+
+	f.queueLock.Lock()
+	clone.queueMode = f.queueMode
+	clone.queueWhilePaused = f.queueWhilePaused
+	clone.deferredLimit = f.deferredLimit
+	f.queueLock.Unlock()
+	clone.deferrable.Store(f.deferrable.Load())
+
+	clone.data = f.Data()
+	if copyData != nil {
+		clone.data = copyData(clone.data)
+	}
+	return clone
+}
+
+// Pool 获取状态机所属的状态机池，独立创建的状态机返回nil
+func (f *FSM) Pool() *FsmPool {
+	return f.pool
+}
+
+// ReturnToPool 将状态机释放回所属的状态机池，可配合defer使用
+// 独立创建的状态机或者已经被释放的状态机不做任何处理，返回false。
+// 可以在本状态机的回调中调用，此时实例在当前转移结束之后才被重置并放回空闲列表，见FsmPool.Release
+func (f *FSM) ReturnToPool() bool {
+	if f.pool == nil {
+		return false
+	}
+	return f.pool.TryRelease(f)
+}
+
+// TimeInState 获取状态机停留在当前状态的时长
+func (f *FSM) TimeInState() time.Duration {
+	return time.Duration(monotonicNow() - f.enteredAt.Load())
+}
+
+// EnteredAt 获取状态机进入当前状态的时间
+// 返回值携带单调时钟读数，与time.Since等配合使用时不受系统时间调整影响
+func (f *FSM) EnteredAt() time.Time {
+	return clockBase.Add(time.Duration(f.enteredAt.Load()))
+}
+
+// InitialState 获取状态机创建时的初始状态，池中的实例返回池的初始状态
+// 初始状态在状态机的整个生命周期内不变，Reset等操作不会修改它
+func (f *FSM) InitialState() State {
+	return f.initialState
 }
 
 // ID 获取状态机ID
@@ -197,116 +948,814 @@ func (f *FSM) ID() uint32 {
 	return f.id
 }
 
+// CanTrigger 判断当前状态下是否存在该事件的转移规则，不加锁、不改变状态，也不执行守卫和回调
+// 被Pause冻结的状态机总是返回false。可以与Trigger并发调用，但结果只是调用时的快照，
+// 随后触发时状态可能已经改变，守卫也仍可能否决
+func (f *FSM) CanTrigger(event Event) bool {
+	if f.paused.Load() {
+		return false
+	}
+	next, ok := f.transitionTable.GetNextState(f.CurrentState(), event)
+	if !ok {
+		return false
+	}
+	_, ok = checkNext(next)
+	return ok
+}
+
+// AvailableEvents 获取当前状态下所有定义了转移规则的事件，按升序排列，适合生成可用操作的菜单
+// 结果只是调用时的快照，不保证随后触发时仍然有效；守卫在运行时才会判断，这里不考虑。
+// 状态转移表不支持枚举事件（未实现EventsFromTable）时返回nil
+func (f *FSM) AvailableEvents() []Event {
+	if et, ok := f.transitionTable.(EventsFromTable); ok {
+		return et.EventsFrom(f.CurrentState())
+	}
+	return nil
+}
+
+// IsTerminal 判断当前状态是否为终态，即在任何事件下都没有转移规则，状态机不会再发生转移
+// 适合由监控方将到达终态的实例释放回池中或停止轮询。状态转移表实现了TerminalTable时使用其结果，
+// 否则在实现了EventsFromTable时以当前状态下没有可用事件作为判断；两者都未实现时返回false
+func (f *FSM) IsTerminal() bool {
+	state := f.CurrentState()
+	switch t := f.transitionTable.(type) {
+	case TerminalTable:
+		return t.IsTerminal(state)
+	case EventsFromTable:
+		return len(t.EventsFrom(state)) == 0
+	}
+	return false
+}
+
+// Data 获取状态机附加的业务数据，未设置时返回nil
+func (f *FSM) Data() any {
+	f.dataLock.Lock()
+	defer f.dataLock.Unlock()
+	return f.data
+}
+
+// SetData 设置状态机附加的业务数据，例如连接对象，回调中可以通过收到的*FSM取回
+// 业务数据使用独立的锁保护，可以与Trigger并发调用，也可以在回调中调用。
+// 状态机被释放回FsmPool时，业务数据会被重置为nil
+func (f *FSM) SetData(data any) {
+	f.dataLock.Lock()
+	defer f.dataLock.Unlock()
+	f.data = data
+}
+
+// TriggerResult 一次触发的详细结果
+type TriggerResult int
+
+const (
+	// Rejected 当前状态下没有该事件的转移规则，事件未被接受
+	Rejected TriggerResult = iota
+	// Consumed 事件被接受并忽略：状态不变，也没有执行任何回调
+	Consumed
+	// SelfTransitioned 执行了目标为自身的外部转移：状态不变，但完整地执行了回调，包括离开并重新进入该状态
+	SelfTransitioned
+	// Transitioned 执行了转移且状态发生了变化
+	Transitioned
+	// PausedRejected 状态机已被Pause冻结，事件未被接受
+	PausedRejected
+	// GuardRejected 存在转移规则，但被守卫否决，事件未被接受
+	GuardRejected
+	// Aborted BeforeEvent或LeaveState阶段的ErrHandler返回了错误，转移被中止，状态不变
+	Aborted
+	// Canceled 开始转移之前context已被取消或超时，事件未被接受
+	Canceled
+	// Queued 在回调中重入触发了同一个状态机，事件已放入队列，将在当前转移完成后处理
+	Queued
+	// InternalTransitioned 执行了内部转移（见Transition.Internal）：状态不变，只执行了BeforeEvent/AfterEvent回调
+	InternalTransitioned
+	// Deferred 事件被标记为可延迟且当前状态下没有转移规则，已放入延迟队列，见SetDeferrable
+	Deferred
+)
+
+// Accepted 事件是否被接受
+func (r TriggerResult) Accepted() bool {
+	switch r {
+	case Rejected, PausedRejected, GuardRejected, Aborted, Canceled:
+		return false
+	}
+	return true
+}
+
+// Changed 状态是否发生了变化
+func (r TriggerResult) Changed() bool {
+	return r == Transitioned
+}
+
 // Trigger 触发事件（原子状态切换）
+// 执行了转移（包括自转移）时返回true，事件被拒绝时返回false；
+// 被接受并忽略的事件默认返回true，可以通过SetConsumedResult修改。
+// 在回调中重入触发同一个状态机时，事件放入内部队列并立即返回true（TriggerDetailed返回Queued），
+// 在当前转移的所有回调执行完之后、外层Trigger返回之前，由外层调用所在的goroutine按入队顺序处理；
+// 这些事件的处理结果不会返回给调用方。需要区分这些情况时使用TriggerDetailed
 func (f *FSM) Trigger(event Event, args ...any) bool {
+	result, _, _, _ := f.trigger(context.Background(), event, args...)
+	if result == Consumed {
+		return !f.consumedRejected.Load()
+	}
+	return result.Accepted()
+}
+
+// TriggerDetailed 触发事件并返回详细结果，能够区分事件是否被接受以及状态是否发生了变化
+func (f *FSM) TriggerDetailed(event Event, args ...any) TriggerResult {
+	result, _, _, _ := f.trigger(context.Background(), event, args...)
+	return result
+}
+
+// TriggerCtx 触发事件，并将ctx传递给回调（见TransitionContext.Ctx）
+// 在获取Event锁之前和之后各检查一次ctx，已取消或超时则直接返回false，不执行任何回调。
+// 一旦开始执行回调，转移就会完整地执行下去，之后（包括CAS之后）不再检查ctx；
+// 耗时较长的回调应自行检查tc.Ctx以尽早返回
+func (f *FSM) TriggerCtx(ctx context.Context, event Event, args ...any) bool {
+	result, _, _, _ := f.trigger(ctx, event, args...)
+	if result == Consumed {
+		return !f.consumedRejected.Load()
+	}
+	return result.Accepted()
+}
+
+// TriggerE 触发事件，并返回中止转移的回调错误
+// bool的含义与Trigger相同；ErrHandler中止转移时返回false和该错误，
+// 状态机被冻结时返回false和ErrPaused，其他被拒绝的情况返回false和nil
+func (f *FSM) TriggerE(event Event, args ...any) (bool, error) {
+	result, _, _, err := f.trigger(context.Background(), event, args...)
+	switch result {
+	case Consumed:
+		return !f.consumedRejected.Load(), nil
+	case PausedRejected:
+		return false, ErrPaused
+	}
+	return result.Accepted(), err
+}
+
+// TriggerR 触发事件，并返回本次调用所执行的转移的源状态和目标状态
+// ok的含义与Trigger相同。from和to在Event锁内（无锁转移时由同一次CAS）确定，不受其他goroutine并发转移的影响，
+// 比在Trigger之后调用CurrentState更可靠。没有发生转移（事件被拒绝、接受并忽略、被中止等）时from和to都是
+// 当时的当前状态；在回调中重入触发而进入队列时，from和to都是调用时读取到的当前状态
+func (f *FSM) TriggerR(event Event, args ...any) (from State, to State, ok bool) {
+	result, from, to, _ := f.trigger(context.Background(), event, args...)
+	if result == Consumed {
+		return from, to, !f.consumedRejected.Load()
+	}
+	return from, to, result.Accepted()
+}
+
+// SetConsumedResult 设置Trigger对被接受并忽略的事件的返回值，默认为true（事件已被处理）
+func (f *FSM) SetConsumedResult(accepted bool) {
+	f.consumedRejected.Store(!accepted)
+}
+
+// trigger 触发事件的公共实现，同时返回本次调用的源状态和目标状态，见TriggerR
+func (f *FSM) trigger(ctx context.Context, event Event, args ...any) (TriggerResult, State, State, error) {
+	if hook := f.attemptHook.Load(); hook != nil {
+		(*hook)(f.CurrentState(), event)
+	}
+	// 回调中重入触发同一个状态机时，Event锁已被当前goroutine持有，
+	// 将事件放入重入队列，由外层的触发在当前转移完成后处理，避免死锁
+	if f.inOwnTransition() && f.deferReentrant(postedEvent{event: event, args: args}) {
+		current := f.CurrentState()
+		return Queued, current, current, nil
+	}
+	result, from, to, err := f.dispatch(ctx, event, args, false)
+	f.drainReentrant(ctx)
+	return result, from, to, err
+}
+
+// dispatch 完成一次触发的检查与转移，不处理重入队列，同时返回本次调用的源状态和目标状态
+// nested为true表示调用方已经约定了加锁顺序、允许在其他状态机的转移中触发（只用于Mirror）
+func (f *FSM) dispatch(ctx context.Context, event Event, args []any, nested bool) (TriggerResult, State, State, error) {
+	if err := ctx.Err(); err != nil {
+		current := f.CurrentState()
+		return Canceled, current, current, err
+	}
+	// 被冻结的状态机不接受任何事件
+	if f.paused.Load() && f.holdWhilePaused(event, args) {
+		current := f.CurrentState()
+		return PausedRejected, current, current, nil
+	}
+	// 没有副作用的转移直接通过CAS完成，见SetLockFree
+	if f.lockFree.Load() {
+		if result, from, to, ok := f.tryLockFree(event); ok {
+			return result, from, to, nil
+		}
+	}
 	// 先检查状态是否匹配，避免不必要的锁竞争
-	current := f.CurrentState()
-	if _, ok := f.transitionTable.GetNextState(current, event); !ok {
-		return false
+	if !f.skipPreCheck.Load() {
+		current := f.CurrentState()
+		if _, ok := f.transitionTable.GetNextState(current, event); !ok {
+			return f.reject(current, event, args), current, current, nil
+		}
 	}
-	// 通过判断调用栈确定是否迭代调用此函数，如果是，则需要跳过
-	if IsRecursiveCall() {
-		panic("FSM.Trigger dosen't support recursive call")
+	// 通过判断调用栈确定是否在另一个状态机的回调中嵌套触发，持有多把Event锁可能导致死锁；
+	// 同一个状态机的重入触发已经在trigger中放入重入队列，不会走到这里
+	if !nested && inTransition() {
+		panic(fmt.Errorf("%w: FSM %d triggered event %v from inside another transition's callback",
+			ErrReentrantTrigger, f.id, event))
 	}
-	f.eventLock.Lock()
-	defer f.eventLock.Unlock()
+	result, from, to, err := f.lockedFire(ctx, event, args)
+	if result == Rejected {
+		// 拒绝回调在释放锁之后执行
+		return f.reject(to, event, args), from, to, nil
+	}
+	return result, from, to, err
+}
+
+// lockedFire 持有Event锁执行一次转移，同时返回转移前后的状态
+// 持锁期间状态只会被本次转移改变，因此两者就是本次转移的源状态和目标状态
+func (f *FSM) lockedFire(ctx context.Context, event Event, args []any) (result TriggerResult, from, to State, err error) {
+	f.lockTransition()
+	defer f.unlockTransition()
+	f.firing.Store(true)
+	defer f.endFiring()
+	from = f.CurrentState()
+	// 等待锁的过程中context可能已经取消
+	if err := ctx.Err(); err != nil {
+		return Canceled, from, from, err
+	}
+	result, err = f.fire(ctx, event, args...)
+	return result, from, f.CurrentState(), err
+}
+
+// reject 处理被拒绝的事件：可延迟的事件放入延迟队列，否则先执行拒绝回调，严格模式下再panic
+func (f *FSM) reject(state State, event Event, args []any) TriggerResult {
+	if f.isDeferrable(event) && f.pushDeferred(event, args) {
+		return Deferred
+	}
+	if sink := f.metricsSink(); sink != nil {
+		sink.IncRejected(state, event)
+	}
+	if rt, ok := f.transitionTable.(RejectTable); ok {
+		if handler := rt.GetRejectHandler(state); handler != nil {
+			handler(f, state, event, args...)
+		}
+	}
+	if f.strict.Load() {
+		panic(fmt.Sprintf("FSM %d: unhandled event %v in state %v", f.id, event, state))
+	}
+	return Rejected
+}
+
+// SetAttemptHook 设置每次触发事件时最先调用的钩子，传入nil表示取消
+// 钩子在冻结检查、预检查和加锁之前执行，无论事件最终是否被接受都会调用，
+// 可以与转移监听器配合，记录完整的"尝试/接受"事件轨迹。
+// 钩子可能在多个goroutine上并发执行，且此时不持有Event锁，调用方需要自行保证并发安全
+func (f *FSM) SetAttemptHook(hook AttemptHook) {
+	if hook == nil {
+		f.attemptHook.Store(nil)
+		return
+	}
+	f.attemptHook.Store(&hook)
+}
+
+// SetStrict 设置是否开启严格模式，默认关闭
+// 严格模式下，当前状态没有对应转移规则的事件会直接panic而不是返回false，
+// 便于在开发和测试阶段尽早暴露逻辑错误，生产环境应保持关闭。
+// 被Pause冻结而拒绝的事件不受影响
+func (f *FSM) SetStrict(strict bool) {
+	f.strict.Store(strict)
+}
+
+// transitionPlan 通过校验、等待执行的一次转移
+type transitionPlan struct {
+	from     State
+	to       State
+	internal bool
+}
+
+// plan 校验current状态下的event：匹配转移规则、检查目标状态并执行守卫，调用方必须持有Event锁
+// 可以执行时返回转移计划和true；否则返回false和对应的结果（Rejected、GuardRejected或Consumed）。
+// 守卫只在这里执行，TriggerAll等先校验后提交的场景把计划交给firePlan，同一次触发不会重复执行守卫
+func (f *FSM) plan(current State, event Event, args []any) (transitionPlan, TriggerResult, bool) {
+	next, ok := f.transitionTable.GetNextState(current, event)
+	if !ok {
+		return transitionPlan{}, Rejected, false
+	}
+	// 即使自定义的状态转移表返回了ok，也绝不能进入StateInInit
+	nextState, ok := checkNext(next)
+	if !ok {
+		return transitionPlan{}, Rejected, false
+	}
+	// 守卫否决时不执行回调也不改变状态
+	if !f.guardAllows(current, event, args) {
+		if sink := f.metricsSink(); sink != nil {
+			sink.IncRejected(current, event)
+		}
+		return transitionPlan{}, GuardRejected, false
+	}
+	// 接受并忽略的事件：视为已处理，但不改变状态也不执行回调
+	if ct, ok := f.transitionTable.(ConsumeTable); ok && ct.IsConsumed(current, event) {
+		return transitionPlan{}, Consumed, false
+	}
+	// 内部转移：状态不变，只执行BeforeEvent/AfterEvent回调
+	if it, ok := f.transitionTable.(InternalTable); ok && it.IsInternal(current, event) {
+		return transitionPlan{from: current, to: current, internal: true}, InternalTransitioned, true
+	}
+	return transitionPlan{from: current, to: nextState}, Transitioned, true
+}
+
+// fire 执行一次状态转移及其回调，调用方必须持有Event锁
+// 只有结果为Aborted时才会返回ErrHandler的错误
+func (f *FSM) fire(ctx context.Context, event Event, args ...any) (TriggerResult, error) {
+	p, result, ok := f.plan(f.CurrentState(), event, args)
+	if !ok {
+		return result, nil
+	}
+	return f.firePlan(ctx, p, event, args)
+}
+
+// firePlan 按已经通过校验的计划执行转移及其回调，调用方必须持有Event锁，并且从计划生成起一直持有
+// 回调在以f的标识标记过的调用栈上执行，见runMarked
+func (f *FSM) firePlan(ctx context.Context, p transitionPlan, event Event, args []any) (result TriggerResult, err error) {
+	runTransition(f.token, func() {
+		result, err = f.runPlan(ctx, p, event, args)
+	})
+	return result, err
+}
+
+// runPlan 见firePlan
+func (f *FSM) runPlan(ctx context.Context, p transitionPlan, event Event, args []any) (TriggerResult, error) {
 	for {
-		// 再次检查状态是否匹配
-		current := f.CurrentState()
-		nextState, ok := f.transitionTable.GetNextState(current, event)
-		if !ok {
-			return false
+		current, nextState, internal := p.from, p.to, p.internal
+		tc := TransitionContext{
+			FSM:   f,
+			From:  current,
+			To:    nextState,
+			Event: event,
+			Args:  args,
+			Seq:   f.seq.Load() + 1,
+			Ctx:   ctx,
+		}
+		// 开始追踪，回调通过tc.Ctx看到追踪返回的上下文
+		var endSpan func()
+		if trace := f.trace.Load(); trace != nil {
+			tc.Ctx, endSpan = (*trace)(ctx, current, nextState, event)
 		}
 
-		// 执行before事件回调
-		if handler := f.transitionTable.GetCallback(BeforeEvent, current, event); handler != nil {
-			handler(f, current, nextState, event, args...)
+		// 执行before事件回调，ErrHandler返回错误时中止转移
+		if err := f.callback(BeforeEvent, current, &tc); err != nil {
+			if endSpan != nil {
+				endSpan()
+			}
+			return Aborted, err
 		}
 
-		// 执行leave状态回调
-		if handler := f.transitionTable.GetCallback(LeaveState, current, event); handler != nil {
-			handler(f, current, nextState, event, args...)
+		// 执行leave状态回调，有父状态时沿层次结构向上执行
+		if !internal {
+			if err := f.leaveBranch(current, nextState, &tc); err != nil {
+				if endSpan != nil {
+					endSpan()
+				}
+				return Aborted, err
+			}
 		}
 
 		// 使用CAS原子操作确保状态切换的原子性
-		if atomic.CompareAndSwapInt32(&f.state, int32(current), int32(nextState)) {
-			// 执行enter状态回调
-			if handler := f.transitionTable.GetCallback(EnterState, nextState, event); handler != nil {
-				handler(f, current, nextState, event, args...)
+		if atomic.CompareAndSwapInt32(f.statePtr, int32(current), int32(nextState)) {
+			f.seq.Add(1)
+			now := monotonicNow()
+			var entered int64
+			if !internal {
+				entered = f.enteredAt.Swap(now)
+			}
+			if f.history != nil {
+				f.history.add(HistoryEntry{Seq: tc.Seq, From: current, To: nextState, Event: event, At: time.Now()})
+			}
+			if f.timeouts != nil && !internal {
+				f.armTimeout(nextState)
+			}
+
+			// 执行enter状态回调，有父状态时沿层次结构向下执行
+			if !internal {
+				f.enterBranch(current, nextState, &tc)
 			}
 
 			// 执行after事件回调
-			if handler := f.transitionTable.GetCallback(AfterEvent, current, event); handler != nil {
-				handler(f, current, nextState, event, args...)
+			f.callback(AfterEvent, current, &tc)
+
+			// 通知实例级的转移监听器
+			for _, listener := range f.listeners {
+				listener(tc)
+			}
+			if !internal {
+				f.notifyWaiters(nextState)
 			}
+			f.notifyObservers(current, nextState, event)
+			if sink := f.metricsSink(); sink != nil {
+				// 内部转移没有离开当前状态，不计入停留时长
+				if !internal {
+					sink.ObserveStateDuration(current, time.Duration(now-entered))
+				}
+				sink.IncTransition(current, nextState, event)
+			}
+			if endSpan != nil {
+				endSpan()
+			}
+
+			if internal {
+				return InternalTransitioned, nil
+			}
+			if current == nextState {
+				return SelfTransitioned, nil
+			}
+			return Transitioned, nil
+		}
+		// 持锁期间无锁转移也被排斥（见lockTransition），回调执行之后状态不会再被改变，理论不会走到这里，
+		// BeforeEvent/LeaveState回调对每次成功的转移只执行一次。
+		// 只有调用方绕过状态机直接写入NewFSMAt的状态字时CAS才会失败，此时按新的状态重新执行整个流程
+		if endSpan != nil {
+			endSpan()
+		}
+		f.casRetries.Add(1)
+		var result TriggerResult
+		var ok bool
+		if p, result, ok = f.plan(f.CurrentState(), event, args); !ok {
+			return result, nil
+		}
+	}
+}
+
+// callback 执行指定阶段的回调，返回ErrHandler的错误，回调panic时按PanicPolicy处理
+// 已停止传播时不再执行任何回调
+func (f *FSM) callback(cbType CallbackType, state State, tc *TransitionContext) error {
+	if tc.stopped {
+		return nil
+	}
+	if PanicPolicy(f.panicPolicy.Load()) != PanicPropagate {
+		return f.recoverCallback(cbType, state, tc)
+	}
+	return f.runCallback(cbType, state, tc)
+}
 
-			return true
+// runCallback 依次执行指定阶段的Handler、ContextHandler和ErrHandler
+func (f *FSM) runCallback(cbType CallbackType, state State, tc *TransitionContext) error {
+	if gt, ok := f.transitionTable.(GlobalCallbackTable); ok {
+		if handler := gt.GetGlobalCallback(cbType); handler != nil {
+			handler(f, tc.From, tc.To, tc.Event, tc.Args...)
+		}
+	}
+	if handler := f.transitionTable.GetCallback(cbType, state, tc.Event); handler != nil {
+		handler(f, tc.From, tc.To, tc.Event, tc.Args...)
+	}
+	if ct, ok := f.transitionTable.(ContextCallbackTable); ok {
+		if handler := ct.GetContextCallback(cbType, state, tc.Event); handler != nil {
+			// 复制一份交给回调，避免回调持有的指针影响后续阶段
+			c := *tc
+			handler(&c)
+			tc.stopped = c.stopped
 		}
-		// 在有锁的情况下，理论不会走到这里。
-		// 但是，如果CAS失败，说明状态已被其他goroutine修改，需要重试
 	}
+	if et, ok := f.transitionTable.(ErrCallbackTable); ok && !tc.stopped {
+		if handler := et.GetErrCallback(cbType, state, tc.Event); handler != nil {
+			return handler(f, tc.From, tc.To, tc.Event, tc.Args...)
+		}
+	}
+	return nil
 }
 
+// Seq 获取状态机成功转移的次数
+func (f *FSM) Seq() uint64 {
+	return f.seq.Load()
+}
+
+// SetSkipPreCheck 设置是否跳过Trigger加锁前的无锁预检查
+// 预检查能让无效事件免于锁竞争，默认开启；对于绝大多数事件都能成功转移的低竞争场景，
+// 跳过预检查可以省去一次重复的状态表查询
+func (f *FSM) SetSkipPreCheck(skip bool) {
+	f.skipPreCheck.Store(skip)
+}
+
+// CASRetries 获取Trigger中CAS失败重试的累计次数，可作为竞争程度的参考指标
+// Reset、ResetWithCallbacks以及池重置实例时清零；TriggerSequence、TriggerAll的回滚不影响计数
+func (f *FSM) CASRetries() int64 {
+	return f.casRetries.Load()
+}
+
+// PoolResetMode 状态机池重置实例的时机
+type PoolResetMode int32
+
+const (
+	// ResetOnRelease 释放时立即重置，默认模式，空闲实例不再持有上一任使用者的任何资源
+	ResetOnRelease PoolResetMode = iota
+	// ResetOnAllocate 分配时才重置，释放后仍可以读取实例的最终状态（例如用于统计），直到被再次分配
+	ResetOnAllocate
+)
+
 // FsmPool 状态机对象池，用于管理大量状态机实例
 type FsmPool struct {
-	pool            []FSM
-	transitionTable *ArrayTransitionTable
+	chunks          atomic.Pointer[[][]FSM] // 按槽位下标顺序排列的实例块，扩容时追加新块，已有的块永不移动
+	transitionTable TransitionTable
+	initialState    State
+	resetMode       atomic.Int32 // PoolResetMode
 	mu              sync.Mutex
+	growable        bool // 耗尽时是否自动扩容，在mu保护下读写
+	historySize     int  // 每个实例保留的转移记录条数，0表示不记录，在mu保护下读写
 	freeIndices     []int
 	allocatedCount  int32
+	highWaterMark   int   // 已分配数量的峰值，在mu保护下读写
+	exhausted       int64 // 因没有空闲实例而未能满足分配请求的次数，在mu保护下读写
 }
 
+// minPoolGrowth 空池自动扩容时新增的实例数量
+const minPoolGrowth = 16
+
 // NewFsmPool 创建状态机池
-func NewFsmPool(size int, initialState State, transitionTable *ArrayTransitionTable) *FsmPool {
+func NewFsmPool(size int, initialState State, transitionTable TransitionTable) *FsmPool {
 	pool := &FsmPool{
-		pool:            make([]FSM, size),
 		transitionTable: transitionTable,
+		initialState:    initialState,
 		freeIndices:     make([]int, 0, size),
 	}
+	pool.chunks.Store(&[][]FSM{})
+	pool.addChunk(size)
+	return pool
+}
+
+// addChunk 追加一块新的实例并初始化，新实例的槽位下标紧接在已有实例之后，调用方必须持有池锁（构造时除外）
+// 新建块时复制块列表而不是原地追加，无锁读取块列表的一方总能看到一致的快照
+func (p *FsmPool) addChunk(size int) {
+	chunks := *p.chunks.Load()
+	base := p.size(chunks)
+	chunk := make([]FSM, size)
 
 	// 初始化所有状态机
-	for i := range pool.pool {
-		pool.pool[i] = FSM{
-			state:           int32(initialState),
-			transitionTable: transitionTable,
-			id:              uint32(i),
+	now := monotonicNow()
+	for i := range chunk {
+		chunk[i].init(uint32(base+i), p.initialState, p.transitionTable, now)
+		chunk[i].pool = p
+		chunk[i].poolIndex = base + i
+		chunk[i].history = (*historyRing)(nil).reset(p.historySize)
+		chunk[i].updateNeedsLock()
+		p.freeIndices = append(p.freeIndices, base+i)
+	}
+	grown := append(chunks[:len(chunks):len(chunks)], chunk)
+	p.chunks.Store(&grown)
+}
+
+// slot 获取指定槽位下标的实例，下标越界时返回nil
+func (p *FsmPool) slot(index int) *FSM {
+	if index < 0 {
+		return nil
+	}
+	for _, chunk := range *p.chunks.Load() {
+		if index < len(chunk) {
+			return &chunk[index]
 		}
-		pool.freeIndices = append(pool.freeIndices, i)
+		index -= len(chunk)
 	}
+	return nil
+}
 
-	return pool
+// size 计算块列表中的实例总数
+func (p *FsmPool) size(chunks [][]FSM) int {
+	n := 0
+	for _, chunk := range chunks {
+		n += len(chunk)
+	}
+	return n
 }
 
-// Allocate 从池中分配一个状态机实例
+// SetGrowable 设置池耗尽时是否自动扩容，默认关闭（耗尽时Allocate返回nil）
+// 扩容时追加一块与当前容量相同大小的新实例（容量翻倍），已有实例不会移动，
+// 之前分配出去的指针始终有效
+func (p *FsmPool) SetGrowable(growable bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.growable = growable
+}
+
+// Allocate 从池中分配一个状态机实例，池耗尽且未开启自动扩容时返回nil
 func (p *FsmPool) Allocate() *FSM {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	return p.allocateLocked()
+}
+
+// AllocateN 在一次加锁中分配至多n个状态机实例，池中空闲实例不足且未开启自动扩容时返回的数量少于n
+func (p *FsmPool) AllocateN(n int) []*FSM {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
+	limit := max(n, 0)
+	if !p.growable && limit > len(p.freeIndices) {
+		limit = len(p.freeIndices)
+		p.exhausted++
+	}
+	fsms := make([]*FSM, 0, limit)
+	for range limit {
+		fsms = append(fsms, p.allocateLocked())
+	}
+	return fsms
+}
+
+// allocateLocked 分配一个状态机实例，没有空闲实例时返回nil，调用方必须持有池锁
+func (p *FsmPool) allocateLocked() *FSM {
 	if len(p.freeIndices) == 0 {
-		return nil
+		if !p.growable {
+			p.exhausted++
+			return nil
+		}
+		growth := p.size(*p.chunks.Load())
+		if growth == 0 {
+			growth = minPoolGrowth
+		}
+		p.addChunk(growth)
 	}
 
 	index := p.freeIndices[len(p.freeIndices)-1]
 	p.freeIndices = p.freeIndices[:len(p.freeIndices)-1]
-	atomic.AddInt32(&p.allocatedCount, 1)
+	fsm := p.slot(index)
+	fsm.pooled.Store(true)
+	p.highWaterMark = max(p.highWaterMark, int(atomic.AddInt32(&p.allocatedCount, 1)))
 
-	return &p.pool[index]
+	if PoolResetMode(p.resetMode.Load()) == ResetOnAllocate {
+		fsm.reset(p.initialState, p.historySize)
+	}
+	return fsm
+}
+
+// SetHistorySize 为池中的每个实例开启最近size条转移记录，size<=0时关闭（默认关闭）
+// 设置立即作用于所有实例（包括已分配的实例，其已有记录会被丢弃）以及之后扩容得到的实例；
+// 实例被重置时转移历史会被清空，但缓冲区会被保留复用，不会重新分配
+func (p *FsmPool) SetHistorySize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.historySize = max(size, 0)
+	for _, chunk := range *p.chunks.Load() {
+		for i := range chunk {
+			chunk[i].SetHistorySize(size)
+		}
+	}
 }
 
+// SetResetMode 设置重置实例的时机，默认为ResetOnRelease
+// 重置会将实例恢复到池的初始状态，并清除业务数据、转移历史、超时规则、事件队列、冻结状态
+// 以及严格模式、钩子等实例级配置，复用的实例与新分配的实例没有区别。
+// 无论哪种模式，释放时业务数据都会被清除
+func (p *FsmPool) SetResetMode(mode PoolResetMode) {
+	p.resetMode.Store(int32(mode))
+}
+
+var (
+	// ErrForeignFSM 释放的实例不属于本池（包括nil和用NewFSM单独创建的实例）
+	ErrForeignFSM = errors.New("fsm does not belong to this pool")
+	// ErrDoubleRelease 释放的实例已经被释放过，尚未重新分配
+	ErrDoubleRelease = errors.New("fsm already released")
+)
+
 // Release 释放状态机实例回池中
-func (p *FsmPool) Release(fsm *FSM) {
+// 重复释放或释放不属于本池的实例时不做任何处理，返回ErrDoubleRelease或ErrForeignFSM，
+// 保证同一个槽位不会两次进入空闲列表而被同时分配给两个使用者。
+// 在实例自身的回调中释放时，实例立即被视为已释放（数据被清除，AllocatedCount随之减少），
+// 重置和放回空闲列表推迟到这次转移释放Event锁之后，转移期间重入触发、尚未处理的事件不再处理
+func (p *FsmPool) Release(fsm *FSM) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	return p.releaseLocked(fsm)
+}
 
-	// 找到FSM在池中的索引
-	for i := range p.pool {
-		if &p.pool[i] == fsm {
-			p.freeIndices = append(p.freeIndices, i)
-			atomic.AddInt32(&p.allocatedCount, -1)
-			// 清空数据
-			break
+// TryRelease 释放状态机实例回池中，返回是否确实释放了
+// 只有属于本池且处于已分配状态的实例才会被释放；nil、其他池的实例或已经释放过的实例返回false，
+// 适合不确定归属时在defer中防御性地释放
+func (p *FsmPool) TryRelease(fsm *FSM) bool {
+	return p.Release(fsm) == nil
+}
+
+// ReleaseAll 在一次加锁中释放一批状态机实例，返回实际释放的数量
+// 与TryRelease一样，nil、其他池的实例和已经释放过的实例会被跳过
+func (p *FsmPool) ReleaseAll(fsms []*FSM) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	released := 0
+	for _, fsm := range fsms {
+		if p.releaseLocked(fsm) == nil {
+			released++
 		}
 	}
+	return released
+}
+
+// releaseLocked 释放状态机实例回池中，调用方必须持有池锁
+// 不属于本池或者尚未分配的状态机不做任何处理，返回对应的错误
+func (p *FsmPool) releaseLocked(fsm *FSM) error {
+	// 根据实例上记录的槽位下标O(1)地定位，并确认该槽位确实就是这个实例
+	if fsm == nil || fsm.pool != p || p.slot(fsm.poolIndex) != fsm {
+		return ErrForeignFSM
+	}
+	if !fsm.pooled.Load() {
+		return fmt.Errorf("%w: FSM %d", ErrDoubleRelease, fsm.id)
+	}
+	fsm.pooled.Store(false)
+	atomic.AddInt32(&p.allocatedCount, -1)
+	// 清空数据，避免复用的实例带上一任使用者的业务数据
+	fsm.SetData(nil)
+	fsm.enteredAt.Store(monotonicNow())
+	if fsm.inOwnTransition() {
+		// 调用方持有该实例的Event锁，此时重置会死锁，放回空闲列表则可能在转移结束前被再次分配
+		fsm.releasing.Store(true)
+		return nil
+	}
+	p.freeLocked(fsm)
+	return nil
+}
+
+// freeLocked 将已释放的实例放回空闲列表，需要时重置，调用方必须持有池锁
+func (p *FsmPool) freeLocked(fsm *FSM) {
+	p.freeIndices = append(p.freeIndices, fsm.poolIndex)
+	if PoolResetMode(p.resetMode.Load()) == ResetOnRelease {
+		fsm.reset(p.initialState, p.historySize)
+	}
+}
+
+// finishRelease 完成在回调中发起的释放，调用方不持有Event锁，返回是否有需要完成的释放
+func (f *FSM) finishRelease() bool {
+	if !f.releasing.Load() {
+		return false
+	}
+	p := f.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !f.releasing.Swap(false) {
+		return false
+	}
+	p.freeLocked(f)
+	return true
+}
+
+// reset 将池中的实例恢复为刚创建时的样子，保留ID、所属的池和状态转移表
+// historySize>0时保留（容量相同时复用）一个清空的转移历史缓冲区，否则关闭历史记录。
+// 调用方必须保证此时没有其他goroutine在使用该实例
+func (f *FSM) reset(initialState State, historySize int) {
+	f.eventLock.Lock()
+	defer f.eventLock.Unlock()
+
+	f.initialState = initialState
+	atomic.StoreInt32(f.statePtr, int32(initialState))
+	f.enteredAt.Store(monotonicNow())
+	f.notifyWaiters(initialState)
+	f.seq.Store(0)
+	f.casRetries.Store(0)
+	f.skipPreCheck.Store(false)
+	f.consumedRejected.Store(false)
+	f.strict.Store(false)
+	f.lockFree.Store(false)
+	f.attemptHook.Store(nil)
+	f.metrics.Store(nil)
+	f.trace.Store(nil)
+	f.listeners = nil
+	f.dropObservers()
+	f.subscribeDrops.Store(0)
+	f.panicPolicy.Store(int32(PanicPropagate))
+	f.recoverFunc.Store(nil)
+	f.history = f.history.reset(historySize)
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	f.timerGen++
+	f.timeouts = nil
+	f.updateNeedsLock()
+
+	f.queueLock.Lock()
+	f.queue = nil
+	f.queueMode = QueueFIFO
+	f.paused.Store(false)
+	f.queueWhilePaused = false
+	f.reentrant = nil
+	f.reentrantCount.Store(0)
+	f.deferred = nil
+	f.deferredLimit = 0
+	f.deferredCount.Store(0)
+	f.deferrable.Store(nil)
+	f.queueLock.Unlock()
+
+	f.SetData(nil)
+}
+
+// PoolStats 状态机池的使用情况
+type PoolStats struct {
+	Size          int   // 池中实例的总数，开启自动扩容时包括扩容得到的实例
+	Allocated     int   // 已分配的实例数量
+	Free          int   // 空闲的实例数量
+	HighWaterMark int   // 池创建以来已分配数量的峰值
+	Exhausted     int64 // 因为没有空闲实例，Allocate返回nil或AllocateN返回数量不足的次数
+}
+
+// Stats 获取池的使用情况，在池锁内读取，各字段是同一时刻的一致快照
+// HighWaterMark接近Size或者Exhausted不为0说明池的容量偏小，可以据此调整池的大小
+func (p *FsmPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		Size:          p.size(*p.chunks.Load()),
+		Allocated:     p.AllocatedCount(),
+		Free:          len(p.freeIndices),
+		HighWaterMark: p.highWaterMark,
+		Exhausted:     p.exhausted,
+	}
 }
 
 // AllocatedCount 获取已分配的状态机数量
@@ -316,5 +1765,65 @@ func (p *FsmPool) AllocatedCount() int {
 
 // Size 获取池大小
 func (p *FsmPool) Size() int {
-	return len(p.pool)
+	return p.size(*p.chunks.Load())
+}
+
+// CountByState 统计已分配状态机在各状态上的数量
+// 统计期间持有池锁，返回结果是一致的快照：各状态数量之和等于该时刻的已分配数量。
+// 代价是统计期间Allocate/Release会被阻塞；高频轮询的场景可以使用CountByStateApprox
+func (p *FsmPool) CountByState() map[State]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.countByState()
+}
+
+// CountByStateApprox 无锁地统计已分配状态机在各状态上的数量
+// 统计期间发生的分配/释放可能被部分计入，各状态数量之和不保证等于AllocatedCount，
+// 适合对精确性要求不高的高频监控
+func (p *FsmPool) CountByStateApprox() map[State]int {
+	return p.countByState()
+}
+
+// ForEachAllocated 按槽位下标顺序遍历池中当前所有已分配的状态机，fn返回false时停止
+// 已分配的实例在池锁内一次性取得快照，fn在释放池锁之后调用，其中可以正常分配、释放实例或触发事件；
+// 快照之后才分配的实例不会被遍历，遍历期间被释放的实例仍会交给fn
+func (p *FsmPool) ForEachAllocated(fn func(fsm *FSM) bool) {
+	for _, f := range p.allocated() {
+		if !fn(f) {
+			return
+		}
+	}
+}
+
+// StateCounts 统计已分配状态机在各状态上的数量，得到当前状态的分布，与CountByState完全相同
+// 一致性和代价见CountByState，高频轮询时使用CountByStateApprox；需要按状态之外的维度聚合时使用ForEachAllocated
+func (p *FsmPool) StateCounts() map[State]int {
+	return p.CountByState()
+}
+
+// allocated 在池锁内获取当前所有已分配实例的快照，按槽位下标排列
+func (p *FsmPool) allocated() []*FSM {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fsms := make([]*FSM, 0, p.AllocatedCount())
+	for _, chunk := range *p.chunks.Load() {
+		for i := range chunk {
+			if chunk[i].pooled.Load() {
+				fsms = append(fsms, &chunk[i])
+			}
+		}
+	}
+	return fsms
+}
+
+func (p *FsmPool) countByState() map[State]int {
+	counts := make(map[State]int)
+	for _, chunk := range *p.chunks.Load() {
+		for i := range chunk {
+			if chunk[i].pooled.Load() {
+				counts[chunk[i].CurrentState()]++
+			}
+		}
+	}
+	return counts
 }
@@ -1,8 +1,16 @@
 package fsm_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	fsm "github.com/cuitpanfei/lowgcfsm"
 )
@@ -143,6 +151,534 @@ func TestCallbacks(t *testing.T) {
 	}
 }
 
+// 测试以TransitionContext为参数的回调函数
+func TestContextCallbacks(t *testing.T) {
+	table := createTestTransitionTable()
+	fsmInstance := fsm.NewFSM(0, StateIdle, table)
+
+	var legacyCalled bool
+	var got []fsm.TransitionContext
+	table.RegisterCallback(fsm.AfterEvent, StateIdle, EventStart, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		legacyCalled = true
+	})
+	table.RegisterContextCallback(fsm.AfterEvent, StateIdle, EventStart, func(tc *fsm.TransitionContext) {
+		if !legacyCalled {
+			t.Error("Expected legacy handler to run before context handler")
+		}
+		got = append(got, *tc)
+	})
+	table.RegisterContextCallback(fsm.BeforeEvent, StateRunning, EventPause, func(tc *fsm.TransitionContext) {
+		got = append(got, *tc)
+	})
+
+	fsmInstance.Trigger(EventStart, "job-1")
+	fsmInstance.Trigger(EventPause)
+
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 context callbacks, got %d", len(got))
+	}
+	first := got[0]
+	if first.FSM != fsmInstance || first.From != StateIdle || first.To != StateRunning || first.Event != EventStart {
+		t.Errorf("Unexpected transition context: %+v", first)
+	}
+	if len(first.Args) != 1 || first.Args[0] != "job-1" || first.Ctx == nil {
+		t.Errorf("Unexpected args or ctx: %+v", first)
+	}
+	if first.Seq != 1 || got[1].Seq != 2 {
+		t.Errorf("Expected sequences 1 and 2, got %d and %d", first.Seq, got[1].Seq)
+	}
+	if fsmInstance.Seq() != 2 {
+		t.Errorf("Expected FSM seq 2, got %d", fsmInstance.Seq())
+	}
+}
+
+// 测试同一位置注册多个回调
+func TestMultipleCallbacks(t *testing.T) {
+	table := createTestTransitionTable()
+	var calls []string
+	record := func(name string) fsm.Handler {
+		return func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) { calls = append(calls, name) }
+	}
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, record("log"))
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, record("business"))
+	table.RegisterCallback(fsm.BeforeEvent, StateIdle, EventStart, record("before"))
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.Trigger(EventStart)
+	if want := []string{"before", "log", "business"}; !slices.Equal(calls, want) {
+		t.Errorf("Expected %v, got %v", want, calls)
+	}
+
+	// SetCallback替换同一位置的所有回调，ClearCallbacks移除所有回调
+	table.SetCallback(fsm.EnterState, StateRunning, 0, record("only"))
+	table.ClearCallbacks(fsm.BeforeEvent, StatePaused, EventResume)
+	calls = nil
+	f.Trigger(EventPause)
+	f.Trigger(EventResume)
+	if want := []string{"only"}; !slices.Equal(calls, want) {
+		t.Errorf("Expected %v, got %v", want, calls)
+	}
+	table.ClearCallbacks(fsm.EnterState, StateRunning, 0)
+	if table.GetCallback(fsm.EnterState, StateRunning, 0) != nil {
+		t.Error("Expected callbacks to be cleared")
+	}
+
+	// 其他状态转移表的RegisterCallback同样按注册顺序追加
+	for name, other := range map[string]callbackTable{
+		"Map": fsm.NewMapTransitionTable(testTransitions),
+		"CSR": fsm.NewCSRTransitionTable(testTransitions),
+	} {
+		other.RegisterCallback(fsm.EnterState, StateRunning, 0, record("log"))
+		other.RegisterCallback(fsm.EnterState, StateRunning, 0, record("business"))
+		calls = nil
+		fsm.NewFSM(0, StateIdle, other).Trigger(EventStart)
+		if want := []string{"log", "business"}; !slices.Equal(calls, want) {
+			t.Errorf("%s: expected %v, got %v", name, want, calls)
+		}
+	}
+}
+
+// callbackTable 可以注册回调的状态转移表
+type callbackTable interface {
+	fsm.TransitionTable
+	RegisterCallback(cbType fsm.CallbackType, state fsm.State, event fsm.Event, handler fsm.Handler)
+}
+
+// 测试同一位置注册多个ContextHandler和ErrHandler
+func TestMultipleContextAndErrCallbacks(t *testing.T) {
+	table := createTestTransitionTable()
+	var calls []string
+	for _, name := range []string{"ctx1", "ctx2"} {
+		table.RegisterContextCallback(fsm.EnterState, StateRunning, 0, func(*fsm.TransitionContext) { calls = append(calls, name) })
+	}
+	refused := errors.New("refused")
+	table.RegisterErrCallback(fsm.BeforeEvent, StateRunning, EventStop, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) error {
+		calls = append(calls, "err1")
+		return refused
+	})
+	table.RegisterErrCallback(fsm.BeforeEvent, StateRunning, EventStop, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) error {
+		calls = append(calls, "err2")
+		return nil
+	})
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.Trigger(EventStart)
+	// 第一个ErrHandler中止转移，第二个不再执行
+	if _, err := f.TriggerE(EventStop); !errors.Is(err, refused) {
+		t.Errorf("Expected the first ErrHandler to abort, got %v", err)
+	}
+	if want := []string{"ctx1", "ctx2", "err1"}; !slices.Equal(calls, want) {
+		t.Errorf("Expected %v, got %v", want, calls)
+	}
+}
+
+// 测试对所有转移生效的全局回调
+func TestGlobalCallback(t *testing.T) {
+	table := createTestTransitionTable()
+	var calls []string
+	table.RegisterCallback(fsm.BeforeEvent, StateIdle, EventStart, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {
+		calls = append(calls, "specific")
+	})
+	table.RegisterGlobalCallback(fsm.BeforeEvent, func(_ *fsm.FSM, from, to fsm.State, _ fsm.Event, _ ...any) {
+		calls = append(calls, fmt.Sprintf("global:%d->%d", from, to))
+	})
+	table.RegisterGlobalCallback(fsm.CallbackType(42), func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {
+		t.Error("Unknown callback type should be ignored")
+	})
+
+	for name, tt := range map[string]fsm.TransitionTable{"Array": table, "Compiled": table.Compile()} {
+		calls = nil
+		f := fsm.NewFSM(0, StateIdle, tt)
+		f.Trigger(EventStart)
+		f.Trigger(EventPause)
+		if want := []string{"global:0->1", "specific", "global:1->2"}; !slices.Equal(calls, want) {
+			t.Errorf("%s: expected %v, got %v", name, want, calls)
+		}
+	}
+}
+
+// 测试进入或离开任意状态时执行的全局状态回调
+func TestGlobalStateCallback(t *testing.T) {
+	table := createTestTransitionTable()
+	var calls []string
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {
+		calls = append(calls, "enter running")
+	})
+	if err := table.RegisterGlobalStateCallback(fsm.EnterState, func(_ *fsm.FSM, _, to fsm.State, _ fsm.Event, _ ...any) {
+		calls = append(calls, fmt.Sprintf("enter %d", to))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.RegisterGlobalStateCallback(fsm.LeaveState, func(_ *fsm.FSM, from, _ fsm.State, _ fsm.Event, _ ...any) {
+		calls = append(calls, fmt.Sprintf("leave %d", from))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.RegisterGlobalStateCallback(fsm.BeforeEvent, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {}); !errors.Is(err, fsm.ErrInvalidCallback) {
+		t.Errorf("Expected ErrInvalidCallback for BeforeEvent, got %v", err)
+	}
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.Trigger(EventStart)
+	f.Trigger(EventStop)
+	want := []string{"leave 0", "enter 1", "enter running", "leave 1", "enter 3"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("Expected %v, got %v", want, calls)
+	}
+}
+
+// 测试停止回调传播
+func TestStopPropagation(t *testing.T) {
+	table := createTestTransitionTable()
+	fsmInstance := fsm.NewFSM(0, StateIdle, table)
+
+	var calls []fsm.CallbackType
+	record := func(cbType fsm.CallbackType) fsm.Handler {
+		return func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+			calls = append(calls, cbType)
+		}
+	}
+	table.RegisterCallback(fsm.BeforeEvent, StateIdle, EventStart, record(fsm.BeforeEvent))
+	table.RegisterCallback(fsm.LeaveState, StateIdle, EventStart, record(fsm.LeaveState))
+	table.RegisterCallback(fsm.EnterState, StateRunning, EventStart, record(fsm.EnterState))
+	table.RegisterContextCallback(fsm.LeaveState, StateIdle, EventStart, func(tc *fsm.TransitionContext) {
+		tc.StopPropagation()
+	})
+
+	if !fsmInstance.Trigger(EventStart) {
+		t.Error("Expected transition to commit after StopPropagation")
+	}
+	if fsmInstance.CurrentState() != StateRunning {
+		t.Errorf("Expected state %d, got %d", StateRunning, fsmInstance.CurrentState())
+	}
+	if len(calls) != 2 || calls[0] != fsm.BeforeEvent || calls[1] != fsm.LeaveState {
+		t.Errorf("Expected only before and leave callbacks, got %v", calls)
+	}
+}
+
+// 测试接受并忽略的事件
+func TestConsumeTransition(t *testing.T) {
+	transitions := []fsm.Transition{
+		{From: StateIdle, Event: EventStart, To: StateRunning},
+		{From: StateRunning, Event: EventStart, Consume: true},
+		{From: StateRunning, Event: EventPause, To: StateRunning},
+	}
+	table := fsm.NewArrayTransitionTable(transitions)
+	fsmInstance := fsm.NewFSM(0, StateRunning, table)
+
+	var calls int
+	countCalls := func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) { calls++ }
+	table.RegisterCallback(fsm.LeaveState, StateRunning, EventStart, countCalls)
+	table.RegisterCallback(fsm.BeforeEvent, StateRunning, EventStart, countCalls)
+
+	if next, ok := table.GetNextState(StateRunning, EventStart); !ok || next != StateRunning {
+		t.Errorf("Expected consumed event to keep state, got %d, %v", next, ok)
+	}
+	if !fsmInstance.Trigger(EventStart) {
+		t.Error("Expected consumed event to be accepted")
+	}
+	if calls != 0 || fsmInstance.Seq() != 0 {
+		t.Errorf("Expected no callbacks and no transition, got %d calls, seq %d", calls, fsmInstance.Seq())
+	}
+
+	// 自转移会执行完整的回调流程
+	if !fsmInstance.Trigger(EventPause) || calls != 1 || fsmInstance.Seq() != 1 {
+		t.Errorf("Expected self transition to run callbacks, got %d calls, seq %d", calls, fsmInstance.Seq())
+	}
+	if !table.Compile().IsConsumed(StateRunning, EventStart) || table.Compile().IsConsumed(StateRunning, EventPause) {
+		t.Error("Expected compiled table to keep consumed marks")
+	}
+}
+
+// 测试使用外部状态字的状态机
+func TestNewFSMAt(t *testing.T) {
+	var slot struct {
+		_     [64]byte
+		state int32
+		_     [60]byte
+	}
+	fsmInstance := fsm.NewFSMAt(0, StateIdle, createTestTransitionTable().Compile(), &slot.state)
+	if !fsmInstance.Trigger(EventStart) {
+		t.Error("Failed to trigger EventStart from StateIdle")
+	}
+	if slot.state != int32(StateRunning) || fsmInstance.CurrentState() != StateRunning {
+		t.Errorf("Expected external state %d, got %d", StateRunning, slot.state)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for nil state pointer")
+		}
+	}()
+	fsm.NewFSMAt(0, StateIdle, createTestTransitionTable(), nil)
+}
+
+// 测试当前状态停留时长
+func TestTimeInState(t *testing.T) {
+	table := createTestTransitionTable()
+	fsmInstance := fsm.NewFSM(0, StateIdle, table)
+
+	time.Sleep(10 * time.Millisecond)
+	if d := fsmInstance.TimeInState(); d < 10*time.Millisecond {
+		t.Errorf("Expected at least 10ms in initial state, got %v", d)
+	}
+
+	// 成功转移后重新计时，被拒绝的事件不影响计时
+	fsmInstance.Trigger(EventStart)
+	before := fsmInstance.TimeInState()
+	if before >= 10*time.Millisecond {
+		t.Errorf("Expected timer to restart after transition, got %v", before)
+	}
+	fsmInstance.Trigger(EventStart)
+	if fsmInstance.TimeInState() < before {
+		t.Error("Expected rejected event to keep the timer running")
+	}
+}
+
+// 测试触发结果在各种转移类型下的取值
+func TestTriggerDetailed(t *testing.T) {
+	transitions := []fsm.Transition{
+		{From: StateIdle, Event: EventStart, To: StateRunning},
+		{From: StateRunning, Event: EventStart, Consume: true},
+		{From: StateRunning, Event: EventPause, To: StateRunning},
+	}
+	table := fsm.NewArrayTransitionTable(transitions)
+
+	cases := []struct {
+		name     string
+		from     fsm.State
+		event    fsm.Event
+		result   fsm.TriggerResult
+		accepted bool
+		changed  bool
+	}{
+		{"transition", StateIdle, EventStart, fsm.Transitioned, true, true},
+		{"consume", StateRunning, EventStart, fsm.Consumed, true, false},
+		{"self", StateRunning, EventPause, fsm.SelfTransitioned, true, false},
+		{"rejected", StateRunning, EventStop, fsm.Rejected, false, false},
+	}
+	for _, c := range cases {
+		f := fsm.NewFSM(0, c.from, table)
+		result := f.TriggerDetailed(c.event)
+		if result != c.result || result.Accepted() != c.accepted || result.Changed() != c.changed {
+			t.Errorf("%s: got %d (accepted %v, changed %v)", c.name, result, result.Accepted(), result.Changed())
+		}
+
+		// Trigger对被接受并忽略的事件的返回值可配置，其他情况不受影响
+		for _, consumedOK := range []bool{true, false} {
+			f := fsm.NewFSM(0, c.from, table)
+			f.SetConsumedResult(consumedOK)
+			want := c.accepted
+			if c.result == fsm.Consumed {
+				want = consumedOK
+			}
+			if got := f.Trigger(c.event); got != want {
+				t.Errorf("%s with consumed=%v: Trigger() = %v, want %v", c.name, consumedOK, got, want)
+			}
+		}
+	}
+}
+
+// 测试TriggerR返回本次调用执行的转移
+func TestTriggerR(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateIdle, table)
+	if from, to, ok := f.TriggerR(EventStart); !ok || from != StateIdle || to != StateRunning {
+		t.Errorf("Expected Idle -> Running, got %v -> %v, %v", from, to, ok)
+	}
+	if from, to, ok := f.TriggerR(EventResume); ok || from != StateRunning || to != StateRunning {
+		t.Errorf("Expected rejected event to report Running, got %v -> %v, %v", from, to, ok)
+	}
+
+	// 并发转移时每次调用看到的都是自己执行的转移
+	ring := fsm.NewFSM(1, 0, createRingTable())
+	for _, lockFree := range []bool{false, true} {
+		ring.SetLockFree(lockFree)
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 500 {
+					from, to, ok := ring.TriggerR(0)
+					if !ok || to != (from+1)%3 {
+						t.Errorf("Expected a single ring step, got %v -> %v, %v", from, to, ok)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// 测试跳过预检查后的状态转移
+func TestSkipPreCheck(t *testing.T) {
+	table := createTestTransitionTable()
+	fsmInstance := fsm.NewFSM(0, StateIdle, table)
+	fsmInstance.SetSkipPreCheck(true)
+
+	if !fsmInstance.Trigger(EventStart) {
+		t.Error("Failed to trigger EventStart from StateIdle")
+	}
+	// 无效事件在锁内被拒绝
+	if fsmInstance.Trigger(EventStart) {
+		t.Error("Expected invalid transition from Running with EventStart")
+	}
+	if fsmInstance.CurrentState() != StateRunning {
+		t.Errorf("Expected state %d, got %d", StateRunning, fsmInstance.CurrentState())
+	}
+}
+
+// 测试复制状态机
+func TestCloneDeep(t *testing.T) {
+	table := createTestTransitionTable()
+	original := fsm.NewFSM(1, StateIdle, table)
+	original.SetSkipPreCheck(true)
+	original.SetHistorySize(4)
+	original.SetTimeout(StatePaused, time.Millisecond, EventStop)
+	var log []string
+	original.AddObserver(&recordingObserver{name: "o", log: &log})
+	events, unsubscribe := original.Subscribe(4)
+	defer unsubscribe()
+	original.SetData(&[]int{1})
+	original.Trigger(EventStart)
+
+	clone := original.CloneDeep(2, func(data any) any {
+		copied := slices.Clone(*data.(*[]int))
+		return &copied
+	})
+	if clone.ID() != 2 || clone.CurrentState() != StateRunning {
+		t.Errorf("Unexpected clone: id %d, state %d", clone.ID(), clone.CurrentState())
+	}
+	if clone.Seq() != 0 || len(clone.History()) != 0 {
+		t.Errorf("Expected clone statistics and history to restart, got seq %d", clone.Seq())
+	}
+	// 业务数据按copyData复制
+	(*clone.Data().(*[]int))[0] = 2
+	if (*original.Data().(*[]int))[0] != 1 {
+		t.Error("Expected clone data to be copied")
+	}
+
+	// 两者相互独立，实例级配置（包括超时规则）被复制，订阅不被复制
+	<-events
+	clone.Trigger(EventPause)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := clone.WaitForState(ctx, StateStopped); err != nil {
+		t.Fatalf("Expected copied timeout to fire on clone, got %v", err)
+	}
+	if original.CurrentState() != StateRunning {
+		t.Error("Expected clone to transition independently")
+	}
+	// History持有Event锁，返回时超时转移的观察者已经执行完毕
+	if history := clone.History(); len(history) != 2 || len(log) != 3 || len(events) != 0 {
+		t.Errorf("Expected clone to keep history and observers only, got %d entries, log %v, %d events",
+			len(history), log, len(events))
+	}
+}
+
+// 测试复制状态转移表后两者相互独立
+func TestArrayTransitionTableClone(t *testing.T) {
+	base := createTestTransitionTable()
+	var baseCalls, cloneCalls int
+	base.RegisterCallback(fsm.EnterState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		baseCalls++
+	})
+	base.RegisterGuard(StatePaused, EventStop, func(f *fsm.FSM, from fsm.State, event fsm.Event, args ...any) bool {
+		return false
+	})
+
+	variant := base.Clone()
+	if err := variant.AddTransition(StateIdle, EventStop, StateStopped); err != nil {
+		t.Fatal(err)
+	}
+	variant.RemoveTransition(StateRunning, EventPause)
+	variant.RegisterCallback(fsm.EnterState, StateStopped, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		cloneCalls++
+	})
+
+	if _, ok := base.GetNextState(StateIdle, EventStop); ok {
+		t.Error("Expected base table to be unaffected by the variant's new transition")
+	}
+	if _, ok := base.GetNextState(StateRunning, EventPause); !ok {
+		t.Error("Expected base table to keep the transition removed from the variant")
+	}
+	if base.GetCallback(fsm.EnterState, StateStopped, 0) != nil {
+		t.Error("Expected base table to be unaffected by the variant's callback")
+	}
+
+	// 复制时已有的回调和守卫在副本中同样生效
+	f := fsm.NewFSM(0, StateIdle, variant)
+	f.Trigger(EventStart)
+	if baseCalls != 1 {
+		t.Errorf("Expected copied enter callback to run, got %d calls", baseCalls)
+	}
+	if f.Trigger(EventPause) {
+		t.Error("Expected removed transition to be rejected by the variant")
+	}
+	f.Trigger(EventStop)
+	if cloneCalls != 1 || f.CurrentState() != StateStopped {
+		t.Errorf("Expected variant callback to run, got %d calls, state %v", cloneCalls, f.CurrentState())
+	}
+	paused := fsm.NewFSM(1, StatePaused, variant)
+	if paused.Trigger(EventStop) {
+		t.Error("Expected copied guard to veto the transition")
+	}
+}
+
+// 测试初始状态在转移、复制和池重置之后保持不变
+func TestInitialState(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.Trigger(EventStart)
+	if f.InitialState() != StateIdle {
+		t.Errorf("Expected initial state Idle, got %v", f.InitialState())
+	}
+	if clone := f.CloneDeep(1, nil); clone.InitialState() != StateIdle || clone.CurrentState() != StateRunning {
+		t.Errorf("Expected clone to keep initial state, got %v", clone.InitialState())
+	}
+	if err := f.ResetToInitial(); err != nil || f.CurrentState() != StateIdle {
+		t.Errorf("Expected reset to initial state, got %v, %v", f.CurrentState(), err)
+	}
+
+	var word int32
+	if at := fsm.NewFSMAt(2, StatePaused, table, &word); at.InitialState() != StatePaused {
+		t.Errorf("Expected NewFSMAt initial state Paused, got %v", at.InitialState())
+	}
+
+	pool := fsm.NewFsmPool(1, StateRunning, table)
+	g := pool.Allocate()
+	g.Trigger(EventStop)
+	if g.InitialState() != StateRunning {
+		t.Errorf("Expected pooled initial state Running, got %v", g.InitialState())
+	}
+	pool.Release(g)
+	if g = pool.Allocate(); g.InitialState() != StateRunning || g.CurrentState() != StateRunning {
+		t.Errorf("Expected reused instance at initial state, got %v", g.CurrentState())
+	}
+}
+
+// 测试批量创建状态机
+func TestNewFSMs(t *testing.T) {
+	table := createTestTransitionTable()
+	fsms := fsm.NewFSMs(3, 100, StateIdle, table)
+	if len(fsms) != 3 {
+		t.Fatalf("Expected 3 FSMs, got %d", len(fsms))
+	}
+	for i, f := range fsms {
+		if f.ID() != uint32(100+i) || f.CurrentState() != StateIdle {
+			t.Errorf("Unexpected FSM #%d: id %d, state %d", i, f.ID(), f.CurrentState())
+		}
+	}
+
+	// 各实例相互独立
+	fsms[0].Trigger(EventStart)
+	if fsms[0].CurrentState() != StateRunning || fsms[1].CurrentState() != StateIdle {
+		t.Error("Expected FSMs to transition independently")
+	}
+}
+
 // 测试FSM池
 func TestFsmPool(t *testing.T) {
 	table := createTestTransitionTable()
@@ -182,6 +718,439 @@ func TestFsmPool(t *testing.T) {
 	}
 }
 
+// 测试状态机归还所属的池
+func TestReturnToPool(t *testing.T) {
+	table := createTestTransitionTable()
+	pool := fsm.NewFsmPool(2, StateIdle, table)
+
+	pooled := pool.Allocate()
+	if pooled.Pool() != pool {
+		t.Error("Expected pooled FSM to report its pool")
+	}
+	if !pooled.ReturnToPool() || pool.AllocatedCount() != 0 {
+		t.Errorf("Expected pooled FSM to be released, allocated %d", pool.AllocatedCount())
+	}
+	// 重复归还不做任何处理
+	if pooled.ReturnToPool() || pool.AllocatedCount() != 0 {
+		t.Errorf("Expected second return to be a no-op, allocated %d", pool.AllocatedCount())
+	}
+
+	standalone := fsm.NewFSM(0, StateIdle, table)
+	if standalone.Pool() != nil || standalone.ReturnToPool() {
+		t.Error("Expected standalone FSM to have no pool")
+	}
+}
+
+// 测试在回调中将状态机归还所属的池
+func TestReturnToPoolInCallback(t *testing.T) {
+	table := createTestTransitionTable()
+	table.RegisterCallback(fsm.EnterState, StateStopped, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		if !f.ReturnToPool() || f.ReturnToPool() {
+			t.Error("Expected exactly one return to succeed")
+		}
+		// 释放之后重入触发的事件不再处理
+		f.Trigger(EventStart)
+	})
+	pool := fsm.NewFsmPool(1, StateIdle, table)
+
+	f := pool.Allocate()
+	f.Trigger(EventStart)
+	// 回调没有死锁，转移结束后实例被重置并放回池中
+	if !f.Trigger(EventStop) {
+		t.Fatal("Expected EventStop to be accepted")
+	}
+	if pool.AllocatedCount() != 0 || f.CurrentState() != StateIdle || f.Seq() != 0 {
+		t.Errorf("Expected reset instance back in the pool, state %v, seq %d", f.CurrentState(), f.Seq())
+	}
+	if pool.Allocate() != f {
+		t.Error("Expected the released slot to be allocatable")
+	}
+}
+
+// 测试报告结果的释放
+func TestFsmPoolTryRelease(t *testing.T) {
+	table := createTestTransitionTable()
+	pool := fsm.NewFsmPool(2, StateIdle, table)
+	other := fsm.NewFsmPool(2, StateIdle, table)
+
+	f := pool.Allocate()
+	if other.TryRelease(f) || pool.TryRelease(nil) || pool.TryRelease(fsm.NewFSM(0, StateIdle, table)) {
+		t.Error("Expected foreign or nil FSMs to be rejected")
+	}
+	if !pool.TryRelease(f) {
+		t.Error("Expected allocated FSM to be released")
+	}
+	if pool.TryRelease(f) {
+		t.Error("Expected second release to be rejected")
+	}
+	if pool.AllocatedCount() != 0 {
+		t.Errorf("Expected 0 allocated FSM, got %d", pool.AllocatedCount())
+	}
+
+	// Release报告具体的错误，重复释放不会让同一个槽位被分配两次
+	if err := pool.Release(f); !errors.Is(err, fsm.ErrDoubleRelease) {
+		t.Errorf("Expected ErrDoubleRelease, got %v", err)
+	}
+	g := pool.Allocate()
+	if err := other.Release(g); !errors.Is(err, fsm.ErrForeignFSM) {
+		t.Errorf("Expected ErrForeignFSM, got %v", err)
+	}
+	if h := pool.Allocate(); h == nil || h == g || pool.Allocate() != nil {
+		t.Error("Expected each slot to be allocated exactly once")
+	}
+}
+
+// 测试批量分配与释放
+func TestFsmPoolAllocateN(t *testing.T) {
+	pool := fsm.NewFsmPool(5, StateIdle, createTestTransitionTable())
+
+	first := pool.AllocateN(3)
+	second := pool.AllocateN(3) // 只剩2个空闲实例
+	if len(first) != 3 || len(second) != 2 || pool.AllocatedCount() != 5 {
+		t.Fatalf("Unexpected batch sizes %d, %d, allocated %d", len(first), len(second), pool.AllocatedCount())
+	}
+	if len(pool.AllocateN(1)) != 0 {
+		t.Error("Expected empty batch from exhausted pool")
+	}
+
+	// 重复的实例和nil只会被跳过
+	batch := append(first, first[0], nil)
+	if released := pool.ReleaseAll(batch); released != 3 {
+		t.Errorf("Expected 3 released FSMs, got %d", released)
+	}
+	if pool.AllocatedCount() != 2 {
+		t.Errorf("Expected 2 allocated FSMs, got %d", pool.AllocatedCount())
+	}
+}
+
+// 测试池自动扩容时已分配的实例不会移动
+func TestFsmPoolGrowable(t *testing.T) {
+	pool := fsm.NewFsmPool(2, StateIdle, createTestTransitionTable())
+	pool.SetGrowable(true)
+
+	held := pool.AllocateN(2)
+	held[0].Trigger(EventStart)
+	grown := pool.AllocateN(3) // 触发两次扩容：2 -> 4 -> 8
+	if len(grown) != 3 || pool.Size() != 8 || pool.AllocatedCount() != 5 {
+		t.Fatalf("Unexpected pool after grow: %d allocated, size %d", pool.AllocatedCount(), pool.Size())
+	}
+
+	// 扩容前取得的指针仍然指向原来的实例
+	if held[0].CurrentState() != StateRunning || held[1].CurrentState() != StateIdle {
+		t.Error("Expected held FSMs to keep their state across grow")
+	}
+	seen := make(map[*fsm.FSM]bool)
+	for _, f := range append(held, grown...) {
+		if seen[f] {
+			t.Fatalf("FSM %d allocated twice", f.ID())
+		}
+		seen[f] = true
+	}
+	if counts := pool.CountByState(); counts[StateRunning] != 1 || counts[StateIdle] != 4 {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+
+	// 扩容得到的实例同样可以释放和复用
+	pool.Release(grown[2])
+	pool.Release(held[0])
+	if pool.AllocatedCount() != 3 {
+		t.Errorf("Expected 3 allocated FSMs, got %d", pool.AllocatedCount())
+	}
+
+	pool.SetGrowable(false)
+	if len(pool.AllocateN(10)) != 5 || pool.Allocate() != nil {
+		t.Error("Expected non-growable pool to stop at its capacity")
+	}
+}
+
+// 测试池中的实例在复用时被重置
+func TestFsmPoolReset(t *testing.T) {
+	table := createTestTransitionTable()
+	pool := fsm.NewFsmPool(1, StateIdle, table)
+
+	f := pool.Allocate()
+	f.SetHistorySize(4)
+	f.SetStrict(true)
+	f.Trigger(EventStart)
+	f.Trigger(EventStop)
+	f.Post(EventStart)
+	pool.Release(f)
+	if f.CurrentState() != StateIdle || f.Seq() != 0 || f.PendingEvents() != 0 || f.History() != nil {
+		t.Errorf("Expected released FSM to be reset, got state %d, seq %d", f.CurrentState(), f.Seq())
+	}
+	// 严格模式已被重置，被拒绝的事件不会panic
+	if pool.Allocate().Trigger(EventStop) {
+		t.Error("Expected EventStop to be rejected in StateIdle")
+	}
+	pool.Release(f)
+
+	// 分配时才重置：释放后仍能读取最终状态
+	pool.SetResetMode(fsm.ResetOnAllocate)
+	f = pool.Allocate()
+	f.Trigger(EventStart)
+	pool.Release(f)
+	if f.CurrentState() != StateRunning {
+		t.Errorf("Expected released FSM to keep its state until reallocated, got %d", f.CurrentState())
+	}
+	if f = pool.Allocate(); f.CurrentState() != StateIdle {
+		t.Errorf("Expected reallocated FSM to be reset, got %d", f.CurrentState())
+	}
+}
+
+// 测试按状态统计池中的状态机
+func TestFsmPoolCountByState(t *testing.T) {
+	table := createTestTransitionTable()
+	pool := fsm.NewFsmPool(5, StateIdle, table)
+
+	fsm1 := pool.Allocate()
+	pool.Allocate()
+	fsm3 := pool.Allocate()
+	fsm1.Trigger(EventStart)
+	pool.Release(fsm3)
+
+	counts := pool.CountByState()
+	if len(counts) != 2 || counts[StateIdle] != 1 || counts[StateRunning] != 1 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+	total := 0
+	for _, n := range pool.CountByStateApprox() {
+		total += n
+	}
+	if total != pool.AllocatedCount() {
+		t.Errorf("Expected counts to sum to %d, got %d", pool.AllocatedCount(), total)
+	}
+}
+
+// 测试池的使用情况统计
+func TestFsmPoolStats(t *testing.T) {
+	table := createTestTransitionTable()
+	pool := fsm.NewFsmPool(3, StateIdle, table)
+	fsms := pool.AllocateN(3)
+	if pool.Allocate() != nil {
+		t.Fatal("Expected exhausted pool to return nil")
+	}
+	pool.Release(fsms[0])
+	pool.Release(fsms[1])
+	if got := pool.AllocateN(5); len(got) != 2 {
+		t.Fatalf("Expected AllocateN to return 2 instances, got %d", len(got))
+	}
+	pool.ReleaseAll(fsms[1:])
+
+	want := fsm.PoolStats{Size: 3, Allocated: 1, Free: 2, HighWaterMark: 3, Exhausted: 2}
+	if got := pool.Stats(); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	// 扩容的池不会耗尽，峰值随扩容增长
+	pool.SetGrowable(true)
+	pool.AllocateN(4)
+	if got := pool.Stats(); got.HighWaterMark != 5 || got.Exhausted != 2 || got.Size != 6 {
+		t.Errorf("Unexpected stats after growth %+v", got)
+	}
+}
+
+// 测试遍历已分配的实例
+func TestFsmPoolForEachAllocated(t *testing.T) {
+	table := createTestTransitionTable()
+	pool := fsm.NewFsmPool(5, StateIdle, table)
+	fsms := pool.AllocateN(4)
+	fsms[1].Trigger(EventStart)
+	fsms[2].Trigger(EventStart)
+	pool.Release(fsms[3])
+
+	var ids []uint32
+	pool.ForEachAllocated(func(f *fsm.FSM) bool {
+		ids = append(ids, f.ID())
+		// 遍历时不持有池锁，可以分配和释放实例
+		if extra := pool.Allocate(); extra != nil {
+			pool.Release(extra)
+		}
+		return true
+	})
+	// 按槽位下标顺序遍历
+	want := []uint32{fsms[0].ID(), fsms[1].ID(), fsms[2].ID()}
+	slices.Sort(want)
+	if !slices.Equal(ids, want) {
+		t.Errorf("Expected allocated instances %v, got %v", want, ids)
+	}
+
+	visited := 0
+	pool.ForEachAllocated(func(f *fsm.FSM) bool {
+		visited++
+		return false
+	})
+	if visited != 1 {
+		t.Errorf("Expected iteration to stop after first instance, got %d", visited)
+	}
+
+	counts := pool.StateCounts()
+	if len(counts) != 2 || counts[StateIdle] != 1 || counts[StateRunning] != 2 || !maps.Equal(counts, pool.CountByState()) {
+		t.Errorf("Unexpected state counts %v", counts)
+	}
+}
+
+// 测试拒绝回调
+func TestRejectHandler(t *testing.T) {
+	table := createTestTransitionTable()
+	rejected := map[fsm.Event]int{}
+	table.RegisterRejectHandler(StateStopped, func(f *fsm.FSM, from fsm.State, event fsm.Event, args ...any) {
+		if from != StateStopped || len(args) != 1 || args[0] != "arg" {
+			t.Errorf("Unexpected reject callback arguments: %d, %v", from, args)
+		}
+		rejected[event]++
+	})
+
+	for name, tt := range map[string]fsm.TransitionTable{"Array": table, "Compiled": table.Compile()} {
+		t.Run(name, func(t *testing.T) {
+			clear(rejected)
+			f := fsm.NewFSM(0, StateIdle, tt)
+			f.Trigger(EventPause, "arg") // Idle下没有注册拒绝回调
+			f.Trigger(EventStart, "arg")
+			f.Trigger(EventStop, "arg")
+			f.Trigger(EventStart, "arg")
+			// 跳过预检查时在锁内被拒绝的事件同样会触发回调
+			f.SetSkipPreCheck(true)
+			f.Trigger(EventStart, "arg")
+			if len(rejected) != 1 || rejected[EventStart] != 2 {
+				t.Errorf("Unexpected rejections: %v", rejected)
+			}
+		})
+	}
+}
+
+// 测试严格模式下未处理的事件会panic
+func TestStrictMode(t *testing.T) {
+	table := createTestTransitionTable()
+	strictFSM := fsm.NewFSM(0, StateIdle, table)
+	strictFSM.SetStrict(true)
+	lenient := fsm.NewFSM(1, StateIdle, table)
+
+	// 严格模式只对设置了的实例生效
+	if lenient.Trigger(EventPause) {
+		t.Error("Expected EventPause to be rejected")
+	}
+	if !strictFSM.Trigger(EventStart) {
+		t.Error("Failed to trigger EventStart from StateIdle")
+	}
+
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, "unhandled event") {
+			t.Errorf("Expected unhandled event panic, got %q", msg)
+		}
+	}()
+	strictFSM.Trigger(EventStart)
+}
+
+// 测试每次触发事件都会调用尝试钩子
+func TestAttemptHook(t *testing.T) {
+	fsmInstance := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+
+	var attempts []fsm.Event
+	fsmInstance.SetAttemptHook(func(state fsm.State, event fsm.Event) {
+		attempts = append(attempts, event)
+	})
+	fsmInstance.Trigger(EventPause) // 被拒绝的事件同样会被记录
+	fsmInstance.Trigger(EventStart)
+	fsmInstance.SetAttemptHook(nil)
+	fsmInstance.Trigger(EventPause)
+
+	if len(attempts) != 2 || attempts[0] != EventPause || attempts[1] != EventStart {
+		t.Errorf("Unexpected attempts: %v", attempts)
+	}
+}
+
+// 测试进入当前状态的时间
+func TestEnteredAt(t *testing.T) {
+	pool := fsm.NewFsmPool(1, StateIdle, createTestTransitionTable())
+	fsmInstance := pool.Allocate()
+	created := fsmInstance.EnteredAt()
+
+	time.Sleep(time.Millisecond)
+	fsmInstance.Trigger(EventStart)
+	entered := fsmInstance.EnteredAt()
+	if !entered.After(created) || time.Since(entered) > time.Second {
+		t.Errorf("Expected EnteredAt to advance on transition, got %v then %v", created, entered)
+	}
+
+	time.Sleep(time.Millisecond)
+	pool.Release(fsmInstance)
+	if !fsmInstance.EnteredAt().After(entered) {
+		t.Error("Expected Release to reset EnteredAt")
+	}
+}
+
+// 测试创建时附加的业务数据
+func TestNewFSMWithData(t *testing.T) {
+	type session struct{ user string }
+	s := &session{user: "alice"}
+	fsmInstance := fsm.NewFSMWithData(0, StateIdle, createTestTransitionTable(), s)
+	if fsmInstance.Data() != s {
+		t.Errorf("Expected data %v, got %v", s, fsmInstance.Data())
+	}
+	if fsm.NewFSM(1, StateIdle, createTestTransitionTable()).Data() != nil {
+		t.Error("Expected nil data for FSM created without data")
+	}
+}
+
+// 测试业务数据的读写及释放回池时的重置
+func TestFSMSetData(t *testing.T) {
+	table := createTestTransitionTable()
+	pool := fsm.NewFsmPool(1, StateIdle, table)
+	fsmInstance := pool.Allocate()
+
+	// 回调中可以读写业务数据
+	table.RegisterCallback(fsm.AfterEvent, StateIdle, EventStart, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		f.SetData(f.Data().(int) + 1)
+	})
+	fsmInstance.SetData(41)
+	fsmInstance.Trigger(EventStart)
+	if fsmInstance.Data() != 42 {
+		t.Errorf("Expected data 42, got %v", fsmInstance.Data())
+	}
+
+	pool.Release(fsmInstance)
+	if reused := pool.Allocate(); reused.Data() != nil {
+		t.Errorf("Expected data to be reset on release, got %v", reused.Data())
+	}
+}
+
+// 测试TriggerCtx传递context并在取消时提前返回
+func TestTriggerCtx(t *testing.T) {
+	type ctxKey struct{}
+	table := createTestTransitionTable()
+	var got any
+	table.RegisterContextCallback(fsm.EnterState, StateRunning, 0, func(tc *fsm.TransitionContext) {
+		got = tc.Ctx.Value(ctxKey{})
+	})
+	fsmInstance := fsm.NewFSM(0, StateIdle, table)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "request-1"))
+	if !fsmInstance.TriggerCtx(ctx, EventStart) || got != "request-1" {
+		t.Errorf("Expected callback to see context value, got %v", got)
+	}
+
+	cancel()
+	if fsmInstance.TriggerCtx(ctx, EventPause) || fsmInstance.CurrentState() != StateRunning {
+		t.Error("Expected canceled context to reject the event")
+	}
+}
+
+// 测试CanTrigger只做判断而不转移
+func TestCanTrigger(t *testing.T) {
+	fsmInstance := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	if !fsmInstance.CanTrigger(EventStart) || fsmInstance.CanTrigger(EventPause) {
+		t.Error("Unexpected CanTrigger results in StateIdle")
+	}
+	if fsmInstance.CurrentState() != StateIdle || fsmInstance.Seq() != 0 {
+		t.Error("Expected CanTrigger to have no side effects")
+	}
+	fsmInstance.Pause()
+	if fsmInstance.CanTrigger(EventStart) {
+		t.Error("Expected paused FSM to report no triggerable events")
+	}
+}
+
 // 测试并发安全性
 func TestConcurrentAccess(t *testing.T) {
 	table := createTestTransitionTable()
@@ -213,6 +1182,11 @@ func TestConcurrentAccess(t *testing.T) {
 		finalState != StatePaused && finalState != StateStopped {
 		t.Errorf("Invalid final state: %d", finalState)
 	}
+
+	// 有Event锁保护，CAS不应出现重试
+	if retries := fsmInstance.CASRetries(); retries != 0 {
+		t.Errorf("Expected 0 CAS retries, got %d", retries)
+	}
 }
 
 // 基准测试：状态转移性能
@@ -227,6 +1201,27 @@ func BenchmarkStateTransition(b *testing.B) {
 	}
 }
 
+// 基准测试：事件均能成功转移时的性能（默认开启预检查）
+func BenchmarkSuccessfulTransition(b *testing.B) {
+	table := createTestTransitionTable()
+	fsmInstance := fsm.NewFSM(0, StateRunning, table)
+	for b.Loop() {
+		fsmInstance.Trigger(EventPause)
+		fsmInstance.Trigger(EventResume)
+	}
+}
+
+// 基准测试：事件均能成功转移时的性能（跳过预检查）
+func BenchmarkSuccessfulTransitionSkipPreCheck(b *testing.B) {
+	table := createTestTransitionTable()
+	fsmInstance := fsm.NewFSM(0, StateRunning, table)
+	fsmInstance.SetSkipPreCheck(true)
+	for b.Loop() {
+		fsmInstance.Trigger(EventPause)
+		fsmInstance.Trigger(EventResume)
+	}
+}
+
 // 基准测试：并发状态转移性能
 func BenchmarkConcurrentStateTransition(b *testing.B) {
 	table := createTestTransitionTable()
@@ -0,0 +1,46 @@
+package fsm
+
+// Guard 转移守卫，在事件匹配转移规则后判断是否允许执行该转移，返回false则否决本次转移
+// 守卫在持有Event锁的情况下执行，应当是快速的判断，每次触发最多执行一次
+type Guard func(fsm *FSM, from State, event Event, args ...any) bool
+
+// GuardTable 可选接口：支持转移守卫的状态转移表
+type GuardTable interface {
+	GetGuard(state State, event Event) Guard
+}
+
+// RegisterGuard 为(state, event)上的转移注册守卫，传入nil表示取消
+// 守卫否决的事件既不执行任何回调也不改变状态，Trigger返回false，TriggerDetailed返回GuardRejected。
+// 守卫对接受并忽略的事件同样生效
+func (t *ArrayTransitionTable) RegisterGuard(state State, event Event, guard Guard) {
+	index, ok := t.cellIndex(state, event)
+	if !ok {
+		return
+	}
+	if t.guards == nil {
+		t.guards = make([]Guard, len(t.table))
+	}
+	t.guards[index] = guard
+}
+
+// GetGuard 获取(state, event)上的转移守卫，没有注册时返回nil
+func (t *ArrayTransitionTable) GetGuard(state State, event Event) Guard {
+	if t.guards == nil {
+		return nil
+	}
+	index, ok := t.resolve(state, event)
+	if !ok {
+		return nil
+	}
+	return t.guards[index]
+}
+
+// guardAllows 判断守卫是否允许当前状态下的转移，没有守卫时总是允许
+func (f *FSM) guardAllows(current State, event Event, args []any) bool {
+	gt, ok := f.transitionTable.(GuardTable)
+	if !ok {
+		return true
+	}
+	guard := gt.GetGuard(current, event)
+	return guard == nil || guard(f, current, event, args...)
+}
@@ -0,0 +1,63 @@
+package fsm_test
+
+import (
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试守卫否决转移
+func TestGuard(t *testing.T) {
+	table := createTestTransitionTable()
+	pendingChildren := 1
+	leaves := 0
+	table.RegisterGuard(StatePaused, EventStop, func(f *fsm.FSM, from fsm.State, event fsm.Event, args ...any) bool {
+		return pendingChildren == 0
+	})
+	table.RegisterCallback(fsm.LeaveState, StatePaused, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		leaves++
+	})
+
+	for name, tt := range map[string]fsm.TransitionTable{"Array": table, "Compiled": table.Compile()} {
+		t.Run(name, func(t *testing.T) {
+			pendingChildren, leaves = 1, 0
+			f := fsm.NewFSM(0, StatePaused, tt)
+			// CanTrigger不执行守卫
+			if !f.CanTrigger(EventStop) || f.CanTrigger(EventStart) {
+				t.Error("Unexpected CanTrigger results")
+			}
+			if got := f.TriggerDetailed(EventStop); got != fsm.GuardRejected {
+				t.Errorf("Expected GuardRejected, got %v", got)
+			}
+			if f.Trigger(EventStop) || f.CurrentState() != StatePaused || leaves != 0 {
+				t.Errorf("Expected vetoed transition to leave state %d untouched, got %d (%d leaves)", StatePaused, f.CurrentState(), leaves)
+			}
+			// 其他事件不受守卫影响
+			if !f.Trigger(EventResume) || !f.Trigger(EventPause) {
+				t.Error("Expected unguarded transitions to succeed")
+			}
+
+			pendingChildren = 0
+			if !f.Trigger(EventStop) || f.CurrentState() != StateStopped {
+				t.Errorf("Expected guarded transition to succeed, got state %d", f.CurrentState())
+			}
+		})
+	}
+}
+
+// 测试TriggerAll在守卫否决时整体失败
+func TestTriggerAllGuard(t *testing.T) {
+	table := createTestTransitionTable()
+	table.RegisterGuard(StateIdle, EventStart, func(f *fsm.FSM, from fsm.State, event fsm.Event, args ...any) bool {
+		return f.ID() != 1
+	})
+	fsms := []*fsm.FSM{fsm.NewFSM(0, StateIdle, table), fsm.NewFSM(1, StateIdle, table)}
+	if fsm.TriggerAll(fsms, EventStart) {
+		t.Error("Expected TriggerAll to fail when a guard vetoes")
+	}
+	for _, f := range fsms {
+		if f.CurrentState() != StateIdle {
+			t.Errorf("FSM %d moved to %d", f.ID(), f.CurrentState())
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package fsm
+
+import (
+	"fmt"
+	"slices"
+	"sync/atomic"
+)
+
+// HierarchyTable 可选接口：支持父子状态（层次状态机）的状态转移表
+// Parent返回state的父状态，没有父状态时返回StateInInit
+type HierarchyTable interface {
+	Parent(state State) State
+}
+
+// SetParent 将parent设为child的父状态，parent为StateInInit时取消child的父状态
+// 子状态没有定义某个事件的转移规则时，事件沿父状态链向上冒泡，使用最近的祖先状态上的规则，
+// 守卫和BeforeEvent/AfterEvent回调同样取自提供规则的那个状态。
+// 转移时沿层次结构执行回调：从源状态向上依次执行LeaveState，直到源状态与目标状态的最近公共祖先（不含）；
+// 再从公共祖先下一层向下依次执行EnterState，直到目标状态。目标状态是源状态的祖先（或反之）时，
+// 该祖先同样会被离开并重新进入，与自转移的处理方式一致。
+// 状态或父状态超出表的范围时返回ErrOutOfRange，形成环时返回ErrInvalidState。
+// 应在使用状态转移表之前设置；Transitions、ToDOT等分析与导出只考虑各状态自身的规则，TerminalSet与IsTerminal则考虑继承的规则
+func (t *ArrayTransitionTable) SetParent(child, parent State) error {
+	if child < 0 || int32(child) >= t.maxStates || parent != StateInInit && (parent < 0 || int32(parent) >= t.maxStates) {
+		return fmt.Errorf("%w: parent %v of state %v outside %d states", ErrOutOfRange, parent, child, t.maxStates)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.parents == nil {
+		if parent == StateInInit {
+			return nil
+		}
+		t.parents = make([]State, t.maxStates)
+		for i := range t.parents {
+			t.parents[i] = StateInInit
+		}
+	}
+	for p := parent; p != StateInInit; p = t.parents[p] {
+		if p == child {
+			return fmt.Errorf("%w: parent %v of state %v forms a cycle", ErrInvalidState, parent, child)
+		}
+	}
+	t.parents[child] = parent
+	t.cache.invalidate()
+	return nil
+}
+
+// Parent 获取state的父状态，没有父状态时返回StateInInit
+func (t *ArrayTransitionTable) Parent(state State) State {
+	if state < 0 || int(state) >= len(t.parents) {
+		return StateInInit
+	}
+	return t.parents[state]
+}
+
+// resolve 计算处理(state, event)的单元格下标：state自身没有规则时沿父状态链查找最近的定义了规则的祖先，
+// 都没有时返回state自身的单元格
+func (t *ArrayTransitionTable) resolve(state State, event Event) (index int32, ok bool) {
+	index, ok = t.cellIndex(state, event)
+	if !ok || t.parents == nil || atomic.LoadInt32((*int32)(&t.table[index])) != int32(StateInInit) {
+		return index, ok
+	}
+	for p := t.parents[state]; p != StateInInit; p = t.parents[p] {
+		if i, _ := t.cellIndex(p, event); atomic.LoadInt32((*int32)(&t.table[i])) != int32(StateInInit) {
+			return i, true
+		}
+	}
+	return index, ok
+}
+
+// resolveCallback 计算获取回调时使用的下标，按(state, event)注册的回调与转移规则一样沿父状态链解析
+func (t *ArrayTransitionTable) resolveCallback(cbType CallbackType, state State, event Event) (index int32, ok bool) {
+	if cbType == BeforeEvent || cbType == AfterEvent {
+		return t.resolve(state, event)
+	}
+	index, _, ok = t.callbackIndex(cbType, state, event)
+	return index, ok
+}
+
+// inheritedEvents 将祖先状态上定义的事件并入events，没有父状态时原样返回
+func (t *ArrayTransitionTable) inheritedEvents(state State, events []Event) []Event {
+	if t.parents == nil || t.parents[state] == StateInInit {
+		return events
+	}
+	for p := t.parents[state]; p != StateInInit; p = t.parents[p] {
+		events = append(events, eventsInRow(t.table[int32(p)*t.maxEvents:(int32(p)+1)*t.maxEvents])...)
+	}
+	slices.Sort(events)
+	return slices.Compact(events)
+}
+
+// flattenParents 将祖先状态上的规则展开到编译后的状态数组中，子状态自身的规则优先
+func (t *ArrayTransitionTable) flattenParents(table []State, consumed, internal []bool, shift uint32) {
+	for state := range t.maxStates {
+		for event := range t.maxEvents {
+			dst := state<<shift | event
+			if table[dst] != StateInInit {
+				continue
+			}
+			if src, _ := t.resolve(State(state), Event(event)); src != state*t.maxEvents+event {
+				table[dst] = t.table[src]
+				if consumed != nil {
+					consumed[dst] = t.consumed[src].Load()
+				}
+				if internal != nil {
+					internal[dst] = t.internal[src].Load()
+				}
+			}
+		}
+	}
+}
+
+// leaveBranch 从current向上依次执行LeaveState回调，直到与next的公共祖先（不含），返回ErrHandler的错误
+func (f *FSM) leaveBranch(current, next State, tc *TransitionContext) error {
+	ht, ok := f.transitionTable.(HierarchyTable)
+	if !ok {
+		return f.callback(LeaveState, current, tc)
+	}
+	stop := branchRoot(ht, current, next)
+	for s := current; s != stop; s = ht.Parent(s) {
+		if err := f.callback(LeaveState, s, tc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enterBranch 从current与next的公共祖先的下一层开始，向下依次执行EnterState回调，直到next
+func (f *FSM) enterBranch(current, next State, tc *TransitionContext) {
+	ht, ok := f.transitionTable.(HierarchyTable)
+	if !ok {
+		f.callback(EnterState, next, tc)
+		return
+	}
+	f.enterFrom(ht, next, branchRoot(ht, current, next), tc)
+}
+
+// enterFrom 先进入state的祖先（stop以下），再进入state
+func (f *FSM) enterFrom(ht HierarchyTable, state, stop State, tc *TransitionContext) {
+	if state == stop {
+		return
+	}
+	f.enterFrom(ht, ht.Parent(state), stop, tc)
+	f.callback(EnterState, state, tc)
+}
+
+// branchRoot 计算转移不会离开的最近公共祖先，没有时返回StateInInit
+// 公共祖先就是current或next本身时，该状态同样会被离开并重新进入，因此取它的父状态
+func branchRoot(ht HierarchyTable, current, next State) State {
+	for a := current; a != StateInInit; a = ht.Parent(a) {
+		for b := next; b != StateInInit; b = ht.Parent(b) {
+			if a != b {
+				continue
+			}
+			if a == current || a == next {
+				return ht.Parent(a)
+			}
+			return a
+		}
+	}
+	return StateInInit
+}
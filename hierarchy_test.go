@@ -0,0 +1,103 @@
+package fsm_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+const (
+	uiIdle fsm.State = iota
+	uiRunning
+	uiLoading // uiRunning的子状态
+	uiReady   // uiRunning的子状态
+	uiStopped
+)
+
+const (
+	uiStart fsm.Event = iota
+	uiLoaded
+	uiStop
+	uiReload
+)
+
+// createHierarchyTable 创建一个uiRunning包含uiLoading和uiReady两个子状态的状态转移表
+func createHierarchyTable(t *testing.T, log *[]string) *fsm.ArrayTransitionTable {
+	table := fsm.NewArrayTransitionTable([]fsm.Transition{
+		{From: uiIdle, Event: uiStart, To: uiLoading},
+		{From: uiLoading, Event: uiLoaded, To: uiReady},
+		{From: uiReady, Event: uiReload, To: uiLoading},
+		{From: uiRunning, Event: uiStop, To: uiStopped},   // 由子状态冒泡使用
+		{From: uiRunning, Event: uiReload, To: uiLoading}, // 被uiReady自身的规则覆盖
+	})
+	for _, child := range []fsm.State{uiLoading, uiReady} {
+		if err := table.SetParent(child, uiRunning); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for state := uiIdle; state <= uiStopped; state++ {
+		table.RegisterCallback(fsm.LeaveState, state, 0, func(_ *fsm.FSM, _, _ fsm.State, _ fsm.Event, _ ...any) {
+			*log = append(*log, fmt.Sprintf("leave:%d", state))
+		})
+		table.RegisterCallback(fsm.EnterState, state, 0, func(_ *fsm.FSM, _, _ fsm.State, _ fsm.Event, _ ...any) {
+			*log = append(*log, fmt.Sprintf("enter:%d", state))
+		})
+	}
+	return table
+}
+
+// 测试事件从子状态冒泡到父状态，并沿层次结构执行进入和离开回调
+func TestHierarchicalStates(t *testing.T) {
+	var log []string
+	table := createHierarchyTable(t, &log)
+
+	for name, tt := range map[string]fsm.TransitionTable{"Array": table, "Compiled": table.Compile()} {
+		t.Run(name, func(t *testing.T) {
+			f := fsm.NewFSM(0, uiIdle, tt)
+			steps := []struct {
+				event fsm.Event
+				to    fsm.State
+				log   []string
+			}{
+				{uiStart, uiLoading, []string{"leave:0", "enter:1", "enter:2"}},
+				{uiLoaded, uiReady, []string{"leave:2", "enter:3"}},
+				{uiReload, uiLoading, []string{"leave:3", "enter:2"}},
+				{uiStop, uiStopped, []string{"leave:2", "leave:1", "enter:4"}}, // 冒泡到uiRunning的规则
+			}
+			for _, step := range steps {
+				log = nil
+				if !f.Trigger(step.event) || f.CurrentState() != step.to {
+					t.Fatalf("Expected event %d to move to %d, got %d", step.event, step.to, f.CurrentState())
+				}
+				if !slices.Equal(log, step.log) {
+					t.Errorf("Event %d: expected callbacks %v, got %v", step.event, step.log, log)
+				}
+			}
+		})
+	}
+
+	if events := table.EventsFrom(uiLoading); !slices.Equal(events, []fsm.Event{uiLoaded, uiStop, uiReload}) {
+		t.Errorf("Expected inherited events, got %v", events)
+	}
+}
+
+// 测试父状态设置的校验
+func TestSetParentErrors(t *testing.T) {
+	var log []string
+	table := createHierarchyTable(t, &log)
+	if err := table.SetParent(uiRunning, uiLoading); !errors.Is(err, fsm.ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState for cycle, got %v", err)
+	}
+	if err := table.SetParent(uiLoading, fsm.State(10)); !errors.Is(err, fsm.ErrOutOfRange) {
+		t.Errorf("Expected ErrOutOfRange, got %v", err)
+	}
+	if err := table.SetParent(uiLoading, fsm.StateInInit); err != nil || table.Parent(uiLoading) != fsm.StateInInit {
+		t.Errorf("Expected parent to be cleared, got %v, %v", table.Parent(uiLoading), err)
+	}
+	if _, ok := table.GetNextState(uiLoading, uiStop); ok {
+		t.Error("Expected uiStop to stop bubbling once the parent is cleared")
+	}
+}
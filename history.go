@@ -0,0 +1,91 @@
+package fsm
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// HistoryEntry 一次成功转移的记录
+type HistoryEntry struct {
+	Seq   uint64    `json:"seq"`   // 本次转移的序号，见TransitionContext.Seq
+	From  State     `json:"from"`  // 转移前的状态
+	To    State     `json:"to"`    // 转移后的状态
+	Event Event     `json:"event"` // 触发转移的事件
+	At    time.Time `json:"at"`    // 完成转移的时间
+}
+
+// historyRing 固定容量的转移历史环形缓冲区，在Event锁保护下读写
+type historyRing struct {
+	entries []HistoryEntry
+	next    int  // 下一条记录写入的位置
+	full    bool // 缓冲区是否已经写满过
+}
+
+// add 写入一条记录，写满后覆盖最旧的记录
+func (h *historyRing) add(entry HistoryEntry) {
+	h.entries[h.next] = entry
+	h.next++
+	if h.next == len(h.entries) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+// reset 返回一个容量为size的空缓冲区，容量不变时原地清空复用，size<=0时返回nil
+// 接收者可以为nil
+func (h *historyRing) reset(size int) *historyRing {
+	if size <= 0 {
+		return nil
+	}
+	if h == nil || len(h.entries) != size {
+		return &historyRing{entries: make([]HistoryEntry, size)}
+	}
+	clear(h.entries)
+	h.next = 0
+	h.full = false
+	return h
+}
+
+// snapshot 按从旧到新的顺序复制出所有记录
+func (h *historyRing) snapshot() []HistoryEntry {
+	if !h.full {
+		return slices.Clone(h.entries[:h.next])
+	}
+	out := make([]HistoryEntry, 0, len(h.entries))
+	out = append(out, h.entries[h.next:]...)
+	return append(out, h.entries[:h.next]...)
+}
+
+// SetHistorySize 设置保留的最近转移记录条数，size<=0时关闭历史记录（默认关闭）
+// 修改容量会丢弃已有的记录
+func (f *FSM) SetHistorySize(size int) {
+	f.eventLock.Lock()
+	defer f.eventLock.Unlock()
+	defer f.updateNeedsLock()
+	if size <= 0 {
+		f.history = nil
+		return
+	}
+	f.history = &historyRing{entries: make([]HistoryEntry, size)}
+}
+
+// History 按从旧到新的顺序获取最近的转移记录，未开启历史记录时返回nil
+func (f *FSM) History() []HistoryEntry {
+	f.eventLock.Lock()
+	defer f.eventLock.Unlock()
+	if f.history == nil {
+		return nil
+	}
+	return f.history.snapshot()
+}
+
+// HistoryJSON 将最近的转移记录序列化为JSON数组，便于离线分析或附在问题报告中
+// 记录在Event锁下复制，可以与Trigger并发调用；未开启历史记录时返回空数组
+func (f *FSM) HistoryJSON() ([]byte, error) {
+	entries := f.History()
+	if entries == nil {
+		entries = []HistoryEntry{}
+	}
+	return json.Marshal(entries)
+}
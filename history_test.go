@@ -0,0 +1,75 @@
+package fsm_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试转移历史的记录与序列化
+func TestHistoryJSON(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+
+	data, err := f.HistoryJSON()
+	if err != nil || string(data) != "[]" {
+		t.Fatalf("Expected empty history when disabled, got %s, %v", data, err)
+	}
+
+	f.SetHistorySize(2)
+	f.Trigger(EventStart)
+	f.Trigger(EventPause)
+	f.Trigger(EventResume)
+
+	data, err = f.HistoryJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []fsm.HistoryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	// 只保留最近的两条，且按从旧到新排列
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Seq != 2 || entries[0].From != StateRunning || entries[0].To != StatePaused || entries[0].Event != EventPause {
+		t.Errorf("Unexpected first entry %+v", entries[0])
+	}
+	if entries[1].Seq != 3 || entries[1].To != StateRunning || entries[1].At.Before(entries[0].At) {
+		t.Errorf("Unexpected second entry %+v", entries[1])
+	}
+}
+
+// 测试池级别的转移历史在释放时被清空
+func TestFsmPoolHistory(t *testing.T) {
+	pool := fsm.NewFsmPool(1, StateIdle, createTestTransitionTable())
+	pool.SetGrowable(true)
+	pool.SetHistorySize(2)
+
+	f := pool.Allocate()
+	f.Trigger(EventStart)
+	f.Trigger(EventPause)
+	f.Trigger(EventResume)
+	if h := f.History(); len(h) != 2 || h[0].To != StatePaused || h[1].To != StateRunning {
+		t.Fatalf("Unexpected history %+v", h)
+	}
+
+	pool.Release(f)
+	if h := f.History(); h == nil || len(h) != 0 {
+		t.Errorf("Expected empty history after release, got %+v", h)
+	}
+
+	// 扩容得到的实例同样记录历史
+	pool.Allocate()
+	g := pool.Allocate()
+	g.Trigger(EventStart)
+	if len(g.History()) != 1 {
+		t.Errorf("Expected grown FSM to record history, got %+v", g.History())
+	}
+
+	pool.SetHistorySize(0)
+	if g.History() != nil {
+		t.Error("Expected history to be disabled")
+	}
+}
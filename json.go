@@ -0,0 +1,102 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// tableDocument ArrayTransitionTable的JSON文档结构
+type tableDocument struct {
+	MaxStates   int32            `json:"maxStates"`
+	MaxEvents   int32            `json:"maxEvents"`
+	Transitions []transitionJSON `json:"transitions"`
+}
+
+// transitionJSON JSON文档中的一条转移规则
+type transitionJSON struct {
+	From     State `json:"from"`
+	Event    Event `json:"event"`
+	To       State `json:"to"`
+	Consume  bool  `json:"consume,omitempty"`
+	Internal bool  `json:"internal,omitempty"`
+}
+
+// MarshalJSON 将状态转移表的结构导出为JSON，便于外部的可视化和校验工具使用
+// 文档包含maxStates、maxEvents以及按状态、事件升序排列的所有转移规则，相同的表总是得到相同的输出。
+// AnyState通配规则已在创建表时展开到每个状态，导出的是展开后的具体规则。
+// 回调、守卫、拒绝回调和事件优先级都不会被导出：函数无法序列化，需要在反序列化后重新注册；
+// 父状态（见SetParent）同样不会被导出，需要重新设置
+func (t *ArrayTransitionTable) MarshalJSON() ([]byte, error) {
+	doc := tableDocument{
+		MaxStates:   t.maxStates,
+		MaxEvents:   t.maxEvents,
+		Transitions: []transitionJSON{},
+	}
+	t.RangeTransitions(func(tr Transition) bool {
+		doc.Transitions = append(doc.Transitions, transitionJSON(tr))
+		return true
+	})
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON 从MarshalJSON导出的文档重建状态转移表，得到的表与原表的转移规则和大小完全相同
+// 表中已有的转移规则和所有回调、守卫等都会被清除，只能在表投入使用之前调用；
+// 文档无效（大小为负、规则超出声明的大小、规则冲突或表过大）时返回ErrInvalidDefinition，表保持不变
+func (t *ArrayTransitionTable) UnmarshalJSON(data []byte) error {
+	var doc tableDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	transitions := make([]Transition, len(doc.Transitions))
+	for i, tr := range doc.Transitions {
+		transitions[i] = Transition(tr)
+	}
+	if err := validateDocument(doc, transitions); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDefinition, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.allocate(doc.MaxStates, doc.MaxEvents, StateInInit)
+	t.ctxCallbacks = [4][]ContextHandler{}
+	t.errCallbacks = [4][]ErrHandler{}
+	t.consumed = nil
+	t.internal = nil
+	t.priorities = nil
+	t.guards = nil
+	t.rejects = nil
+	t.parents = nil
+	t.globals = [4]Handler{}
+	for i, trans := range transitions {
+		t.fill(i, trans)
+	}
+	t.cache.invalidate()
+	return nil
+}
+
+// validateDocument 检查JSON文档声明的大小能否容纳其中的所有转移规则
+func validateDocument(doc tableDocument, transitions []Transition) error {
+	if doc.MaxStates < 0 || doc.MaxEvents < 0 {
+		return fmt.Errorf("%w: %d states x %d events", ErrOutOfRange, doc.MaxStates, doc.MaxEvents)
+	}
+	if cells := int64(doc.MaxStates) * int64(doc.MaxEvents); cells > min(MaxTableCells, math.MaxInt32) {
+		return fmt.Errorf("%w: %d states x %d events", ErrTableTooLarge, doc.MaxStates, doc.MaxEvents)
+	}
+	if err := validateStates(transitions); err != nil {
+		return err
+	}
+	if err := ValidateNoConflicts(transitions); err != nil {
+		return err
+	}
+	var errs []error
+	for i, trans := range transitions {
+		if trans.From < 0 || int32(trans.From) >= doc.MaxStates || trans.Event < 0 || int32(trans.Event) >= doc.MaxEvents ||
+			trans.To < 0 || (!trans.keepsState() && int32(trans.To) >= doc.MaxStates) {
+			errs = append(errs, fmt.Errorf("%w: transition #%d %+v does not fit in %d states x %d events table",
+				ErrOutOfRange, i, trans, doc.MaxStates, doc.MaxEvents))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,119 @@
+package fsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInvalidDefinition 状态机定义文档无效
+var ErrInvalidDefinition = errors.New("invalid table definition")
+
+// tableDefinition LoadTableJSON读取的状态机定义文档
+type tableDefinition struct {
+	States      []string               `json:"states"`
+	Events      []string               `json:"events"`
+	Transitions []transitionDefinition `json:"transitions"`
+}
+
+// transitionDefinition 定义文档中以名称表示的转移规则
+type transitionDefinition struct {
+	From     string `json:"from"`
+	Event    string `json:"event"`
+	To       string `json:"to"`
+	Consume  bool   `json:"consume"`
+	Internal bool   `json:"internal"`
+}
+
+// LoadTableJSON 从JSON定义文档创建数组状态转移表，同时返回状态和事件的名称到ID的映射
+// 文档格式如下，状态和事件按声明顺序从0开始编号，consume和internal可省略，其中之一为true时可以省略to：
+//
+//	{
+//	  "states": ["Idle", "Running"],
+//	  "events": ["start", "stop"],
+//	  "transitions": [
+//	    {"from": "Idle", "event": "start", "to": "Running"},
+//	    {"from": "Running", "event": "start", "consume": true}
+//	  ]
+//	}
+//
+// 语法错误和类型错误会报告所在的行列；名称重复、引用了未声明的状态或事件时返回ErrInvalidDefinition，
+// 并指出出错的字段（例如transitions[2].to）；转移规则冲突时返回的错误与NewArrayTransitionTableChecked相同。
+// 需要用YAML书写定义时，可以先转换为JSON再调用本函数
+func LoadTableJSON(r io.Reader) (*ArrayTransitionTable, map[string]State, map[string]Event, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var def tableDefinition
+	if err := dec.Decode(&def); err != nil {
+		return nil, nil, nil, definitionError(data, err)
+	}
+
+	states := make(map[string]State, len(def.States))
+	for i, name := range def.States {
+		if _, ok := states[name]; ok {
+			return nil, nil, nil, fmt.Errorf("%w: states[%d]: duplicate state %q", ErrInvalidDefinition, i, name)
+		}
+		states[name] = State(i)
+	}
+	events := make(map[string]Event, len(def.Events))
+	for i, name := range def.Events {
+		if _, ok := events[name]; ok {
+			return nil, nil, nil, fmt.Errorf("%w: events[%d]: duplicate event %q", ErrInvalidDefinition, i, name)
+		}
+		events[name] = Event(i)
+	}
+
+	var errs []error
+	transitions := make([]Transition, len(def.Transitions))
+	for i, td := range def.Transitions {
+		from, ok := states[td.From]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: transitions[%d].from: undeclared state %q", ErrInvalidDefinition, i, td.From))
+		}
+		event, ok := events[td.Event]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: transitions[%d].event: undeclared event %q", ErrInvalidDefinition, i, td.Event))
+		}
+		to := from
+		if !(td.Consume || td.Internal) || td.To != "" {
+			if to, ok = states[td.To]; !ok {
+				errs = append(errs, fmt.Errorf("%w: transitions[%d].to: undeclared state %q", ErrInvalidDefinition, i, td.To))
+			}
+		}
+		transitions[i] = Transition{From: from, Event: event, To: to, Consume: td.Consume, Internal: td.Internal}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, nil, err
+	}
+
+	table, err := NewArrayTransitionTableChecked(transitions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return table, states, events, nil
+}
+
+// definitionError 为JSON解析错误补充出错位置的行列
+func definitionError(data []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return fmt.Errorf("%w: %w", ErrInvalidDefinition, err)
+	}
+	offset = min(offset, int64(len(data)))
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	col := offset - int64(bytes.LastIndexByte(data[:offset], '\n'))
+	return fmt.Errorf("%w: line %d column %d: %w", ErrInvalidDefinition, line, col, err)
+}
@@ -0,0 +1,67 @@
+package fsm_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试从JSON定义文档加载状态转移表
+func TestLoadTableJSON(t *testing.T) {
+	doc := `{
+  "states": ["Idle", "Running", "Stopped"],
+  "events": ["start", "stop"],
+  "transitions": [
+    {"from": "Idle", "event": "start", "to": "Running"},
+    {"from": "Running", "event": "stop", "to": "Stopped"},
+    {"from": "Running", "event": "start", "consume": true}
+  ]
+}`
+	table, states, events, err := fsm.LoadTableJSON(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states["Stopped"] != 2 || events["stop"] != 1 {
+		t.Errorf("Unexpected name maps %v %v", states, events)
+	}
+
+	f := fsm.NewFSM(0, states["Idle"], table)
+	if !f.Trigger(events["start"]) || !f.Trigger(events["start"]) || f.CurrentState() != states["Running"] {
+		t.Fatalf("Unexpected state %v", f.CurrentState())
+	}
+	if !f.Trigger(events["stop"]) || f.CurrentState() != states["Stopped"] {
+		t.Errorf("Expected Stopped, got %v", f.CurrentState())
+	}
+}
+
+// 测试定义文档错误时报告的位置
+func TestLoadTableJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"syntax", "{\n  \"states\": [\"Idle\",]\n}", "line 2"},
+		{"type", "{\n  \"states\": [1]\n}", "line 2"},
+		{"unknown field", `{"stats": []}`, "unknown field"},
+		{"duplicate", `{"states": ["Idle", "Idle"]}`, `states[1]: duplicate state "Idle"`},
+		{"undeclared", `{"states": ["Idle"], "events": ["start"], "transitions": [{"from": "Idle", "event": "start", "to": "Runing"}]}`,
+			`transitions[0].to: undeclared state "Runing"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := fsm.LoadTableJSON(strings.NewReader(tt.doc))
+			if !errors.Is(err, fsm.ErrInvalidDefinition) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected ErrInvalidDefinition containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	conflict := `{"states": ["A", "B"], "events": ["e"], "transitions": [
+		{"from": "A", "event": "e", "to": "A"}, {"from": "A", "event": "e", "to": "B"}]}`
+	if _, _, _, err := fsm.LoadTableJSON(strings.NewReader(conflict)); !errors.Is(err, fsm.ErrConflictingTransition) {
+		t.Errorf("Expected ErrConflictingTransition, got %v", err)
+	}
+}
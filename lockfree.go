@@ -0,0 +1,123 @@
+package fsm
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// SetLockFree 设置是否为没有副作用的转移启用无锁快速路径，默认关闭
+// 开启后，如果一次转移满足以下条件，Trigger只通过CAS切换状态，完全不获取Event锁，
+// 同一状态机上并发的转移不再相互排队：
+//   - 状态转移表中没有与本次转移相关的任何回调（包括全局回调、ContextHandler、ErrHandler以及父状态上的回调）、
+//     守卫、接受并忽略的规则或内部转移规则，源状态和目标状态都没有父状态；
+//   - 状态机没有观察者、指标接收方、追踪函数、实例级监听器（如Mirror）、转移历史和超时规则，也没有被Pause冻结。
+//
+// 不满足条件的转移仍然持有Event锁按原来的方式执行。持锁的转移（以及TriggerSequence、TriggerAll、超时、重置等）
+// 从获取Event锁起就会排斥无锁转移：它们先等待正在进行的无锁转移完成，之后到达的无锁转移退回到持锁路径排队，
+// 因此持锁转移读取状态、执行回调和提交之间状态不会被改变，回调不会因CAS失败而重复执行，批量操作的原子性也不受影响。
+// 由此带来的语义变化：
+//   - 并发的无锁转移之间没有先后顺序的保证，只保证状态和转移次数一致，进入状态的时间（TimeInState、EnteredAt）是近似值；
+//   - 在其他状态机的回调中触发本状态机的无锁转移不会panic，因为此时不会持有多把Event锁。
+//
+// 适合单个状态机被大量goroutine同时驱动、且转移本身没有回调的高竞争场景
+func (f *FSM) SetLockFree(lockFree bool) {
+	f.lockFree.Store(lockFree)
+}
+
+// updateNeedsLock 根据实例级监听器、转移历史和超时规则重新计算needsLock，调用方持有Event锁
+// 或者状态机尚未被其他goroutine使用
+func (f *FSM) updateNeedsLock() {
+	f.needsLock.Store(f.listeners != nil || f.history != nil || f.timeouts != nil)
+}
+
+// lockTransition 获取Event锁并等待正在进行的无锁转移完成
+// 直到unlockTransition为止，新到达的无锁转移都会退回到持锁路径，状态只会被持锁的一方改变
+func (f *FSM) lockTransition() {
+	f.eventLock.Lock()
+	f.exclusive.Store(true)
+	// 无锁转移只包含几次查表和一次CAS，不会阻塞，等待的时间很短
+	for f.lockFreeActive.Load() != 0 {
+		runtime.Gosched()
+	}
+}
+
+// unlockTransition 重新允许无锁转移并释放Event锁
+func (f *FSM) unlockTransition() {
+	f.exclusive.Store(false)
+	f.eventLock.Unlock()
+}
+
+// tryLockFree 尝试以无锁快速路径完成一次转移，返回false表示不满足条件，调用方应按持锁的方式处理
+func (f *FSM) tryLockFree(event Event) (result TriggerResult, from, to State, ok bool) {
+	if f.needsLock.Load() || f.observers.Load() != nil || f.metrics.Load() != nil || f.trace.Load() != nil {
+		return 0, 0, 0, false
+	}
+	// 先登记再检查exclusive，与lockTransition先设置exclusive再检查登记数相对应：
+	// 两者之中至少有一方能看到对方，持锁的一方不会与无锁转移同时修改状态
+	f.lockFreeActive.Add(1)
+	defer f.lockFreeActive.Add(-1)
+	if f.exclusive.Load() {
+		return 0, 0, 0, false
+	}
+	for {
+		current := f.CurrentState()
+		next, ok := f.transitionTable.GetNextState(current, event)
+		if !ok {
+			return 0, 0, 0, false
+		}
+		if next, ok = checkNext(next); !ok || f.hasSideEffects(current, next, event) {
+			return 0, 0, 0, false
+		}
+		if atomic.CompareAndSwapInt32(f.statePtr, int32(current), int32(next)) {
+			f.seq.Add(1)
+			f.enteredAt.Store(monotonicNow())
+			f.notifyWaiters(next)
+			if current == next {
+				return SelfTransitioned, current, next, true
+			}
+			return Transitioned, current, next, true
+		}
+		// 状态被并发的转移改变，按新的状态重新判断
+		f.casRetries.Add(1)
+	}
+}
+
+// hasSideEffects 判断从current经event到next的转移是否涉及回调、守卫、接受并忽略的规则、内部转移或父状态
+func (f *FSM) hasSideEffects(current, next State, event Event) bool {
+	t := f.transitionTable
+	if t.GetCallback(BeforeEvent, current, event) != nil || t.GetCallback(LeaveState, current, event) != nil ||
+		t.GetCallback(EnterState, next, event) != nil || t.GetCallback(AfterEvent, current, event) != nil {
+		return true
+	}
+	if gt, ok := t.(GlobalCallbackTable); ok {
+		for cbType := BeforeEvent; cbType <= EnterState; cbType++ {
+			if gt.GetGlobalCallback(cbType) != nil {
+				return true
+			}
+		}
+	}
+	if ct, ok := t.(ContextCallbackTable); ok {
+		if ct.GetContextCallback(BeforeEvent, current, event) != nil || ct.GetContextCallback(LeaveState, current, event) != nil ||
+			ct.GetContextCallback(EnterState, next, event) != nil || ct.GetContextCallback(AfterEvent, current, event) != nil {
+			return true
+		}
+	}
+	if et, ok := t.(ErrCallbackTable); ok {
+		if et.GetErrCallback(BeforeEvent, current, event) != nil || et.GetErrCallback(LeaveState, current, event) != nil {
+			return true
+		}
+	}
+	if gt, ok := t.(GuardTable); ok && gt.GetGuard(current, event) != nil {
+		return true
+	}
+	if ct, ok := t.(ConsumeTable); ok && ct.IsConsumed(current, event) {
+		return true
+	}
+	if it, ok := t.(InternalTable); ok && it.IsInternal(current, event) {
+		return true
+	}
+	if ht, ok := t.(HierarchyTable); ok && (ht.Parent(current) != StateInInit || ht.Parent(next) != StateInInit) {
+		return true
+	}
+	return false
+}
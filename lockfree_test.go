@@ -0,0 +1,183 @@
+package fsm_test
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// createRingTable 三个状态的环，事件0总是转移到下一个状态
+func createRingTable() *fsm.ArrayTransitionTable {
+	return fsm.NewArrayTransitionTable([]fsm.Transition{
+		{From: 0, Event: 0, To: 1},
+		{From: 1, Event: 0, To: 2},
+		{From: 2, Event: 0, To: 0},
+	})
+}
+
+// 测试无锁转移在并发下保持状态与转移次数一致
+func TestLockFreeConcurrent(t *testing.T) {
+	f := fsm.NewFSM(0, 0, createRingTable())
+	f.SetLockFree(true)
+
+	const goroutines, perGoroutine = 8, 1000
+	var accepted atomic.Int64
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perGoroutine {
+				if f.Trigger(0) {
+					accepted.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted.Load() != goroutines*perGoroutine || f.Seq() != uint64(accepted.Load()) {
+		t.Errorf("Expected %d transitions, accepted %d, seq %d", goroutines*perGoroutine, accepted.Load(), f.Seq())
+	}
+	if want := fsm.State(f.Seq() % 3); f.CurrentState() != want {
+		t.Errorf("Expected state %v, got %v", want, f.CurrentState())
+	}
+}
+
+// 测试有副作用的转移仍然持有Event锁执行
+func TestLockFreeFallback(t *testing.T) {
+	table := createRingTable()
+	var entered int
+	table.RegisterCallback(fsm.EnterState, 2, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		entered++
+		// 持锁转移的回调中重入触发的事件仍然进入重入队列
+		if f.TriggerDetailed(0) != fsm.Queued {
+			t.Error("Expected reentrant trigger to be queued")
+		}
+	})
+	f := fsm.NewFSM(0, 0, table)
+	f.SetLockFree(true)
+	f.SetHistorySize(8)
+
+	for range 3 {
+		f.Trigger(0)
+	}
+	// 第三次触发转移到0，随后重入的事件转移到1
+	if entered != 1 || f.CurrentState() != 1 || f.Seq() != 4 {
+		t.Errorf("Expected callback once and state 1, got %d calls, state %v, seq %d", entered, f.CurrentState(), f.Seq())
+	}
+	if got := len(f.History()); got != 4 {
+		t.Errorf("Expected all transitions recorded in history, got %d", got)
+	}
+
+	// 冻结的状态机不走无锁路径
+	f.SetHistorySize(0)
+	f.Pause()
+	if f.TriggerDetailed(0) != fsm.PausedRejected {
+		t.Error("Expected paused FSM to reject triggers")
+	}
+	f.Resume()
+	if !f.Trigger(0) || entered != 2 || f.CurrentState() != 0 {
+		t.Errorf("Expected locked transition after resume, got %d calls, state %v", entered, f.CurrentState())
+	}
+}
+
+// 基准测试：开启无锁快速路径后的并发状态转移性能，与BenchmarkConcurrentStateTransition对比
+func BenchmarkConcurrentStateTransitionLockFree(b *testing.B) {
+	table := createTestTransitionTable()
+	fsmInstance := fsm.NewFSM(0, StateIdle, table)
+	fsmInstance.SetLockFree(true)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			fsmInstance.Trigger(EventStart)
+			fsmInstance.Trigger(EventPause)
+			fsmInstance.Trigger(EventResume)
+			fsmInstance.Trigger(EventStop)
+		}
+	})
+}
+
+// 基准测试：每次触发都能成功转移的高竞争场景，分别使用Event锁和无锁快速路径
+func BenchmarkConcurrentRingTransition(b *testing.B) {
+	for _, lockFree := range []bool{false, true} {
+		name := "Locked"
+		if lockFree {
+			name = "LockFree"
+		}
+		b.Run(name, func(b *testing.B) {
+			fsmInstance := fsm.NewFSM(0, 0, createRingTable())
+			fsmInstance.SetLockFree(lockFree)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					fsmInstance.Trigger(0)
+				}
+			})
+		})
+	}
+}
+
+// 基准测试：开启无锁快速路径后的单线程转移性能，与BenchmarkSuccessfulTransition对比
+func BenchmarkSuccessfulTransitionLockFree(b *testing.B) {
+	table := createTestTransitionTable()
+	fsmInstance := fsm.NewFSM(0, StateRunning, table)
+	fsmInstance.SetLockFree(true)
+	for b.Loop() {
+		fsmInstance.Trigger(EventPause)
+		fsmInstance.Trigger(EventResume)
+	}
+}
+
+// 测试无锁转移与带回调的持锁转移混合时，每个回调对每次成功的转移只执行一次
+func TestLockFreeCallbacksRunOnce(t *testing.T) {
+	// 事件0沿环无锁转移；事件1从任意状态回到0，带有回调，走持锁路径
+	table := fsm.NewArrayTransitionTable([]fsm.Transition{
+		{From: 0, Event: 0, To: 1},
+		{From: 1, Event: 0, To: 2},
+		{From: 2, Event: 0, To: 0},
+		{From: fsm.AnyState, Event: 1, To: 0},
+	})
+	var before, after atomic.Int64
+	for state := fsm.State(0); state < 3; state++ {
+		table.RegisterCallback(fsm.BeforeEvent, state, 1, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+			before.Add(1)
+			// 让出处理器，给并发的无锁转移改变状态的机会
+			runtime.Gosched()
+			if f.CurrentState() != from {
+				t.Errorf("Expected state to stay %v during callbacks, got %v", from, f.CurrentState())
+			}
+		})
+		table.RegisterCallback(fsm.AfterEvent, state, 1, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+			after.Add(1)
+		})
+	}
+	f := fsm.NewFSM(0, 1, table)
+	f.SetLockFree(true)
+
+	var resets atomic.Int64
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				if i%2 == 0 {
+					f.Trigger(0)
+				} else if f.Trigger(1) {
+					resets.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if before.Load() != resets.Load() || after.Load() != resets.Load() {
+		t.Errorf("Expected callbacks once per transition, %d transitions, before %d, after %d (CAS retries %d)",
+			resets.Load(), before.Load(), after.Load(), f.CASRetries())
+	}
+}
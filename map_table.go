@@ -0,0 +1,130 @@
+package fsm
+
+import (
+	"slices"
+	"strconv"
+)
+
+// MapTransitionTable 基于map的状态转移表，适合取值稀疏或包含负数的状态/事件
+// ArrayTransitionTable按最大的状态和事件值分配稠密数组，状态取值为1和1000000时需要一百万个单元格，
+// 而且无法表示负数；MapTransitionTable只为实际定义的转移规则分配空间，代价是查询更慢、GC压力更大。
+// 状态和事件取值稠密时应优先使用ArrayTransitionTable
+type MapTransitionTable struct {
+	table     map[uint64]State // 以packKey(state, event)为键
+	consumed  map[uint64]bool  // 被标记为接受并忽略的(state, event)，没有此类规则时为nil
+	internal  map[uint64]bool  // 被标记为内部转移的(state, event)，没有此类规则时为nil
+	callbacks [4]map[uint64]Handler
+}
+
+// packKey 将(state, event)打包为map的键
+func packKey(state State, event Event) uint64 {
+	return uint64(uint32(state))<<32 | uint64(uint32(event))
+}
+
+// NewMapTransitionTable 创建新的map状态转移表，转移规则的语义与NewArrayTransitionTable相同
+// 通配规则（From为AnyState）在查询时解析：某个状态没有该事件的具体规则时才使用通配规则
+func NewMapTransitionTable(transitions []Transition) *MapTransitionTable {
+	t := &MapTransitionTable{table: make(map[uint64]State, len(transitions))}
+	for _, trans := range transitions {
+		if StateInInit == trans.From || StateInInit == trans.To {
+			panic(strconv.Itoa(int(StateInInit)) + " is invalid state")
+		}
+		key := packKey(trans.From, trans.Event)
+		// 同一(From, Event)重复定义时以后者为准
+		delete(t.consumed, key)
+		delete(t.internal, key)
+		switch {
+		case trans.Consume:
+			if t.consumed == nil {
+				t.consumed = make(map[uint64]bool)
+			}
+			t.consumed[key] = true
+		case trans.Internal:
+			if t.internal == nil {
+				t.internal = make(map[uint64]bool)
+			}
+			t.internal[key] = true
+		}
+		if trans.keepsState() {
+			t.table[key] = trans.From
+		} else {
+			t.table[key] = trans.To
+		}
+	}
+	return t
+}
+
+// GetNextState 获取下一个状态
+func (t *MapTransitionTable) GetNextState(from State, event Event) (State, bool) {
+	next, ok := t.table[t.resolve(from, event)]
+	if !ok {
+		return StateInInit, false
+	}
+	// 接受并忽略或内部转移的通配规则保持当前状态
+	if next == AnyState {
+		next = from
+	}
+	return checkNext(next)
+}
+
+// resolve 获取(from, event)实际使用的规则的键：优先使用具体规则，其次是通配规则
+func (t *MapTransitionTable) resolve(from State, event Event) uint64 {
+	key := packKey(from, event)
+	if _, ok := t.table[key]; ok {
+		return key
+	}
+	return packKey(AnyState, event)
+}
+
+// IsConsumed 判断事件在指定状态下是否被标记为接受并忽略
+func (t *MapTransitionTable) IsConsumed(from State, event Event) bool {
+	return t.consumed != nil && t.consumed[t.resolve(from, event)]
+}
+
+// IsInternal 判断事件在指定状态下是否是内部转移
+func (t *MapTransitionTable) IsInternal(from State, event Event) bool {
+	return t.internal != nil && t.internal[t.resolve(from, event)]
+}
+
+// EventsFrom 获取指定状态下所有定义了转移规则的事件，按升序排列
+// 需要遍历全部转移规则，不适合在热路径上调用
+func (t *MapTransitionTable) EventsFrom(state State) []Event {
+	var events []Event
+	for key := range t.table {
+		if from := State(int32(key >> 32)); from == state || from == AnyState {
+			events = append(events, Event(int32(uint32(key))))
+		}
+	}
+	slices.Sort(events)
+	return slices.Compact(events)
+}
+
+// callbackKey 计算回调的键，LeaveState/EnterState回调只按state存储
+func callbackKey(cbType CallbackType, state State, event Event) uint64 {
+	if cbType == LeaveState || cbType == EnterState {
+		event = 0
+	}
+	return packKey(state, event)
+}
+
+// RegisterCallback 注册回调函数，语义与ArrayTransitionTable.RegisterCallback相同，同一位置的多个回调按注册顺序执行，
+// 未知的回调类型被忽略
+// 与ArrayTransitionTable不同，这里不限制(state, event)的范围
+func (t *MapTransitionTable) RegisterCallback(cbType CallbackType, state State, event Event, handler Handler) {
+	if cbType < BeforeEvent || cbType > EnterState {
+		return
+	}
+	if t.callbacks[cbType] == nil {
+		t.callbacks[cbType] = make(map[uint64]Handler)
+	}
+	key := callbackKey(cbType, state, event)
+	t.callbacks[cbType][key] = chainHandlers(t.callbacks[cbType][key], handler)
+}
+
+// GetCallback 获取回调函数
+func (t *MapTransitionTable) GetCallback(cbType CallbackType, state State, event Event) Handler {
+	if cbType < BeforeEvent || cbType > EnterState {
+		return nil
+	}
+	return t.callbacks[cbType][callbackKey(cbType, state, event)]
+}
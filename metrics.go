@@ -0,0 +1,31 @@
+package fsm
+
+import "time"
+
+// MetricsSink 状态机指标的接收方，由使用者适配到Prometheus、OpenTelemetry等指标库，本包不依赖任何指标库
+// 所有方法都在Trigger的调用路径上同步执行（转移相关的方法调用时持有Event锁），实现应当只做计数等轻量操作
+type MetricsSink interface {
+	// IncTransition 一次成功转移（包括自转移）
+	IncTransition(from, to State, event Event)
+	// IncRejected 一次被拒绝的事件：当前状态下没有转移规则，或者被守卫否决
+	IncRejected(from State, event Event)
+	// ObserveStateDuration 离开state时在其中停留的时长，自转移也会结束一次停留
+	ObserveStateDuration(state State, d time.Duration)
+}
+
+// SetMetricsSink 设置指标接收方，传入nil时关闭指标上报（默认关闭）
+func (f *FSM) SetMetricsSink(sink MetricsSink) {
+	if sink == nil {
+		f.metrics.Store(nil)
+		return
+	}
+	f.metrics.Store(&sink)
+}
+
+// metricsSink 获取当前的指标接收方，未设置时返回nil
+func (f *FSM) metricsSink() MetricsSink {
+	if sink := f.metrics.Load(); sink != nil {
+		return *sink
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+type countingSink struct {
+	transitions int
+	rejected    int
+	durations   map[fsm.State]time.Duration
+}
+
+func (s *countingSink) IncTransition(from, to fsm.State, event fsm.Event) { s.transitions++ }
+func (s *countingSink) IncRejected(from fsm.State, event fsm.Event)       { s.rejected++ }
+func (s *countingSink) ObserveStateDuration(state fsm.State, d time.Duration) {
+	s.durations[state] += d
+}
+
+// 测试指标上报
+func TestMetricsSink(t *testing.T) {
+	table := createTestTransitionTable()
+	table.RegisterGuard(StateRunning, EventStop, func(*fsm.FSM, fsm.State, fsm.Event, ...any) bool { return false })
+	f := fsm.NewFSM(0, StateIdle, table)
+	sink := &countingSink{durations: make(map[fsm.State]time.Duration)}
+	f.SetMetricsSink(sink)
+
+	time.Sleep(time.Millisecond)
+	f.Trigger(EventStart)
+	f.Trigger(EventStart) // 没有转移规则
+	f.Trigger(EventStop)  // 被守卫否决
+	f.Trigger(EventPause)
+	if sink.transitions != 2 || sink.rejected != 2 {
+		t.Errorf("Expected 2 transitions and 2 rejections, got %d and %d", sink.transitions, sink.rejected)
+	}
+	if sink.durations[StateIdle] < time.Millisecond || len(sink.durations) != 2 {
+		t.Errorf("Unexpected state durations %v", sink.durations)
+	}
+
+	f.SetMetricsSink(nil)
+	f.Trigger(EventResume)
+	if sink.transitions != 2 {
+		t.Errorf("Expected metrics to stop after removing sink, got %d", sink.transitions)
+	}
+}
@@ -0,0 +1,49 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrMirrorDiverged 备用状态机无法跟随主状态机的转移
+var ErrMirrorDiverged = errors.New("mirror diverged")
+
+// Mirror 让standby作为热备状态机与f保持同步
+// f每次成功转移后，都会在持有f的Event锁的情况下对standby执行同一事件（包括回调和参数）。
+// standby无法接受该事件或者转移后的状态与f不一致时（例如两者的转移表拓扑不同），
+// 通过onError报告ErrMirrorDiverged，onError可以为nil。
+// standby与普通的触发一样：被Pause冻结时拒绝该事件（按SetQueueWhilePaused放入队列）并报告为不一致，
+// 回调中重入触发standby的事件在standby的转移完成、释放standby的锁之后处理。
+// 同步过程依次持有f和standby的锁，不能让两个状态机相互镜像，也不能在standby的回调中触发f
+func (f *FSM) Mirror(standby *FSM, onError func(err error)) {
+	f.addListener(func(tc TransitionContext) {
+		result, state, err := standby.follow(tc.Ctx, tc.Event, tc.Args)
+
+		if onError == nil {
+			return
+		}
+		if err != nil {
+			onError(fmt.Errorf("%w: standby %d aborted event %v: %w",
+				ErrMirrorDiverged, standby.ID(), tc.Event, err))
+		} else if !result.Accepted() {
+			onError(fmt.Errorf("%w: standby %d rejected event %v in state %v",
+				ErrMirrorDiverged, standby.ID(), tc.Event, state))
+		} else if state != tc.To {
+			onError(fmt.Errorf("%w: standby %d moved to %v, primary %d moved to %v",
+				ErrMirrorDiverged, standby.ID(), state, f.ID(), tc.To))
+		}
+	})
+}
+
+// follow 在主状态机的转移监听器中对f执行同一事件，返回结果和f转移后的状态
+// 与dispatch一样检查冻结状态并标记正在转移，但不做预检查、拒绝回调和延迟处理，由Mirror统一报告；
+// 转移完成并释放f的锁之后处理重入队列，此时仍位于主状态机的转移中，按Mirror约定的加锁顺序允许嵌套
+func (f *FSM) follow(ctx context.Context, event Event, args []any) (TriggerResult, State, error) {
+	defer f.drain(ctx, true)
+	if f.paused.Load() && f.holdWhilePaused(event, args) {
+		return PausedRejected, f.CurrentState(), nil
+	}
+	result, _, to, err := f.lockedFire(ctx, event, args)
+	return result, to, err
+}
@@ -0,0 +1,67 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试热备状态机跟随主状态机转移
+func TestMirror(t *testing.T) {
+	table := createTestTransitionTable()
+	primary := fsm.NewFSM(1, StateIdle, table)
+	standby := fsm.NewFSM(2, StateIdle, table)
+
+	var errs []error
+	primary.Mirror(standby, func(err error) { errs = append(errs, err) })
+
+	primary.Trigger(EventStart)
+	primary.Trigger(EventPause)
+	if standby.CurrentState() != StatePaused {
+		t.Errorf("Expected standby state %d, got %d", StatePaused, standby.CurrentState())
+	}
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	// 备用状态机无法跟随时报告错误
+	diverged := fsm.NewFSM(3, StateStopped, table)
+	primary.Mirror(diverged, func(err error) { errs = append(errs, err) })
+	primary.Trigger(EventResume)
+	if len(errs) != 1 || !errors.Is(errs[0], fsm.ErrMirrorDiverged) {
+		t.Errorf("Expected one ErrMirrorDiverged, got %v", errs)
+	}
+	if standby.CurrentState() != StateRunning {
+		t.Errorf("Expected healthy standby to keep following, got %d", standby.CurrentState())
+	}
+}
+
+// 测试备用状态机与普通的触发一样处理冻结和回调中的重入触发
+func TestMirrorPausedAndReentrant(t *testing.T) {
+	table := createTestTransitionTable()
+	standbyTable := createTestTransitionTable()
+	standbyTable.RegisterCallback(fsm.EnterState, StatePaused, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		f.Trigger(EventStop)
+	})
+	primary := fsm.NewFSM(1, StateIdle, table)
+	standby := fsm.NewFSM(2, StateIdle, standbyTable)
+
+	var errs []error
+	primary.Mirror(standby, func(err error) { errs = append(errs, err) })
+
+	// 冻结的备用状态机不跟随转移
+	standby.Pause()
+	primary.Trigger(EventStart)
+	if standby.CurrentState() != StateIdle || len(errs) != 1 || !errors.Is(errs[0], fsm.ErrMirrorDiverged) {
+		t.Fatalf("Expected paused standby to diverge, got state %d, errors %v", standby.CurrentState(), errs)
+	}
+
+	// 重入触发的事件在备用状态机的转移完成之后处理
+	standby.Resume()
+	standby.Trigger(EventStart)
+	primary.Trigger(EventPause)
+	if standby.CurrentState() != StateStopped || len(errs) != 1 {
+		t.Errorf("Expected reentrant EventStop to run on standby, got state %d, errors %v", standby.CurrentState(), errs)
+	}
+}
@@ -0,0 +1,141 @@
+package fsm
+
+import (
+	"cmp"
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// TriggerAll 对多个状态机原子地触发同一事件：要么全部转移，要么全部保持不变
+// 按指针地址的稳定顺序获取所有状态机的Event锁以避免死锁，在锁内确认每个状态机都能接受该事件后
+// 再依次提交转移。校验与Trigger的规则相同：没有转移规则、目标状态无效或被守卫否决时整体失败，
+// 接受并忽略的状态机视为已接受但不执行回调；守卫在校验阶段对每个状态机只执行一次。
+// 由于是整体失败，被Pause冻结的状态机同样使整体失败，但事件不会按SetQueueWhilePaused放入队列；
+// 可延迟的事件也不会放入延迟队列，拒绝回调和严格模式同样不生效，由调用方根据返回值处理。
+// 回调在持有全部锁的情况下执行：回调中触发该状态机自身时与Trigger一样放入重入队列，
+// 不能触发其他参与的状态机。释放全部锁之后，按加锁顺序依次处理各状态机的重入队列和延迟队列。
+//
+// 提交阶段只有ErrHandler可能中止转移。此时返回false，已经提交的状态机被直接恢复为调用前的状态，
+// 与TriggerSequence相同：恢复过程不执行任何回调，已经执行过的回调、观察者通知和转移历史也不会撤销。
+func TriggerAll(fsms []*FSM, event Event, args ...any) bool {
+	// 排序并去重，重复的状态机只加锁一次
+	sorted := slices.Clone(fsms)
+	slices.SortFunc(sorted, func(a, b *FSM) int {
+		return cmp.Compare(uintptr(unsafe.Pointer(a)), uintptr(unsafe.Pointer(b)))
+	})
+	sorted = slices.Compact(sorted)
+	if len(sorted) > 0 && sorted[0] == nil {
+		return false
+	}
+
+	ok := triggerAllLocked(sorted, event, args)
+	ctx := context.Background()
+	for _, f := range sorted {
+		f.drainReentrant(ctx)
+	}
+	return ok
+}
+
+// triggerAllLocked 获取sorted中所有状态机的Event锁，校验并提交转移，见TriggerAll
+func triggerAllLocked(sorted []*FSM, event Event, args []any) bool {
+	for _, f := range sorted {
+		f.lockTransition()
+		defer f.unlockTransition()
+		f.firing.Store(true)
+		defer f.endFiring()
+	}
+
+	// 校验阶段：任意一个状态机被冻结、不能接受事件或被守卫否决则整体失败，守卫只在这里执行一次
+	type planned struct {
+		f *FSM
+		p transitionPlan
+	}
+	plans := make([]planned, 0, len(sorted))
+	for _, f := range sorted {
+		if f.IsPaused() {
+			return false
+		}
+		p, result, ok := f.plan(f.CurrentState(), event, args)
+		if !ok {
+			if result != Consumed {
+				return false
+			}
+			// 接受并忽略的状态机视为已接受，提交阶段无需处理
+			continue
+		}
+		plans = append(plans, planned{f: f, p: p})
+	}
+
+	// 提交阶段：按加锁顺序依次执行校验得到的转移，被ErrHandler中止时恢复已经提交的状态机
+	for i, planned := range plans {
+		if result, _ := planned.f.firePlan(context.Background(), planned.p, event, args); !result.Accepted() {
+			for _, committed := range plans[:i] {
+				committed.f.resetLocked(committed.p.from)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Broadcast 对池中当前所有已分配的状态机依次触发event，返回执行了转移（包括自转移和内部转移）的数量
+// 已分配的实例在池锁内一次性取得快照，触发在释放池锁之后进行，回调中可以正常分配和释放实例；
+// 快照之后才分配的实例不会收到事件，期间被释放的实例仍会被触发（已被重置时通常不再匹配转移规则），
+// 调用方应当避免在广播的同时释放并重新分配实例。适合按tick驱动的游戏、仿真循环
+func (p *FsmPool) Broadcast(event Event, args ...any) int {
+	transitioned := 0
+	for _, f := range p.allocated() {
+		switch f.TriggerDetailed(event, args...) {
+		case Transitioned, SelfTransitioned, InternalTransitioned:
+			transitioned++
+		}
+	}
+	return transitioned
+}
+
+// FSMEvent 一个待触发的(状态机, 事件)对
+type FSMEvent struct {
+	FSM   *FSM
+	Event Event
+}
+
+// batchParallelThreshold 批量触发时启用并行处理的最小数量
+const batchParallelThreshold = 1024
+
+// TriggerBatch 批量地对不同状态机触发各自的事件，返回每一对是否转移成功
+// 数量较大时会按GOMAXPROCS分段并行处理：各状态机的锁相互独立，可以真正并发执行。
+// 并行时共享状态转移表上的回调会被并发调用，必须是并发安全的；
+// 同一状态机在批次中出现多次时，只有串行处理（数量低于阈值）才能保证按顺序触发。
+// FSM为nil的项返回false
+func TriggerBatch(pairs []FSMEvent, args ...any) []bool {
+	results := make([]bool, len(pairs))
+	workers := min(runtime.GOMAXPROCS(0), len(pairs)/batchParallelThreshold+1)
+	if workers <= 1 {
+		triggerRange(pairs, results, args)
+		return results
+	}
+
+	var wg sync.WaitGroup
+	chunk := (len(pairs) + workers - 1) / workers
+	for start := 0; start < len(pairs); start += chunk {
+		end := min(start+chunk, len(pairs))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			triggerRange(pairs[start:end], results[start:end], args)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func triggerRange(pairs []FSMEvent, results []bool, args []any) {
+	for i, pair := range pairs {
+		if pair.FSM != nil {
+			results[i] = pair.FSM.Trigger(pair.Event, args...)
+		}
+	}
+}
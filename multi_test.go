@@ -0,0 +1,166 @@
+package fsm_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试多状态机原子触发
+func TestTriggerAll(t *testing.T) {
+	table := createTestTransitionTable()
+	fsm1 := fsm.NewFSM(1, StateIdle, table)
+	fsm2 := fsm.NewFSM(2, StateIdle, table)
+
+	// 重复出现的状态机不会导致死锁
+	if !fsm.TriggerAll([]*fsm.FSM{fsm1, fsm2, fsm1}, EventStart) {
+		t.Error("Expected TriggerAll to succeed")
+	}
+	if fsm1.CurrentState() != StateRunning || fsm2.CurrentState() != StateRunning {
+		t.Errorf("Expected both running, got %d and %d", fsm1.CurrentState(), fsm2.CurrentState())
+	}
+
+	// 任意一个不能转移时，全部保持不变
+	fsm2.Trigger(EventStop)
+	if fsm.TriggerAll([]*fsm.FSM{fsm1, fsm2}, EventPause) {
+		t.Error("Expected TriggerAll to fail")
+	}
+	if fsm1.CurrentState() != StateRunning || fsm2.CurrentState() != StateStopped {
+		t.Errorf("Expected states unchanged, got %d and %d", fsm1.CurrentState(), fsm2.CurrentState())
+	}
+}
+
+// 测试TriggerAll对每个状态机只执行一次守卫，守卫否决时整体失败
+func TestTriggerAllGuardOnce(t *testing.T) {
+	table := createTestTransitionTable()
+	calls := map[*fsm.FSM]int{}
+	allow := true
+	table.RegisterGuard(StateIdle, EventStart, func(f *fsm.FSM, from fsm.State, event fsm.Event, args ...any) bool {
+		calls[f]++
+		return allow || f.ID() == 1
+	})
+	fsm1 := fsm.NewFSM(1, StateIdle, table)
+	fsm2 := fsm.NewFSM(2, StateIdle, table)
+
+	allow = false
+	if fsm.TriggerAll([]*fsm.FSM{fsm1, fsm2}, EventStart) {
+		t.Error("Expected TriggerAll to fail when a guard vetoes")
+	}
+	if fsm1.CurrentState() != StateIdle || fsm2.CurrentState() != StateIdle {
+		t.Errorf("Expected states unchanged, got %d and %d", fsm1.CurrentState(), fsm2.CurrentState())
+	}
+
+	allow = true
+	clear(calls)
+	if !fsm.TriggerAll([]*fsm.FSM{fsm1, fsm2}, EventStart) {
+		t.Error("Expected TriggerAll to succeed")
+	}
+	if calls[fsm1] != 1 || calls[fsm2] != 1 {
+		t.Errorf("Expected each guard to run once, got %d and %d", calls[fsm1], calls[fsm2])
+	}
+}
+
+// 测试提交阶段被ErrHandler中止时恢复已经提交的状态机
+func TestTriggerAllRollback(t *testing.T) {
+	table := createTestTransitionTable()
+	// 加锁顺序取决于指针地址，让第二个提交的状态机被中止
+	calls := 0
+	table.RegisterErrCallback(fsm.BeforeEvent, StateIdle, EventStart, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) error {
+		calls++
+		if calls == 2 {
+			return errors.New("refused")
+		}
+		return nil
+	})
+	fsm1 := fsm.NewFSM(1, StateIdle, table)
+	fsm2 := fsm.NewFSM(2, StateIdle, table)
+
+	if fsm.TriggerAll([]*fsm.FSM{fsm1, fsm2}, EventStart) {
+		t.Error("Expected TriggerAll to fail when an ErrHandler aborts")
+	}
+	if fsm1.CurrentState() != StateIdle || fsm2.CurrentState() != StateIdle {
+		t.Errorf("Expected rollback to StateIdle, got %d and %d", fsm1.CurrentState(), fsm2.CurrentState())
+	}
+}
+
+// 测试TriggerAll完成后处理重入触发的事件和延迟事件
+func TestTriggerAllDrains(t *testing.T) {
+	table := createTestTransitionTable()
+	fsm1 := fsm.NewFSM(1, StateIdle, table)
+	fsm2 := fsm.NewFSM(2, StateIdle, table)
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		if f == fsm1 && from == StateIdle {
+			if f.TriggerDetailed(EventPause) != fsm.Queued {
+				t.Error("Expected reentrant trigger to be queued")
+			}
+		}
+	})
+	// EventStop在StateIdle下无效，进入StateRunning后生效
+	fsm2.PostDeferred(EventStop)
+
+	if !fsm.TriggerAll([]*fsm.FSM{fsm1, fsm2}, EventStart) {
+		t.Fatal("Expected TriggerAll to succeed")
+	}
+	if fsm1.CurrentState() != StatePaused || fsm2.CurrentState() != StateStopped || fsm2.DeferredEvents() != 0 {
+		t.Errorf("Expected queued and deferred events to run, got %d and %d", fsm1.CurrentState(), fsm2.CurrentState())
+	}
+}
+
+// 测试批量触发
+func TestTriggerBatch(t *testing.T) {
+	table := createTestTransitionTable()
+	fsm1 := fsm.NewFSM(1, StateIdle, table)
+	fsm2 := fsm.NewFSM(2, StateRunning, table)
+
+	results := fsm.TriggerBatch([]fsm.FSMEvent{
+		{FSM: fsm1, Event: EventStart},
+		{FSM: fsm2, Event: EventStart},
+		{FSM: fsm1, Event: EventPause},
+		{FSM: nil, Event: EventStop},
+	})
+	want := []bool{true, false, true, false}
+	if !slices.Equal(results, want) {
+		t.Errorf("Expected %v, got %v", want, results)
+	}
+
+	// 大批量时并行处理
+	fsms := fsm.NewFSMs(5000, 0, StateIdle, table)
+	pairs := make([]fsm.FSMEvent, len(fsms))
+	for i, f := range fsms {
+		pairs[i] = fsm.FSMEvent{FSM: f, Event: EventStart}
+	}
+	for i, ok := range fsm.TriggerBatch(pairs) {
+		if !ok || fsms[i].CurrentState() != StateRunning {
+			t.Fatalf("Expected FSM #%d to transition in parallel batch", i)
+		}
+	}
+}
+
+// 测试对池中所有已分配的状态机广播事件
+func TestPoolBroadcast(t *testing.T) {
+	table := createTestTransitionTable()
+	pool := fsm.NewFsmPool(10, StateIdle, table)
+	fsms := pool.AllocateN(4)
+	fsms[0].Trigger(EventStart)
+
+	// 回调中分配和释放实例不会因为池锁而死锁
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		if extra := pool.Allocate(); extra != nil {
+			pool.Release(extra)
+		}
+	})
+
+	// 已在Running的实例不能再次Start，未分配的实例不受影响
+	if n := pool.Broadcast(EventStart); n != 3 {
+		t.Errorf("Expected 3 transitions, got %d", n)
+	}
+	counts := pool.CountByState()
+	if counts[StateRunning] != 4 || len(counts) != 1 {
+		t.Errorf("Expected all 4 allocated instances running, got %v", counts)
+	}
+	if n := pool.Broadcast(EventResume); n != 0 {
+		t.Errorf("Expected rejected broadcast to count 0, got %d", n)
+	}
+}
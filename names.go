@@ -0,0 +1,66 @@
+package fsm
+
+import (
+	"maps"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// namesMu 串行化名称的注册，查询不加锁
+	namesMu    sync.Mutex
+	stateNames atomic.Pointer[map[State]string]
+	eventNames atomic.Pointer[map[Event]string]
+)
+
+// RegisterStateNames 注册状态的可读名称，供State.String使用，已注册的同名状态会被覆盖
+// 名称是全局的，通常在init中一次性注册；注册是并发安全的，但注册期间打印的名称可能仍是旧值
+func RegisterStateNames(names map[State]string) {
+	registerNames(&stateNames, names)
+}
+
+// RegisterEventNames 注册事件的可读名称，供Event.String使用，已注册的同名事件会被覆盖
+func RegisterEventNames(names map[Event]string) {
+	registerNames(&eventNames, names)
+}
+
+// registerNames 以写时复制的方式将names并入registry
+func registerNames[K comparable](registry *atomic.Pointer[map[K]string], names map[K]string) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	next := make(map[K]string)
+	if old := registry.Load(); old != nil {
+		maps.Copy(next, *old)
+	}
+	maps.Copy(next, names)
+	registry.Store(&next)
+}
+
+// String 返回状态的可读名称：优先使用RegisterStateNames注册的名称，
+// 其次是StateInInit、AnyState这两个哨兵的名称，都没有时返回"state(N)"。
+// State.String可以直接作为ToMermaid的StateNamer使用
+func (s State) String() string {
+	if names := stateNames.Load(); names != nil {
+		if name, ok := (*names)[s]; ok {
+			return name
+		}
+	}
+	switch s {
+	case StateInInit:
+		return "StateInInit"
+	case AnyState:
+		return "AnyState"
+	}
+	return "state(" + strconv.Itoa(int(s)) + ")"
+}
+
+// String 返回事件的可读名称：优先使用RegisterEventNames注册的名称，没有时返回"event(N)"
+func (e Event) String() string {
+	if names := eventNames.Load(); names != nil {
+		if name, ok := (*names)[e]; ok {
+			return name
+		}
+	}
+	return "event(" + strconv.Itoa(int(e)) + ")"
+}
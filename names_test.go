@@ -0,0 +1,82 @@
+package fsm_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 名称注册表是全局的，测试使用其他测试不会用到的状态和事件值
+const (
+	namedIdle fsm.State = 900 + iota
+	namedBusy
+	namedUnnamed
+)
+
+const (
+	namedGo fsm.Event = 900 + iota
+	namedUnnamedEvent
+)
+
+// 测试注册名称后State和Event的打印结果
+func TestStateEventNames(t *testing.T) {
+	fsm.RegisterStateNames(map[fsm.State]string{namedIdle: "Idle", namedBusy: "Bsy"})
+	fsm.RegisterStateNames(map[fsm.State]string{namedBusy: "Busy"})
+	fsm.RegisterEventNames(map[fsm.Event]string{namedGo: "Go"})
+
+	cases := []struct {
+		value fmt.Stringer
+		want  string
+	}{
+		{namedIdle, "Idle"},
+		{namedBusy, "Busy"},
+		{namedUnnamed, "state(902)"},
+		{fsm.StateInInit, "StateInInit"},
+		{fsm.AnyState, "AnyState"},
+		{namedGo, "Go"},
+		{namedUnnamedEvent, "event(901)"},
+	}
+	for _, c := range cases {
+		if got := c.value.String(); got != c.want {
+			t.Errorf("Expected %q, got %q", c.want, got)
+		}
+	}
+	if got := fmt.Sprintf("%v --%v--> %v", namedIdle, namedGo, namedBusy); got != "Idle --Go--> Busy" {
+		t.Errorf("Unexpected formatting %q", got)
+	}
+	// %d仍然输出数值
+	if got := fmt.Sprintf("%d", namedIdle); got != "900" {
+		t.Errorf("Expected %%d to print the number, got %q", got)
+	}
+}
+
+// 测试错误信息使用注册的名称
+func TestNamesInErrors(t *testing.T) {
+	fsm.RegisterStateNames(map[fsm.State]string{namedIdle: "Idle"})
+	table := fsm.NewArrayTransitionTable([]fsm.Transition{{From: 0, Event: 0, To: 1}})
+	err := table.SetParent(namedIdle, 0)
+	if !errors.Is(err, fsm.ErrOutOfRange) || !strings.Contains(err.Error(), "state Idle") {
+		t.Errorf("Expected error to mention Idle, got %v", err)
+	}
+}
+
+// 测试并发注册和查询
+func TestConcurrentNameRegistration(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 100 {
+			fsm.RegisterEventNames(map[fsm.Event]string{fsm.Event(1000 + i): fmt.Sprint("e", i)})
+		}
+	}()
+	for range 100 {
+		_ = namedGo.String()
+	}
+	<-done
+	if got := fsm.Event(1099).String(); got != "e99" {
+		t.Errorf("Expected e99, got %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+package fsm
+
+import "slices"
+
+// Observer 转移观察者，每次成功转移（包括自转移）后收到通知，适合埋点、审计日志等横切关注点
+type Observer interface {
+	OnTransition(fsm *FSM, from, to State, event Event)
+}
+
+// ObserverFunc 将普通函数适配为Observer
+// 函数不可比较，以ObserverFunc注册的观察者不能通过RemoveObserver移除
+type ObserverFunc func(fsm *FSM, from, to State, event Event)
+
+// OnTransition 调用fn本身
+func (fn ObserverFunc) OnTransition(fsm *FSM, from, to State, event Event) {
+	fn(fsm, from, to, event)
+}
+
+// AddObserver 注册转移观察者，同一观察者注册多次会收到多次通知
+// 观察者在所有回调和内部监听器之后按注册顺序调用。为保证观察者看到的转移顺序与实际顺序一致，
+// 调用时持有Event锁：观察者应尽快返回，在其中触发本状态机的事件会在当前转移完成后处理。
+// 注册和移除不获取Event锁，可以在回调或观察者中调用，从下一次转移开始生效
+func (f *FSM) AddObserver(o Observer) {
+	for {
+		old := f.observers.Load()
+		var next []Observer
+		if old != nil {
+			next = slices.Clone(*old)
+		}
+		next = append(next, o)
+		if f.observers.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
+
+// RemoveObserver 移除最早注册的一个o，返回是否找到
+// 观察者按==比较，因此应当使用指针等可比较的类型实现Observer，与已注册的ObserverFunc比较时会panic
+func (f *FSM) RemoveObserver(o Observer) bool {
+	for {
+		old := f.observers.Load()
+		if old == nil {
+			return false
+		}
+		i := slices.Index(*old, o)
+		if i < 0 {
+			return false
+		}
+		var next *[]Observer
+		if len(*old) > 1 {
+			remaining := slices.Delete(slices.Clone(*old), i, i+1)
+			next = &remaining
+		}
+		if f.observers.CompareAndSwap(old, next) {
+			return true
+		}
+	}
+}
+
+// notifyObservers 通知所有转移观察者，调用方持有Event锁
+func (f *FSM) notifyObservers(from, to State, event Event) {
+	if observers := f.observers.Load(); observers != nil {
+		for _, o := range *observers {
+			o.OnTransition(f, from, to, event)
+		}
+	}
+}
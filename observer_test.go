@@ -0,0 +1,56 @@
+package fsm_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+type recordingObserver struct {
+	name string
+	log  *[]string
+}
+
+func (o *recordingObserver) OnTransition(f *fsm.FSM, from, to fsm.State, event fsm.Event) {
+	*o.log = append(*o.log, fmt.Sprintf("%s:%d-%d->%d", o.name, from, event, to))
+}
+
+// 测试转移观察者
+func TestObserver(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	var log []string
+	a := &recordingObserver{name: "a", log: &log}
+	b := &recordingObserver{name: "b", log: &log}
+	f.AddObserver(a)
+	f.AddObserver(b)
+
+	f.Trigger(EventStart)
+	f.Trigger(EventStart) // 被拒绝的事件不通知
+	if want := []string{"a:0-0->1", "b:0-0->1"}; !slices.Equal(log, want) {
+		t.Errorf("Expected %v, got %v", want, log)
+	}
+
+	if !f.RemoveObserver(a) || f.RemoveObserver(a) {
+		t.Error("Expected observer to be removed exactly once")
+	}
+	log = nil
+	f.Trigger(EventPause)
+	if want := []string{"b:1-1->2"}; !slices.Equal(log, want) {
+		t.Errorf("Expected %v, got %v", want, log)
+	}
+
+	// 在观察者中触发本状态机的事件，会在当前转移完成后处理
+	f.AddObserver(fsm.ObserverFunc(func(f *fsm.FSM, from, to fsm.State, event fsm.Event) {
+		if to == StatePaused {
+			f.Trigger(EventResume)
+		}
+	}))
+	log = nil
+	f.Trigger(EventResume)
+	f.Trigger(EventPause)
+	if f.CurrentState() != StateRunning || len(log) != 3 {
+		t.Errorf("Expected nested trigger to run after observer, got %v in %v", log, f.CurrentState())
+	}
+}
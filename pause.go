@@ -0,0 +1,56 @@
+package fsm
+
+import "errors"
+
+// ErrPaused 状态机已被Pause冻结
+var ErrPaused = errors.New("fsm paused")
+
+// Pause 冻结状态机：在Resume之前，所有触发都会被拒绝，既不执行回调也不改变状态
+// 这是一种带外的管理操作，与状态机自身定义的"暂停"状态无关。
+// 冻结期间ProcessQueue不会处理事件队列，已投递的事件保留到解冻之后
+func (f *FSM) Pause() {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	f.paused.Store(true)
+}
+
+// Resume 解冻状态机
+// 如果开启了SetQueueWhilePaused，冻结期间被拒绝的事件会在当前goroutine上按原顺序重放，
+// 返回前完成重放
+func (f *FSM) Resume() {
+	f.queueLock.Lock()
+	f.paused.Store(false)
+	replay := f.queueWhilePaused
+	f.queueLock.Unlock()
+
+	if replay {
+		f.ProcessQueue()
+	}
+}
+
+// IsPaused 判断状态机是否被冻结
+func (f *FSM) IsPaused() bool {
+	return f.paused.Load()
+}
+
+// SetQueueWhilePaused 设置冻结期间被拒绝的事件是否放入事件队列，并在Resume时重放
+// 默认关闭，即冻结期间的事件直接丢弃
+func (f *FSM) SetQueueWhilePaused(queue bool) {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	f.queueWhilePaused = queue
+}
+
+// holdWhilePaused 在持有队列锁的情况下再次确认冻结状态，避免与Resume竞争时丢失事件
+// 返回true表示状态机仍处于冻结中，事件已被拒绝（按需放入队列）
+func (f *FSM) holdWhilePaused(event Event, args []any) bool {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	if !f.paused.Load() {
+		return false
+	}
+	if f.queueWhilePaused {
+		f.queue = append(f.queue, postedEvent{event: event, args: args})
+	}
+	return true
+}
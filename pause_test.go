@@ -0,0 +1,57 @@
+package fsm_test
+
+import (
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试冻结与解冻
+func TestPauseResume(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateIdle, table)
+
+	var calls int
+	table.RegisterCallback(fsm.BeforeEvent, StateIdle, EventStart, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		calls++
+	})
+
+	f.Pause()
+	if !f.IsPaused() {
+		t.Error("Expected FSM to be paused")
+	}
+	if f.Trigger(EventStart) || f.TriggerDetailed(EventStart) != fsm.PausedRejected {
+		t.Error("Expected triggers to be rejected while paused")
+	}
+	if calls != 0 || f.CurrentState() != StateIdle {
+		t.Errorf("Expected no effect while paused, calls %d, state %d", calls, f.CurrentState())
+	}
+	if fsm.TriggerAll([]*fsm.FSM{f}, EventStart) {
+		t.Error("Expected TriggerAll to reject paused FSMs")
+	}
+
+	f.Resume()
+	if !f.Trigger(EventStart) || calls != 1 {
+		t.Errorf("Expected trigger to work after resume, calls %d", calls)
+	}
+}
+
+// 测试冻结期间的事件在解冻时重放
+func TestPauseQueueReplay(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	f.SetQueueWhilePaused(true)
+
+	f.Pause()
+	f.Trigger(EventStart)
+	f.Trigger(EventPause)
+	f.Post(EventResume)
+	// 冻结期间不处理队列
+	if f.ProcessQueue() != 0 || f.PendingEvents() != 3 {
+		t.Errorf("Expected queue to be held while paused, pending %d", f.PendingEvents())
+	}
+
+	f.Resume()
+	if f.CurrentState() != StateRunning || f.PendingEvents() != 0 {
+		t.Errorf("Expected replay to reach state %d, got %d (pending %d)", StateRunning, f.CurrentState(), f.PendingEvents())
+	}
+}
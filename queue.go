@@ -0,0 +1,143 @@
+package fsm
+
+import (
+	"cmp"
+	"slices"
+)
+
+// QueueMode 事件队列模式
+type QueueMode int32
+
+const (
+	// QueueFIFO 按投递顺序保留并处理所有事件
+	QueueFIFO QueueMode = iota
+	// QueueLatestPerEvent 每种事件只保留最新投递的一个（参数也以最新的为准），
+	// 处理时按事件优先级从高到低、同优先级按事件值升序执行，适合渲染循环等只关心最终输入的高频场景
+	QueueLatestPerEvent
+	// QueuePriority 保留所有事件，处理时按事件优先级从高到低执行，同优先级按投递顺序执行
+	QueuePriority
+)
+
+// SetEventPriority 设置事件的静态优先级，数值越大越优先，未设置的事件优先级为0
+// 优先级是事件类型的固定属性，与由谁、何时投递无关：例如EventStop总是先于EventTick处理。
+// 只影响QueuePriority和QueueLatestPerEvent模式下事件队列的处理顺序，事件队列不支持按单次投递指定优先级。
+// 应在使用状态转移表之前设置
+func (t *ArrayTransitionTable) SetEventPriority(event Event, priority int) {
+	if event < 0 || int32(event) >= t.maxEvents {
+		return
+	}
+	if t.priorities == nil {
+		t.priorities = make([]int, t.maxEvents)
+	}
+	t.priorities[event] = priority
+}
+
+// EventPriority 获取事件的静态优先级
+func (t *ArrayTransitionTable) EventPriority(event Event) int {
+	if event < 0 || int(event) >= len(t.priorities) {
+		return 0
+	}
+	return t.priorities[event]
+}
+
+// postedEvent 投递到队列中的事件
+type postedEvent struct {
+	event Event
+	args  []any
+	post  bool   // 由PostDeferred在回调中投递，处理时按PostDeferred的规则决定触发还是延迟
+	apply func() // 不为nil时不是事件，而是在回调中调用、推迟到转移完成后执行的设置（如SetTimeout）
+}
+
+// SetQueueMode 设置事件队列模式
+// 切换到QueueLatestPerEvent时，队列中已有的重复事件会在下一次处理时合并
+func (f *FSM) SetQueueMode(mode QueueMode) {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	f.queueMode = mode
+}
+
+// Post 将事件投递到状态机的事件队列中，事件不会立即处理，而是在下一次ProcessQueue时执行
+// Post不获取Event锁，可以在回调中安全调用
+func (f *FSM) Post(event Event, args ...any) {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	if f.queueMode == QueueLatestPerEvent {
+		for i := range f.queue {
+			if f.queue[i].event == event {
+				f.queue[i].args = args
+				return
+			}
+		}
+	}
+	f.queue = append(f.queue, postedEvent{event: event, args: args})
+}
+
+// PendingEvents 获取事件队列中等待处理的事件数量
+func (f *FSM) PendingEvents() int {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	return len(f.queue)
+}
+
+// ProcessQueue 在当前goroutine上依次触发事件队列中的事件，返回被接受的事件数量
+// 处理期间新投递的事件留到下一次ProcessQueue；状态机被Pause冻结时不做任何处理，返回0
+func (f *FSM) ProcessQueue() int {
+	f.queueLock.Lock()
+	if f.paused.Load() {
+		f.queueLock.Unlock()
+		return 0
+	}
+	pending := f.queue
+	f.queue = nil
+	switch f.queueMode {
+	case QueueLatestPerEvent:
+		// 稳定排序后同一事件的多次投递相邻，保留最后一次
+		slices.SortStableFunc(pending, func(a, b postedEvent) int {
+			if c := f.comparePriority(a, b); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.event, b.event)
+		})
+		pending = compactLatest(pending)
+	case QueuePriority:
+		slices.SortStableFunc(pending, f.comparePriority)
+	}
+	f.queueLock.Unlock()
+
+	accepted := 0
+	for _, posted := range pending {
+		if f.Trigger(posted.event, posted.args...) {
+			accepted++
+		}
+	}
+
+	// 复用队列的底层数组，减少内存分配
+	clear(pending)
+	f.queueLock.Lock()
+	if f.queue == nil {
+		f.queue = pending[:0]
+	}
+	f.queueLock.Unlock()
+	return accepted
+}
+
+// comparePriority 按事件优先级从高到低比较，状态转移表不支持优先级时所有事件同等优先
+func (f *FSM) comparePriority(a, b postedEvent) int {
+	pt, ok := f.transitionTable.(EventPriorityTable)
+	if !ok {
+		return 0
+	}
+	return cmp.Compare(pt.EventPriority(b.event), pt.EventPriority(a.event))
+}
+
+// compactLatest 合并已按事件排序的队列中相邻的同一事件，保留最后投递的一个
+func compactLatest(pending []postedEvent) []postedEvent {
+	out := pending[:0]
+	for i, posted := range pending {
+		if i+1 < len(pending) && pending[i+1].event == posted.event {
+			continue
+		}
+		out = append(out, posted)
+	}
+	return out
+}
@@ -0,0 +1,248 @@
+package fsm_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试FIFO事件队列
+func TestPostFIFO(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	f.Post(EventStart)
+	f.Post(EventPause)
+	f.Post(EventPause)
+	if f.CurrentState() != StateIdle || f.PendingEvents() != 3 {
+		t.Errorf("Expected events to wait for processing, state %d, pending %d", f.CurrentState(), f.PendingEvents())
+	}
+	if accepted := f.ProcessQueue(); accepted != 2 {
+		t.Errorf("Expected 2 accepted events, got %d", accepted)
+	}
+	if f.CurrentState() != StatePaused || f.PendingEvents() != 0 {
+		t.Errorf("Unexpected state %d, pending %d", f.CurrentState(), f.PendingEvents())
+	}
+}
+
+// 测试只保留每种事件最新一次的事件队列
+func TestPostLatestPerEvent(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.SetQueueMode(fsm.QueueLatestPerEvent)
+
+	var gotArgs []any
+	table.RegisterCallback(fsm.AfterEvent, StateRunning, EventPause, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		gotArgs = args
+	})
+
+	// 投递顺序与处理顺序无关，按事件值升序处理
+	f.Post(EventPause, "first")
+	f.Post(EventStart)
+	f.Post(EventPause, "latest")
+	f.Post(EventStart)
+	if f.PendingEvents() != 2 {
+		t.Errorf("Expected 2 coalesced events, got %d", f.PendingEvents())
+	}
+	if accepted := f.ProcessQueue(); accepted != 2 {
+		t.Errorf("Expected 2 accepted events, got %d", accepted)
+	}
+	if f.CurrentState() != StatePaused {
+		t.Errorf("Expected state %d, got %d", StatePaused, f.CurrentState())
+	}
+	if len(gotArgs) != 1 || gotArgs[0] != "latest" {
+		t.Errorf("Expected latest args to win, got %v", gotArgs)
+	}
+}
+
+// 测试按事件优先级处理的事件队列
+func TestPostPriority(t *testing.T) {
+	table := createTestTransitionTable()
+	table.SetEventPriority(EventStop, 10)
+	table.SetEventPriority(EventStart, 5)
+	if table.EventPriority(EventStop) != 10 || table.EventPriority(EventPause) != 0 {
+		t.Error("Unexpected event priorities")
+	}
+
+	var order []fsm.Event
+	table.RegisterContextCallback(fsm.BeforeEvent, StateIdle, EventStart, func(tc *fsm.TransitionContext) {
+		order = append(order, tc.Event)
+	})
+	table.RegisterContextCallback(fsm.BeforeEvent, StateRunning, EventStop, func(tc *fsm.TransitionContext) {
+		order = append(order, tc.Event)
+	})
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.SetQueueMode(fsm.QueuePriority)
+	f.Post(EventPause)
+	f.Post(EventStop)
+	f.Post(EventStart)
+	// 处理顺序：Stop(10, 在Idle下被拒绝)、Start(5)、Pause(0)
+	if accepted := f.ProcessQueue(); accepted != 2 {
+		t.Errorf("Expected 2 accepted events, got %d", accepted)
+	}
+	if f.CurrentState() != StatePaused || len(order) != 1 || order[0] != EventStart {
+		t.Errorf("Unexpected state %d, order %v", f.CurrentState(), order)
+	}
+
+	// 同一批中高优先级的Stop抢先于Pause
+	f = fsm.NewFSM(1, StateRunning, table)
+	f.SetQueueMode(fsm.QueuePriority)
+	f.Post(EventPause)
+	f.Post(EventStop)
+	f.ProcessQueue()
+	if f.CurrentState() != StateStopped {
+		t.Errorf("Expected Stop to preempt Pause, got state %d", f.CurrentState())
+	}
+
+	// 编译后的表保留事件优先级
+	compiled := table.Compile()
+	if compiled.EventPriority(EventStop) != 10 || compiled.EventPriority(EventPause) != 0 || compiled.EventPriority(-1) != 0 {
+		t.Error("Expected compiled table to keep event priorities")
+	}
+	f = fsm.NewFSM(2, StateRunning, compiled)
+	f.SetQueueMode(fsm.QueuePriority)
+	f.Post(EventPause)
+	f.Post(EventStop)
+	f.ProcessQueue()
+	if f.CurrentState() != StateStopped {
+		t.Errorf("Expected Stop to preempt Pause on the compiled table, got state %d", f.CurrentState())
+	}
+}
+
+// 测试回调中重入触发同一状态机时事件被排队处理
+func TestReentrantTriggerQueued(t *testing.T) {
+	table := createTestTransitionTable()
+	var order []string
+	var inner fsm.TriggerResult
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		order = append(order, "enter running")
+		if from == StateIdle {
+			inner = f.TriggerDetailed(EventPause)
+		}
+	})
+	table.RegisterCallback(fsm.AfterEvent, StateIdle, EventStart, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		order = append(order, "after start")
+	})
+	table.RegisterCallback(fsm.BeforeEvent, StateRunning, EventPause, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		order = append(order, "before pause")
+	})
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	if !f.Trigger(EventStart) {
+		t.Fatal("Failed to trigger EventStart")
+	}
+	if inner != fsm.Queued {
+		t.Errorf("Expected reentrant trigger to be queued, got %v", inner)
+	}
+	// 重入的事件在外层转移的全部回调之后、Trigger返回之前处理
+	if f.CurrentState() != StatePaused {
+		t.Errorf("Expected queued EventPause to be processed, got state %d", f.CurrentState())
+	}
+	want := []string{"enter running", "after start", "before pause"}
+	if !slices.Equal(order, want) {
+		t.Errorf("Unexpected callback order %v, want %v", order, want)
+	}
+}
+
+// 测试不经由Trigger发起的转移中重入触发同一状态机同样被放入重入队列
+func TestReentrantTriggerInSequence(t *testing.T) {
+	table := createTestTransitionTable()
+	var inner fsm.TriggerResult
+	table.RegisterCallback(fsm.EnterState, StatePaused, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		inner = f.TriggerDetailed(EventStop)
+	})
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.TriggerSequence([]fsm.Event{EventStart, EventPause})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("TriggerSequence deadlocked on reentrant trigger")
+	}
+	if inner != fsm.Queued || f.CurrentState() != StateStopped {
+		t.Errorf("Expected queued EventStop to be processed, got %v in state %d", inner, f.CurrentState())
+	}
+}
+
+// 测试回调中重入触发不会死锁：同一状态机排队处理，嵌套触发其他状态机时panic
+func TestReentrantTriggerDetected(t *testing.T) {
+	table := createTestTransitionTable()
+	other := fsm.NewFSM(1, StateIdle, createTestTransitionTable())
+	var recovered any
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		if from != StateIdle {
+			return
+		}
+		// 非重入的互斥锁上，这里曾经会永久阻塞
+		f.Trigger(EventStop)
+		func() {
+			defer func() { recovered = recover() }()
+			other.Trigger(EventStart)
+		}()
+	})
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Trigger(EventStart)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Reentrant Trigger deadlocked")
+	}
+
+	if f.CurrentState() != StateStopped {
+		t.Errorf("Expected reentrant EventStop to be processed, got state %d", f.CurrentState())
+	}
+	if err, _ := recovered.(error); !errors.Is(err, fsm.ErrReentrantTrigger) {
+		t.Errorf("Expected ErrReentrantTrigger panic, got %v", recovered)
+	}
+	if other.CurrentState() != StateIdle {
+		t.Errorf("Expected nested FSM to stay in StateIdle, got %d", other.CurrentState())
+	}
+}
+
+// 测试在其他状态机的回调中触发一个正由另一个goroutine执行转移的状态机时，不会被当作重入放入它的队列
+func TestReentrantTriggerOtherGoroutine(t *testing.T) {
+	busyTable := createTestTransitionTable()
+	started, release := make(chan struct{}), make(chan struct{})
+	busyTable.RegisterCallback(fsm.EnterState, StateRunning, 0, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {
+		close(started)
+		<-release
+	})
+	busy := fsm.NewFSM(0, StateIdle, busyTable)
+
+	table := createTestTransitionTable()
+	var result fsm.TriggerResult
+	var recovered any
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {
+		defer func() { recovered = recover() }()
+		result = busy.TriggerDetailed(EventPause)
+	})
+	other := fsm.NewFSM(1, StateIdle, table)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		busy.Trigger(EventStart)
+	}()
+	<-started
+	other.Trigger(EventStart)
+	close(release)
+	<-done
+
+	if err, _ := recovered.(error); !errors.Is(err, fsm.ErrReentrantTrigger) {
+		t.Errorf("Expected ErrReentrantTrigger panic, got result %v, recovered %v", result, recovered)
+	}
+	if busy.CurrentState() != StateRunning {
+		t.Errorf("Expected busy FSM to stay in StateRunning, got %d", busy.CurrentState())
+	}
+}
@@ -0,0 +1,67 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrCallbackPanic PanicAbort策略下BeforeEvent或LeaveState阶段的回调panic时，TriggerE返回的错误包装了它
+var ErrCallbackPanic = errors.New("callback panicked")
+
+// PanicPolicy 转移回调panic时的处理策略
+type PanicPolicy int32
+
+const (
+	// PanicPropagate 不做处理，panic沿Trigger向调用方传播，默认策略
+	// Event锁会被释放，状态停留在panic发生时的位置：BeforeEvent/LeaveState阶段panic时状态不变；
+	// EnterState/AfterEvent阶段panic时转移已经提交并记入转移历史，但剩余的回调、监听器和观察者都不会执行
+	PanicPropagate PanicPolicy = iota
+	// PanicAbort 恢复panic并中止转移
+	// BeforeEvent/LeaveState阶段panic时与ErrHandler返回错误相同：状态不变，结果为Aborted，
+	// TriggerE返回包装了ErrCallbackPanic的错误。EnterState/AfterEvent阶段panic时转移已经提交，无法撤销：
+	// 状态为目标状态，结果为转移成功，与调用StopPropagation相同，其余的回调都不再执行，
+	// 监听器、观察者和指标照常通知
+	PanicAbort
+	// PanicComplete 恢复panic并完成转移
+	// panic所在阶段该状态上剩余的回调不再执行（与同一位置的回调链中某个回调panic时相同），
+	// 之后的阶段照常执行，转移正常提交，结果与没有panic时相同
+	PanicComplete
+)
+
+// RecoverFunc PanicAbort或PanicComplete策略下恢复回调panic后调用的函数，recovered为recover()的返回值
+type RecoverFunc func(recovered any, from, to State, event Event)
+
+// SetPanicPolicy 设置转移回调panic时的处理策略，默认为PanicPropagate
+// 策略覆盖Handler、ContextHandler、ErrHandler和全局回调，包括ResetWithCallbacks执行的回调；
+// 守卫、拒绝回调、监听器和观察者panic时仍然直接传播
+func (f *FSM) SetPanicPolicy(policy PanicPolicy) {
+	f.panicPolicy.Store(int32(policy))
+}
+
+// SetRecover 设置恢复回调panic后调用的函数，通常用于记录日志，传入nil表示取消
+// 只在PanicAbort和PanicComplete策略下被调用，调用时持有Event锁
+func (f *FSM) SetRecover(fn RecoverFunc) {
+	if fn == nil {
+		f.recoverFunc.Store(nil)
+		return
+	}
+	f.recoverFunc.Store(&fn)
+}
+
+// recoverCallback 执行指定阶段的回调并按策略处理panic
+func (f *FSM) recoverCallback(cbType CallbackType, state State, tc *TransitionContext) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if fn := f.recoverFunc.Load(); fn != nil {
+			(*fn)(r, tc.From, tc.To, tc.Event)
+		}
+		if PanicPolicy(f.panicPolicy.Load()) == PanicAbort {
+			tc.stopped = true
+			err = fmt.Errorf("%w: callback type %d in state %v: %v", ErrCallbackPanic, cbType, state, r)
+		}
+	}()
+	return f.runCallback(cbType, state, tc)
+}
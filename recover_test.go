@@ -0,0 +1,91 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// panicTable 在指定阶段的回调中panic的状态转移表，同时记录执行过的阶段
+func panicTable(panicAt fsm.CallbackType, phases *[]fsm.CallbackType) *fsm.ArrayTransitionTable {
+	table := createTestTransitionTable()
+	record := func(cbType fsm.CallbackType) fsm.Handler {
+		return func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+			*phases = append(*phases, cbType)
+			if cbType == panicAt {
+				panic("boom")
+			}
+		}
+	}
+	table.RegisterCallback(fsm.BeforeEvent, StateIdle, EventStart, record(fsm.BeforeEvent))
+	table.RegisterCallback(fsm.LeaveState, StateIdle, 0, record(fsm.LeaveState))
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, record(fsm.EnterState))
+	table.RegisterCallback(fsm.AfterEvent, StateIdle, EventStart, record(fsm.AfterEvent))
+	return table
+}
+
+// 测试默认策略下panic沿Trigger传播，Event锁被释放
+func TestPanicPropagate(t *testing.T) {
+	var phases []fsm.CallbackType
+	f := fsm.NewFSM(0, StateIdle, panicTable(fsm.LeaveState, &phases))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected panic to propagate")
+			}
+		}()
+		f.Trigger(EventStart)
+	}()
+	if f.CurrentState() != StateIdle {
+		t.Errorf("Expected state unchanged, got %d", f.CurrentState())
+	}
+	// Event锁已经释放，状态机仍然可用
+	if f.Trigger(EventPause) {
+		t.Error("Expected EventPause to be rejected in StateIdle")
+	}
+}
+
+// 测试PanicAbort策略在提交前后的行为
+func TestPanicAbort(t *testing.T) {
+	var recovered []any
+	for _, tc := range []struct {
+		panicAt fsm.CallbackType
+		ok      bool
+		state   fsm.State
+		phases  int
+	}{
+		{fsm.BeforeEvent, false, StateIdle, 1},
+		{fsm.LeaveState, false, StateIdle, 2},
+		{fsm.EnterState, true, StateRunning, 3},
+		{fsm.AfterEvent, true, StateRunning, 4},
+	} {
+		var phases []fsm.CallbackType
+		f := fsm.NewFSM(0, StateIdle, panicTable(tc.panicAt, &phases))
+		f.SetPanicPolicy(fsm.PanicAbort)
+		f.SetRecover(func(r any, from, to fsm.State, event fsm.Event) {
+			recovered = append(recovered, r)
+		})
+		ok, err := f.TriggerE(EventStart)
+		if ok != tc.ok || f.CurrentState() != tc.state || len(phases) != tc.phases {
+			t.Errorf("panic at %d: ok %v, state %d, phases %v", tc.panicAt, ok, f.CurrentState(), phases)
+		}
+		if !tc.ok && !errors.Is(err, fsm.ErrCallbackPanic) {
+			t.Errorf("panic at %d: expected ErrCallbackPanic, got %v", tc.panicAt, err)
+		}
+	}
+	if len(recovered) != 4 || recovered[0] != "boom" {
+		t.Errorf("Unexpected recovered values: %v", recovered)
+	}
+}
+
+// 测试PanicComplete策略下转移照常完成
+func TestPanicComplete(t *testing.T) {
+	var phases []fsm.CallbackType
+	f := fsm.NewFSM(0, StateIdle, panicTable(fsm.LeaveState, &phases))
+	f.SetPanicPolicy(fsm.PanicComplete)
+	ok, err := f.TriggerE(EventStart)
+	if !ok || err != nil || f.CurrentState() != StateRunning || len(phases) != 4 {
+		t.Errorf("Expected transition to complete, got %v, %v, state %d, phases %v", ok, err, f.CurrentState(), phases)
+	}
+}
@@ -0,0 +1,82 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrReentrantTrigger 在转移回调中嵌套触发了另一个状态机，作为panic的值抛出
+// 在回调中重入触发同一个状态机是允许的，事件会被放入重入队列，见Trigger
+var ErrReentrantTrigger = errors.New("fsm: reentrant Trigger detected")
+
+// inOwnTransition 判断当前goroutine是否位于f自身的转移之中，是则触发f的事件应当放入重入队列
+// 先检查标记，没有转移在执行时不需要检查调用栈
+func (f *FSM) inOwnTransition() bool {
+	return f.firing.Load() && inTransitionOf(f.token)
+}
+
+// deferReentrant 回调中重入Trigger时，将事件放入内部的重入队列
+// 返回false表示状态机此时并未在执行转移，调用方应按普通的触发处理
+func (f *FSM) deferReentrant(posted postedEvent) bool {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	if !f.firing.Load() {
+		return false
+	}
+	f.reentrant = append(f.reentrant, posted)
+	f.reentrantCount.Add(1)
+	return true
+}
+
+// endFiring 结束一次转移，调用方必须持有Event锁
+// 在队列锁内清除标记，保证deferReentrant要么看到标记并入队（随后由drainReentrant处理），
+// 要么看不到标记而按普通的触发处理，不会有事件滞留在重入队列中
+func (f *FSM) endFiring() {
+	f.queueLock.Lock()
+	defer f.queueLock.Unlock()
+	f.firing.Store(false)
+}
+
+// drainReentrant 在释放Event锁之后，按入队顺序处理转移期间重入触发的事件
+// 处理过程中再次重入的事件追加到队尾，直到队列为空；之后按PostDeferred的规则处理延迟队列中已经有效的事件
+// 两个队列都为空时只有两次原子读取，不获取队列锁。转移的回调中释放了状态机时，先完成释放，不再处理两个队列
+func (f *FSM) drainReentrant(ctx context.Context) {
+	f.drain(ctx, false)
+}
+
+// drain 见drainReentrant，nested的含义与dispatch相同
+func (f *FSM) drain(ctx context.Context, nested bool) {
+	for {
+		if f.finishRelease() {
+			return
+		}
+		if f.reentrantCount.Load() == 0 {
+			posted, ok := f.popDeferred()
+			if !ok {
+				return
+			}
+			f.dispatch(ctx, posted.event, posted.args, nested)
+			continue
+		}
+		f.queueLock.Lock()
+		if len(f.reentrant) == 0 {
+			// 已被并发的drain取走
+			f.queueLock.Unlock()
+			continue
+		}
+		next := f.reentrant[0]
+		f.reentrant = f.reentrant[1:]
+		f.reentrantCount.Add(-1)
+		f.queueLock.Unlock()
+
+		if next.apply != nil {
+			next.apply()
+			continue
+		}
+		if next.post {
+			f.postDeferred(ctx, next.event, next.args, nested)
+			continue
+		}
+		f.dispatch(ctx, next.event, next.args, nested)
+	}
+}
@@ -0,0 +1,104 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// ResetEvent ResetWithCallbacks执行回调时使用的事件，便于回调区分重置与普通转移
+// 它不对应任何转移规则，不应在状态转移表中使用
+const ResetEvent Event = math.MaxInt32
+
+// Reset 将状态机直接置为to，不执行任何回调
+// 在Event锁内原子地切换状态并重新开始计算停留时长，to设置了超时规则时重新计时；
+// 重置不是转移：转移次数不变，不记录转移历史，也不通知监听器和观察者，已投递的事件和实例级配置保持不变；
+// CASRetries的计数被清零，便于按重试循环的每一轮统计竞争程度。
+// to为StateInInit或AnyState时返回ErrInvalidState；状态转移表实现了StateCountTable且to不在[0, NumStates())内时返回ErrOutOfRange，
+// 其他的表（例如允许负数状态的MapTransitionTable）不限制to的取值。
+// 重置会使当前状态尚未触发的超时失效。
+// 适合重试循环等需要回到已知状态的场景，需要执行LeaveState/EnterState回调时使用ResetWithCallbacks。
+// 不能在本状态机的回调中调用
+func (f *FSM) Reset(to State) error {
+	if err := f.checkResetTarget(to); err != nil {
+		return err
+	}
+	f.lockTransition()
+	defer f.unlockTransition()
+	f.resetLocked(to)
+	f.casRetries.Store(0)
+	return nil
+}
+
+// ResetToInitial 将状态机直接置为InitialState，不执行任何回调，见Reset
+func (f *FSM) ResetToInitial() error {
+	return f.Reset(f.initialState)
+}
+
+// ResetWithCallbacks 与Reset相同，但像一次转移一样先执行当前状态的LeaveState回调，切换状态后再执行to的EnterState回调
+// 有父状态时沿层次结构执行，BeforeEvent/AfterEvent回调不会执行。回调收到的事件为ResetEvent，
+// 全局回调同样会被执行。LeaveState阶段的ErrHandler返回错误时中止重置，状态保持不变并返回该错误。
+// 即使已经处于to，也会离开并重新进入
+func (f *FSM) ResetWithCallbacks(to State, args ...any) error {
+	if err := f.checkResetTarget(to); err != nil {
+		return err
+	}
+	defer f.drainReentrant(context.Background())
+	f.lockTransition()
+	defer f.unlockTransition()
+	f.firing.Store(true)
+	defer f.endFiring()
+	return f.resetBranch(to, args)
+}
+
+// resetBranch 执行重置的回调并切换状态，调用方持有Event锁
+// 与firePlan一样被inTransition识别并以f的标识标记调用栈，回调中重入触发的事件会在重置完成后处理
+func (f *FSM) resetBranch(to State, args []any) (err error) {
+	runTransition(f.token, func() {
+		err = f.runReset(to, args)
+	})
+	return err
+}
+
+// runReset 见resetBranch
+func (f *FSM) runReset(to State, args []any) error {
+	current := f.CurrentState()
+	tc := TransitionContext{
+		FSM:   f,
+		From:  current,
+		To:    to,
+		Event: ResetEvent,
+		Args:  args,
+		Seq:   f.seq.Load(),
+		Ctx:   context.Background(),
+	}
+	if err := f.leaveBranch(current, to, &tc); err != nil {
+		return err
+	}
+	f.resetLocked(to)
+	f.casRetries.Store(0)
+	f.enterBranch(current, to, &tc)
+	return nil
+}
+
+// checkResetTarget 检查重置的目标状态是否是合法的状态
+func (f *FSM) checkResetTarget(to State) error {
+	if to == StateInInit || to == AnyState {
+		return fmt.Errorf("%w: cannot reset to %v", ErrInvalidState, to)
+	}
+	if t, ok := f.transitionTable.(StateCountTable); ok && (to < 0 || int(to) >= t.NumStates()) {
+		return fmt.Errorf("%w: cannot reset to %v outside %d states", ErrOutOfRange, to, t.NumStates())
+	}
+	return nil
+}
+
+// resetLocked 切换到to并重新开始计时，调用方持有Event锁
+func (f *FSM) resetLocked(to State) {
+	atomic.StoreInt32(f.statePtr, int32(to))
+	f.enteredAt.Store(monotonicNow())
+	if f.timeouts != nil {
+		f.armTimeout(to)
+	}
+	f.notifyWaiters(to)
+}
@@ -0,0 +1,167 @@
+package fsm_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试Reset直接切换状态且不执行回调
+func TestReset(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateIdle, table)
+	var calls int
+	table.RegisterCallback(fsm.EnterState, StateIdle, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		calls++
+	})
+	f.Trigger(EventStart)
+	f.Trigger(EventStop)
+
+	if err := f.Reset(StateIdle); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if f.CurrentState() != StateIdle || calls != 0 {
+		t.Errorf("Expected silent reset to Idle, state %v, calls %d", f.CurrentState(), calls)
+	}
+	if f.Seq() != 2 {
+		t.Errorf("Expected reset not to count as a transition, seq %d", f.Seq())
+	}
+	if !f.Trigger(EventStart) {
+		t.Error("Expected transitions from the reset state to work")
+	}
+
+	for _, to := range []fsm.State{fsm.StateInInit, fsm.AnyState} {
+		if err := f.Reset(to); !errors.Is(err, fsm.ErrInvalidState) {
+			t.Errorf("Expected ErrInvalidState for %v, got %v", to, err)
+		}
+	}
+	// 测试表只有4个状态
+	for _, to := range []fsm.State{-1, 1000} {
+		if err := f.Reset(to); !errors.Is(err, fsm.ErrOutOfRange) {
+			t.Errorf("Expected ErrOutOfRange for %v, got %v", to, err)
+		}
+	}
+	if f.CurrentState() != StateRunning {
+		t.Errorf("Expected invalid resets to keep the state, got %v", f.CurrentState())
+	}
+}
+
+// 测试Reset清零CAS重试计数
+func TestResetClearsCASRetries(t *testing.T) {
+	table := createTestTransitionTable()
+	var state int32
+	// 在回调中绕过状态机改写状态字，制造一次CAS失败
+	table.RegisterCallback(fsm.BeforeEvent, StateIdle, EventStart, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {
+		atomic.StoreInt32(&state, int32(StatePaused))
+	})
+	f := fsm.NewFSMAt(0, StateIdle, table, &state)
+	f.Trigger(EventStart)
+	if f.CASRetries() == 0 {
+		t.Fatal("Expected a CAS retry")
+	}
+	if err := f.Reset(StateIdle); err != nil || f.CASRetries() != 0 {
+		t.Errorf("Expected Reset to clear CAS retries, got %d, %v", f.CASRetries(), err)
+	}
+}
+
+// 测试重置到map状态转移表中的负数状态
+func TestResetNegativeState(t *testing.T) {
+	const failed fsm.State = -1
+	table := fsm.NewMapTransitionTable([]fsm.Transition{
+		{From: StateIdle, Event: EventStop, To: failed},
+		{From: failed, Event: EventStart, To: StateIdle},
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+	if err := f.Reset(failed); err != nil || f.CurrentState() != failed {
+		t.Fatalf("Expected reset to the negative state, got %v, %v", f.CurrentState(), err)
+	}
+	if err := f.ResetWithCallbacks(StateIdle); err != nil || !f.Trigger(EventStop) {
+		t.Errorf("Expected ResetWithCallbacks and transitions to work, got %v", err)
+	}
+}
+
+// 测试ResetWithCallbacks执行离开和进入回调
+func TestResetWithCallbacks(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateRunning, table)
+	var order []string
+	table.RegisterCallback(fsm.LeaveState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		if event != fsm.ResetEvent || from != StateRunning || to != StateIdle || len(args) != 1 {
+			t.Errorf("Unexpected leave callback %v -> %v on %v, args %v", from, to, event, args)
+		}
+		order = append(order, "leave")
+	})
+	table.RegisterCallback(fsm.EnterState, StateIdle, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		if f.CurrentState() != StateIdle {
+			t.Errorf("Expected state to be switched before enter, got %v", f.CurrentState())
+		}
+		order = append(order, "enter")
+		// 回调中重入触发的事件在重置完成后处理
+		if f.TriggerDetailed(EventStart) != fsm.Queued {
+			t.Error("Expected reentrant trigger to be queued")
+		}
+	})
+	table.RegisterCallback(fsm.BeforeEvent, StateRunning, EventStop, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		order = append(order, "before")
+	})
+
+	if err := f.ResetWithCallbacks(StateIdle, "retry"); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(order) != 2 || order[0] != "leave" || order[1] != "enter" {
+		t.Errorf("Unexpected callbacks %v", order)
+	}
+	if f.CurrentState() != StateRunning {
+		t.Errorf("Expected queued Start to run after reset, got %v", f.CurrentState())
+	}
+}
+
+// 测试离开回调中止重置
+func TestResetWithCallbacksAbort(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateRunning, table)
+	busy := errors.New("busy")
+	table.RegisterErrCallback(fsm.LeaveState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) error {
+		return busy
+	})
+	if err := f.ResetWithCallbacks(StateIdle); !errors.Is(err, busy) {
+		t.Errorf("Expected abort error, got %v", err)
+	}
+	if f.CurrentState() != StateRunning {
+		t.Errorf("Expected aborted reset to keep the state, got %v", f.CurrentState())
+	}
+}
+
+// 测试重置后重新计算超时
+func TestResetRearmsTimeout(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateRunning, table)
+	f.SetTimeout(StatePaused, 10*time.Millisecond, EventResume)
+	if err := f.Reset(StatePaused); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for f.CurrentState() != StateRunning && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if f.CurrentState() != StateRunning {
+		t.Errorf("Expected timeout after reset, got %v", f.CurrentState())
+	}
+}
+
+// 测试重置使尚未触发的超时失效
+func TestResetCancelsTimeout(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateRunning, table)
+	f.SetTimeout(StateRunning, 10*time.Millisecond, EventStop)
+	if err := f.Reset(StateIdle); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if f.CurrentState() != StateIdle || f.Seq() != 0 {
+		t.Errorf("Expected pending timeout to be cancelled, got %v, seq %d", f.CurrentState(), f.Seq())
+	}
+}
@@ -0,0 +1,77 @@
+package fsm
+
+import "context"
+
+// TriggerSequence 在一次加锁中原子地依次触发一组事件：要么全部被接受，要么状态保持不变
+// 先在锁内从当前状态出发沿转移表逐个校验事件（包括守卫，每个事件只执行一次），任意一个事件在对应的中间状态下
+// 没有转移规则或被守卫否决时直接返回，此时不会执行任何回调；全部通过后再依次提交。
+// ok为true时applied等于len(events)；ok为false时applied为第一个失败事件之前的事件数量，
+// 状态机处于调用前的状态。被Pause冻结时返回(0, false)，空序列返回(0, true)。
+//
+// 提交阶段只有ErrHandler可能中止转移。此时状态被直接恢复为调用前的状态：
+// 恢复过程不执行任何回调，已经执行过的回调、观察者通知和转移历史也不会撤销，
+// 需要补偿的业务应当在ErrHandler中自行处理。
+// 回调在持有Event锁时执行，不能在本状态机的回调中调用TriggerSequence
+func (f *FSM) TriggerSequence(events []Event, args ...any) (applied int, ok bool) {
+	defer f.drainReentrant(context.Background())
+	f.lockTransition()
+	defer f.unlockTransition()
+	f.firing.Store(true)
+	defer f.endFiring()
+
+	if f.paused.Load() {
+		return 0, false
+	}
+
+	// 校验阶段：沿中间状态走一遍，不产生任何副作用（守卫除外），守卫只在这里执行一次
+	type step struct {
+		index int
+		p     transitionPlan
+	}
+	start := f.CurrentState()
+	state := start
+	steps := make([]step, 0, len(events))
+	for i, event := range events {
+		p, result, ok := f.plan(state, event, args)
+		if !ok {
+			if result != Consumed {
+				return i, false
+			}
+			// 接受并忽略的事件不改变状态，提交阶段无需处理
+			continue
+		}
+		steps = append(steps, step{index: i, p: p})
+		state = p.to
+	}
+
+	// 提交阶段：依次执行校验得到的转移，被ErrHandler中止时恢复起始状态
+	for _, s := range steps {
+		if result, _ := f.firePlan(context.Background(), s.p, events[s.index], args); !result.Accepted() {
+			f.resetLocked(start)
+			return s.index, false
+		}
+	}
+	return len(events), true
+}
+
+// Simulate 从当前状态出发，按转移表演练events，返回途经的状态，不改变状态机，也不执行任何回调
+// path[0]为出发时的状态，path[i+1]为处理events[i]之后的状态，接受并忽略的事件保持状态不变。
+// 遇到没有转移规则的事件时停止并返回false，此时len(path)-1就是该事件在events中的下标。
+// 守卫可能有副作用，演练时不执行，只按转移规则判断；Pause冻结的状态也不考虑。
+// 不加锁，出发状态只是调用时的快照，适合测试和预先校验用户提交的事件脚本
+func (f *FSM) Simulate(events []Event) (path []State, ok bool) {
+	state := f.CurrentState()
+	path = make([]State, 1, len(events)+1)
+	path[0] = state
+	for _, event := range events {
+		next, ok := f.transitionTable.GetNextState(state, event)
+		if !ok {
+			return path, false
+		}
+		if state, ok = checkNext(next); !ok {
+			return path, false
+		}
+		path = append(path, state)
+	}
+	return path, true
+}
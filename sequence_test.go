@@ -0,0 +1,85 @@
+package fsm_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试原子地触发事件序列
+func TestTriggerSequence(t *testing.T) {
+	table := createTestTransitionTable()
+	entered := 0
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {
+		entered++
+	})
+	guarded := 0
+	table.RegisterGuard(StatePaused, EventResume, func(*fsm.FSM, fsm.State, fsm.Event, ...any) bool {
+		guarded++
+		return true
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+
+	if applied, ok := f.TriggerSequence([]fsm.Event{EventStart, EventPause, EventResume}); !ok || applied != 3 {
+		t.Fatalf("Expected full sequence to apply, got %d, %v", applied, ok)
+	}
+	if guarded != 1 {
+		t.Errorf("Expected guard to run once, got %d", guarded)
+	}
+	if f.CurrentState() != StateRunning || entered != 2 {
+		t.Errorf("Expected StateRunning entered twice, got %v, %d", f.CurrentState(), entered)
+	}
+
+	// 第三个事件在中间状态StatePaused下无效，整个序列不生效，也不执行回调
+	if applied, ok := f.TriggerSequence([]fsm.Event{EventPause, EventResume, EventResume}); ok || applied != 2 {
+		t.Errorf("Expected sequence to fail at index 2, got %d, %v", applied, ok)
+	}
+	if f.CurrentState() != StateRunning || entered != 2 || f.Seq() != 3 {
+		t.Errorf("Expected state to be unchanged, got %v, entered %d, seq %d", f.CurrentState(), entered, f.Seq())
+	}
+}
+
+// 测试提交阶段被ErrHandler中止时恢复起始状态
+func TestTriggerSequenceRollback(t *testing.T) {
+	table := createTestTransitionTable()
+	table.RegisterErrCallback(fsm.LeaveState, StatePaused, 0, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) error {
+		return errors.New("refused")
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+
+	if applied, ok := f.TriggerSequence([]fsm.Event{EventStart, EventPause, EventStop}); ok || applied != 2 {
+		t.Errorf("Expected sequence to be aborted at index 2, got %d, %v", applied, ok)
+	}
+	if f.CurrentState() != StateIdle {
+		t.Errorf("Expected rollback to StateIdle, got %v", f.CurrentState())
+	}
+}
+
+// 测试演练事件序列不改变状态机也不执行回调
+func TestSimulate(t *testing.T) {
+	table := createTestTransitionTable()
+	called := false
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {
+		called = true
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+
+	path, ok := f.Simulate([]fsm.Event{EventStart, EventPause, EventResume, EventStop})
+	if want := []fsm.State{StateIdle, StateRunning, StatePaused, StateRunning, StateStopped}; !ok || !slices.Equal(path, want) {
+		t.Errorf("Expected %v, true; got %v, %v", want, path, ok)
+	}
+	if f.CurrentState() != StateIdle || f.Seq() != 0 || called {
+		t.Error("Expected Simulate to have no side effects")
+	}
+
+	// 在第一个无效事件处停止
+	path, ok = f.Simulate([]fsm.Event{EventStart, EventResume, EventStop})
+	if ok || !slices.Equal(path, []fsm.State{StateIdle, StateRunning}) {
+		t.Errorf("Expected to stop before EventResume, got %v, %v", path, ok)
+	}
+	if path, ok = f.Simulate(nil); !ok || !slices.Equal(path, []fsm.State{StateIdle}) {
+		t.Errorf("Expected only the start state for an empty script, got %v, %v", path, ok)
+	}
+}
@@ -1,59 +1,190 @@
 package fsm
 
 import (
+	"reflect"
 	"runtime"
-	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-// 记录函数调用信息
-type CallInfo struct {
-	Function string
-	File     string
-	Line     int
-}
-
-// 获取当前调用栈信息
-func getCallStack(skip int) []CallInfo {
-	pcs := make([]uintptr, 32)
-	n := runtime.Callers(skip, pcs)
-	if n == 0 {
-		return nil
-	}
+// maxStackDepth 调用栈检查时每次获取的栈帧数量
+const maxStackDepth = 64
 
-	pcs = pcs[:n]
-	frames := runtime.CallersFrames(pcs)
+// pcPool 复用调用栈检查使用的程序计数器缓冲区，避免每次检查都分配
+var pcPool = sync.Pool{
+	New: func() any {
+		pcs := make([]uintptr, maxStackDepth)
+		return &pcs
+	},
+}
 
-	var stack []CallInfo
+// walkStack 从第skip层开始（0为runtime.Callers自身）依次把栈帧的完整函数名交给fn，fn返回false时停止
+// 栈很深时分段获取，不受maxStackDepth的限制。只逐帧读取函数名，不构造完整的调用栈信息；
+// 程序计数器缓冲区来自pcPool，每段只有runtime.CallersFrames的一次分配
+func walkStack(skip int, fn func(function string) bool) {
+	buf := pcPool.Get().(*[]uintptr)
+	defer pcPool.Put(buf)
+	pcs := *buf
 	for {
-		frame, more := frames.Next()
-		stack = append(stack, CallInfo{
-			Function: frame.Function,
-			File:     frame.File,
-			Line:     frame.Line,
-		})
-		if !more {
-			break
+		n := runtime.Callers(skip, pcs)
+		frames := runtime.CallersFrames(pcs[:n])
+		for more := n > 0; more; {
+			var frame runtime.Frame
+			frame, more = frames.Next()
+			if !fn(frame.Function) {
+				return
+			}
+		}
+		if n < len(pcs) {
+			return
 		}
+		skip += n
 	}
+}
 
-	return stack
+// CallDepth 获取function在当前调用栈中出现的次数，调用者本身也计算在内
+// 函数名的格式与DetectCycle相同。可以在会重入触发事件的回调中用来限制递归深度，
+// 例如在Handler中发现自身的调用深度超过预算时直接返回；整个调用栈都会被检查，不受栈深度限制
+func CallDepth(function string) int {
+	depth := 0
+	walkStack(3, func(name string) bool { // 跳过runtime.Callers、walkStack和CallDepth自身
+		if name == function {
+			depth++
+		}
+		return true
+	})
+	return depth
 }
 
-// 检查是否递归调用了指定函数
+// 检查调用者是否被递归调用，即调用者的函数在当前调用栈中出现了两次或以上
+// 函数按完整的函数名（含包路径和接收者类型）精确比较，doWork不会因为栈中有doWorkAsync而被误判；
+// 间接递归（A调用B，B再调用A）同样能被A中的检查发现。
+// 闭包与外层函数是不同的函数：在闭包中调用时检查的是闭包本身
 func IsRecursiveCall() bool {
-	stack := getCallStack(3) // 跳过isRecursiveCall自身
 	// IsRecursiveCall的调用者，假设叫FuncA
 	// 在同一个调用栈中出现两次或以上表示存在递归调用FuncA
-	first := stack[0]
-	count := 0
-	for _, call := range stack {
-		if strings.Contains(call.Function, first.Function) {
-			count++
-			if count >= 2 {
-				return true
+	var first string
+	recursive := false
+	walkStack(3, func(name string) bool { // 跳过runtime.Callers、walkStack和IsRecursiveCall自身
+		if first == "" {
+			first = name
+			return true
+		}
+		recursive = name == first
+		return !recursive
+	})
+	return recursive
+}
+
+// DetectCycle 检查functions中是否有任意一个函数在当前调用栈中出现了两次或以上
+// 用于发现在多个回调之间来回调用形成的间接递归（A调用B，B再调用A……），检查可以放在环上的任意一个函数中。
+// 函数名必须是完整的函数名，例如"example.com/app.handleA"、"example.com/app.(*Conn).onEnter"，
+// 可以通过runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()获取
+func DetectCycle(functions ...string) bool {
+	// 函数不多时使用栈上的数组记录是否出现过
+	var small [8]bool
+	seen := small[:0]
+	if len(functions) <= len(small) {
+		seen = small[:len(functions)]
+	} else {
+		seen = make([]bool, len(functions))
+	}
+	found := false
+	walkStack(3, func(name string) bool { // 跳过runtime.Callers、walkStack和DetectCycle自身
+		for i, function := range functions {
+			if name != function {
+				continue
 			}
+			if seen[i] {
+				found = true
+				return false
+			}
+			seen[i] = true
 		}
+		return true
+	})
+	return found
+}
+
+// fireFunc、resetFunc 执行转移回调的函数(*FSM).firePlan和执行重置回调的函数(*FSM).resetBranch的完整函数名
+// mark0Func、mark1Func runMarked用来编码标识的两个函数的完整函数名
+var fireFunc, resetFunc, mark0Func, mark1Func string
+
+func init() {
+	// 在init中计算，避免firePlan间接引用inTransition造成包级变量的初始化循环
+	fireFunc = funcName((*FSM).firePlan)
+	resetFunc = funcName((*FSM).resetBranch)
+	mark0Func = funcName(mark0)
+	mark1Func = funcName(mark1)
+}
+
+// funcName 获取函数的完整函数名，与调用栈中的名称一致
+func funcName(fn any) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
+
+// transitionsRunning 所有状态机中正在执行的firePlan和resetBranch的数量，见runTransition
+var transitionsRunning atomic.Int64
+
+// runTransition 以token标记调用栈并执行fn，执行期间计入transitionsRunning
+func runTransition(token uint64, fn func()) {
+	transitionsRunning.Add(1)
+	defer transitionsRunning.Add(-1)
+	runMarked(token, fn)
+}
+
+// inTransition 判断当前goroutine是否正在执行某个状态机的转移（即调用栈中有firePlan或resetBranch），
+// 也就是说调用者位于转移回调、监听器或观察者之中。
+// 无论转移由Trigger、超时、TriggerSequence、TriggerAll还是ResetWithCallbacks发起都能识别；
+// 没有任何状态机在执行转移时只有一次原子读取，不检查调用栈
+func inTransition() bool {
+	if transitionsRunning.Load() == 0 {
+		return false
 	}
+	found := false
+	walkStack(3, func(name string) bool { // 跳过runtime.Callers、walkStack和inTransition自身
+		found = name == fireFunc || name == resetFunc
+		return !found
+	})
+	return found
+}
 
-	return false
+// runMarked 把token的二进制位从低到高依次编码为mark0、mark1的栈帧，再在最内层执行fn
+// Go没有可以直接访问的goroutine标识，firePlan和resetBranch借此在调用栈中留下状态机的标识，
+// inTransitionOf从回调所在的调用栈中解码出来，即可判断当前goroutine执行的是哪个状态机的转移。
+// 标识由fsmTokens顺序分配，栈帧数量为其二进制位数
+func runMarked(token uint64, fn func()) {
+	switch {
+	case token == 0:
+		fn()
+	case token&1 == 0:
+		mark0(token>>1, fn)
+	default:
+		mark1(token>>1, fn)
+	}
+}
+
+// mark0、mark1 在调用栈中表示一个为0或1的二进制位
+func mark0(token uint64, fn func()) { runMarked(token, fn) }
+func mark1(token uint64, fn func()) { runMarked(token, fn) }
+
+// inTransitionOf 判断当前goroutine是否正在执行标识为token的状态机的转移，也就是说调用者位于该状态机的
+// 转移回调、监听器或观察者之中；位于其他状态机的转移中（包括其他goroutine正在执行该状态机的转移）时返回false
+func inTransitionOf(token uint64) bool {
+	found := false
+	var decoded uint64
+	walkStack(3, func(name string) bool { // 跳过runtime.Callers、walkStack和inTransitionOf自身
+		switch name {
+		case mark0Func:
+			decoded <<= 1
+		case mark1Func:
+			decoded = decoded<<1 | 1
+		case fireFunc, resetFunc:
+			// 由内向外先遇到高位，到达firePlan或resetBranch时一个标识解码完毕
+			found = decoded == token
+			decoded = 0
+		}
+		return !found
+	})
+	return found
 }
@@ -21,3 +21,114 @@ func TestStackCheck(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func doWork() bool {
+	return fsm.IsRecursiveCall()
+}
+
+func doWorkAsync() bool {
+	return doWork()
+}
+
+// 函数名是另一个函数名的子串时不应被误判为递归
+func TestStackCheckSubstring(t *testing.T) {
+	if doWorkAsync() {
+		t.Error("Expected doWork called from doWorkAsync not to be recursive")
+	}
+}
+
+func pingA(depth int) bool {
+	if fsm.IsRecursiveCall() {
+		return true
+	}
+	if depth > 3 {
+		return false
+	}
+	return pongB(depth + 1)
+}
+
+func pongB(depth int) bool {
+	return pingA(depth + 1)
+}
+
+// 间接递归A -> B -> A同样能被A中的检查发现
+func TestStackCheckMutual(t *testing.T) {
+	if !pingA(0) {
+		t.Error("Expected A -> B -> A to be detected as recursive")
+	}
+}
+
+const (
+	bounceAName = "github.com/cuitpanfei/lowgcfsm_test.bounceA"
+	bounceBName = "github.com/cuitpanfei/lowgcfsm_test.bounceB"
+)
+
+// bounceA和bounceB相互调用，在bounceB中检查环
+func bounceA(depth int) (int, bool) {
+	return bounceB(depth + 1)
+}
+
+func bounceB(depth int) (int, bool) {
+	if fsm.DetectCycle(bounceAName, bounceBName) {
+		return depth, true
+	}
+	if depth > 10 {
+		return depth, false
+	}
+	return bounceA(depth + 1)
+}
+
+// 测试检测A <-> B的间接递归
+func TestDetectCycle(t *testing.T) {
+	depth, ok := bounceA(0)
+	if !ok || depth != 3 {
+		t.Errorf("Expected cycle A -> B -> A -> B to be detected at depth 3, got %d, %v", depth, ok)
+	}
+	if fsm.DetectCycle(bounceAName, bounceBName) {
+		t.Error("Expected no cycle outside the functions")
+	}
+}
+
+const nestName = "github.com/cuitpanfei/lowgcfsm_test.nest"
+
+// nest 递归调用自身直到调用深度达到budget
+func nest(budget int) int {
+	if depth := fsm.CallDepth(nestName); depth >= budget {
+		return depth
+	}
+	return nest(budget)
+}
+
+// 测试获取函数在调用栈中的深度
+func TestCallDepth(t *testing.T) {
+	if depth := nest(3); depth != 3 {
+		t.Errorf("Expected depth 3, got %d", depth)
+	}
+	// 超过一次获取的栈帧数量时仍能完整计数
+	if depth := nest(100); depth != 100 {
+		t.Errorf("Expected depth 100, got %d", depth)
+	}
+	if depth := fsm.CallDepth(nestName); depth != 0 {
+		t.Errorf("Expected depth 0 outside nest, got %d", depth)
+	}
+}
+
+// 基准测试：递归检查的开销，栈缓冲区来自sync.Pool，每次检查只有遍历栈帧时的一次分配
+func BenchmarkIsRecursiveCall(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if doWorkAsync() {
+			b.Fatal("unexpected recursion")
+		}
+	}
+}
+
+// 基准测试：间接递归检查的开销
+func BenchmarkDetectCycle(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if fsm.DetectCycle(bounceAName, bounceBName) {
+			b.Fatal("unexpected cycle")
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package fsm
+
+import (
+	"sync"
+	"time"
+)
+
+// TransitionEvent 通过Subscribe收到的一次成功转移
+type TransitionEvent struct {
+	From  State
+	To    State
+	Event Event
+	Time  time.Time // 转移完成、发送通知的时间
+}
+
+// subscription Subscribe注册的观察者，把转移转发到通道中
+type subscription struct {
+	fsm    *FSM
+	mu     sync.Mutex // 串行化发送与关闭，保证不会向已关闭的通道发送
+	ch     chan TransitionEvent
+	closed bool
+}
+
+// Subscribe 订阅状态机的转移，返回接收转移通知的通道和取消订阅的函数
+// 订阅以观察者的方式实现，通知的时机和顺序与AddObserver相同，Reset等不属于转移的状态变化不会通知。
+// 发送是非阻塞的：通道已满（buffer为0时没有正在等待的接收方）时丢弃这次通知，不会阻塞转移，
+// 丢弃的总数通过SubscriptionDrops获取。取消订阅后通道被关闭，取消函数可以重复调用，也可以在回调中调用；
+// 池中的实例被重置时（见FsmPool.SetResetMode）所有订阅被取消，通道同样被关闭。
+// buffer为负数时panic
+func (f *FSM) Subscribe(buffer int) (<-chan TransitionEvent, func()) {
+	if buffer < 0 {
+		panic("Subscribe: negative buffer")
+	}
+	s := &subscription{fsm: f, ch: make(chan TransitionEvent, buffer)}
+	f.AddObserver(s)
+	return s.ch, s.close
+}
+
+// SubscriptionDrops 获取所有订阅因通道已满而丢弃的转移通知总数
+func (f *FSM) SubscriptionDrops() uint64 {
+	return f.subscribeDrops.Load()
+}
+
+// OnTransition 以非阻塞的方式将转移发送到通道
+func (s *subscription) OnTransition(fsm *FSM, from, to State, event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- TransitionEvent{From: from, To: to, Event: event, Time: time.Now()}:
+	default:
+		s.fsm.subscribeDrops.Add(1)
+	}
+}
+
+// close 取消订阅并关闭通道
+func (s *subscription) close() {
+	s.fsm.RemoveObserver(s)
+	s.closeChannel()
+}
+
+// closeChannel 关闭通道，可以重复调用
+func (s *subscription) closeChannel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// dropObservers 移除所有观察者，并关闭其中Subscribe订阅的通道，使仍在接收的一方能够退出
+func (f *FSM) dropObservers() {
+	old := f.observers.Swap(nil)
+	if old == nil {
+		return
+	}
+	for _, o := range *old {
+		if s, ok := o.(*subscription); ok {
+			s.closeChannel()
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package fsm_test
+
+import (
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试通过通道订阅转移、丢弃计数和取消订阅
+func TestSubscribe(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	events, cancel := f.Subscribe(2)
+
+	f.Trigger(EventStart)
+	f.Trigger(EventPause)
+	// 通道已满，这次通知被丢弃而不会阻塞转移
+	if !f.Trigger(EventResume) || f.CurrentState() != StateRunning {
+		t.Fatal("Expected transition to proceed with a full subscription channel")
+	}
+	if f.SubscriptionDrops() != 1 {
+		t.Errorf("Expected 1 drop, got %d", f.SubscriptionDrops())
+	}
+
+	want := []fsm.TransitionEvent{
+		{From: StateIdle, To: StateRunning, Event: EventStart},
+		{From: StateRunning, To: StatePaused, Event: EventPause},
+	}
+	for i, w := range want {
+		got := <-events
+		if got.From != w.From || got.To != w.To || got.Event != w.Event || got.Time.IsZero() {
+			t.Errorf("event %d: got %+v, want %+v", i, got, w)
+		}
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+	f.Trigger(EventStop)
+	if f.SubscriptionDrops() != 1 {
+		t.Errorf("Expected no drops after cancel, got %d", f.SubscriptionDrops())
+	}
+}
+
+// 测试在回调中取消订阅
+func TestSubscribeCancelInCallback(t *testing.T) {
+	table := createTestTransitionTable()
+	f := fsm.NewFSM(0, StateIdle, table)
+	events, cancel := f.Subscribe(1)
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		cancel()
+	})
+	f.Trigger(EventStart)
+	if _, ok := <-events; ok {
+		t.Error("Expected no event after cancelling during the transition")
+	}
+}
+
+// 测试池中的实例被释放重置时关闭订阅的通道
+func TestSubscribeClosedOnPoolRelease(t *testing.T) {
+	pool := fsm.NewFsmPool(1, StateIdle, createTestTransitionTable())
+	f := pool.Allocate()
+	events, cancel := f.Subscribe(1)
+	f.Trigger(EventStart)
+
+	done := make(chan int)
+	go func() {
+		n := 0
+		for range events {
+			n++
+		}
+		done <- n
+	}()
+	if err := pool.Release(f); err != nil {
+		t.Fatal(err)
+	}
+	if n := <-done; n != 1 {
+		t.Errorf("Expected 1 notification before close, got %d", n)
+	}
+	// 上一任使用者的取消函数不影响复用的实例
+	cancel()
+	if pool.Allocate() != f {
+		t.Fatal("Expected the released slot to be reused")
+	}
+}
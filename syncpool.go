@@ -0,0 +1,48 @@
+package fsm
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SyncFsmPool 基于sync.Pool的状态机对象池，没有容量上限，也不需要预先指定大小
+// 实例在Get时按需创建，空闲的实例可以被GC回收，适合流量突发、实例数量难以预估的场景。
+// 与FsmPool相比，SyncFsmPool无法统计已分配的实例数量，也不能识别重复归还，
+// 需要固定容量、按状态计数或防御重复释放时请使用FsmPool
+type SyncFsmPool struct {
+	pool            sync.Pool
+	transitionTable TransitionTable
+	initialState    State
+	nextID          atomic.Uint32
+}
+
+// NewSyncFsmPool 创建基于sync.Pool的状态机对象池
+// 新创建的实例按创建顺序从0开始分配ID，被GC回收后重新创建的实例会得到新的ID
+func NewSyncFsmPool(initialState State, transitionTable TransitionTable) *SyncFsmPool {
+	p := &SyncFsmPool{
+		transitionTable: transitionTable,
+		initialState:    initialState,
+	}
+	p.pool.New = func() any {
+		fsm := NewFSM(p.nextID.Add(1)-1, p.initialState, p.transitionTable)
+		fsm.syncPool = p
+		return fsm
+	}
+	return p
+}
+
+// Get 从池中获取一个处于初始状态的状态机实例，池中没有空闲实例时新建一个
+func (p *SyncFsmPool) Get() *FSM {
+	return p.pool.Get().(*FSM)
+}
+
+// Put 将状态机实例重置为初始状态后归还到池中，归还后调用方不能再使用该实例
+// 归还的实例必须来自本池的Get：nil、单独创建的实例、属于FsmPool或其他SyncFsmPool的实例不做任何处理，
+// 避免之后的Get交出绑定在其他状态转移表上的实例
+func (p *SyncFsmPool) Put(fsm *FSM) {
+	if fsm == nil || fsm.syncPool != p {
+		return
+	}
+	fsm.reset(p.initialState, 0)
+	p.pool.Put(fsm)
+}
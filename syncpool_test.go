@@ -0,0 +1,84 @@
+package fsm_test
+
+import (
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试基于sync.Pool的状态机池
+func TestSyncFsmPool(t *testing.T) {
+	pool := fsm.NewSyncFsmPool(StateIdle, createTestTransitionTable())
+
+	f := pool.Get()
+	if f == nil || f.CurrentState() != StateIdle {
+		t.Fatal("Expected FSM in initial state")
+	}
+	f.SetData("conn")
+	f.Trigger(EventStart)
+	pool.Put(f)
+
+	// 无论取回的是复用的实例还是新建的实例，都处于初始状态且不带业务数据
+	g := pool.Get()
+	if g.CurrentState() != StateIdle || g.Data() != nil {
+		t.Errorf("Expected reset FSM, got state %v data %v", g.CurrentState(), g.Data())
+	}
+	if !g.Trigger(EventStart) {
+		t.Error("Expected FSM from pool to be usable")
+	}
+
+	// nil和属于FsmPool的实例不会被放入池中
+	pool.Put(nil)
+	arrayPool := fsm.NewFsmPool(1, StateIdle, createTestTransitionTable())
+	pooled := arrayPool.Allocate()
+	pool.Put(pooled)
+	if arrayPool.AllocatedCount() != 1 || pooled.Pool() != arrayPool {
+		t.Error("Expected FsmPool instance to be left untouched")
+	}
+
+	// 其他池或单独创建的实例同样不会被放入池中，之后的Get不会交出绑定在其他表上的实例
+	other := fsm.NewSyncFsmPool(StateRunning, fsm.NewMapTransitionTable(testTransitions))
+	foreign := other.Get()
+	foreign.Trigger(EventPause)
+	pool.Put(foreign)
+	pool.Put(fsm.NewFSM(0, StateRunning, createTestTransitionTable()))
+	if foreign.CurrentState() != StatePaused {
+		t.Error("Expected foreign FSM not to be reset by another pool")
+	}
+	for range 8 {
+		if got := pool.Get(); got == foreign || got.CurrentState() != StateIdle {
+			t.Fatal("Expected Get to return only this pool's instances")
+		}
+	}
+}
+
+// 基准测试：sync.Pool状态机池分配性能，与BenchmarkFsmPoolAllocation对比
+func BenchmarkSyncFsmPoolAllocation(b *testing.B) {
+	pool := fsm.NewSyncFsmPool(StateIdle, createTestTransitionTable())
+	for b.Loop() {
+		pool.Put(pool.Get())
+	}
+}
+
+// 基准测试：并发场景下两种状态机池的分配性能
+func BenchmarkConcurrentPoolAllocation(b *testing.B) {
+	table := createTestTransitionTable()
+	b.Run("FsmPool", func(b *testing.B) {
+		pool := fsm.NewFsmPool(6500, StateIdle, table)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if f := pool.Allocate(); f != nil {
+					pool.Release(f)
+				}
+			}
+		})
+	})
+	b.Run("SyncFsmPool", func(b *testing.B) {
+		pool := fsm.NewSyncFsmPool(StateIdle, table)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				pool.Put(pool.Get())
+			}
+		})
+	})
+}
@@ -0,0 +1,305 @@
+package fsm_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试用的转移规则
+var testTransitions = []fsm.Transition{
+	{From: StateIdle, Event: EventStart, To: StateRunning},
+	{From: StateRunning, Event: EventPause, To: StatePaused},
+	{From: StateRunning, Event: EventStop, To: StateStopped},
+	{From: StatePaused, Event: EventResume, To: StateRunning},
+	{From: StatePaused, Event: EventStop, To: StateStopped},
+}
+
+// tableImpls 所有TransitionTable实现的构造函数，同一组行为测试会在每个实现上运行
+var tableImpls = map[string]func([]fsm.Transition) fsm.TransitionTable{
+	"Array": func(transitions []fsm.Transition) fsm.TransitionTable {
+		return fsm.NewArrayTransitionTable(transitions)
+	},
+	"Compiled": func(transitions []fsm.Transition) fsm.TransitionTable {
+		return fsm.NewArrayTransitionTable(transitions).Compile()
+	},
+	"Map": func(transitions []fsm.Transition) fsm.TransitionTable {
+		return fsm.NewMapTransitionTable(transitions)
+	},
+	"CSR": func(transitions []fsm.Transition) fsm.TransitionTable {
+		return fsm.NewCSRTransitionTable(transitions)
+	},
+}
+
+// forEachTable 在每个TransitionTable实现上运行测试
+func forEachTable(t *testing.T, fn func(t *testing.T, table fsm.TransitionTable)) {
+	for name, build := range tableImpls {
+		t.Run(name, func(t *testing.T) {
+			fn(t, build(testTransitions))
+		})
+	}
+}
+
+// 测试各实现对无效查询的返回值一致
+func TestTableMissingLookups(t *testing.T) {
+	forEachTable(t, func(t *testing.T, table fsm.TransitionTable) {
+		missing := []struct {
+			from  fsm.State
+			event fsm.Event
+		}{
+			{StateIdle, EventPause},
+			{StateStopped, EventStart},
+			{-1, EventStart},
+			{StateIdle, -1},
+			{StateIdle, EventStop + 1},
+			{StateStopped + 1, EventStart},
+			{fsm.StateInInit, EventStart},
+		}
+		for _, m := range missing {
+			if next, ok := table.GetNextState(m.from, m.event); ok || next != fsm.StateInInit {
+				t.Errorf("GetNextState(%d, %d) = %d, %v; want StateInInit, false", m.from, m.event, next, ok)
+			}
+		}
+		for _, trans := range testTransitions {
+			if next, ok := table.GetNextState(trans.From, trans.Event); !ok || next != trans.To {
+				t.Errorf("GetNextState(%d, %d) = %d, %v; want %d, true", trans.From, trans.Event, next, ok, trans.To)
+			}
+		}
+	})
+}
+
+// 测试各实现驱动状态机的行为一致
+func TestTableFSMBehavior(t *testing.T) {
+	forEachTable(t, func(t *testing.T, table fsm.TransitionTable) {
+		f := fsm.NewFSM(0, StateIdle, table)
+		steps := []struct {
+			event fsm.Event
+			ok    bool
+			state fsm.State
+		}{
+			{EventPause, false, StateIdle},
+			{EventStart, true, StateRunning},
+			{EventPause, true, StatePaused},
+			{EventResume, true, StateRunning},
+			{EventStop, true, StateStopped},
+			{EventStart, false, StateStopped},
+		}
+		for i, step := range steps {
+			if ok := f.Trigger(step.event); ok != step.ok {
+				t.Errorf("step %d: Trigger(%d) = %v, want %v", i, step.event, ok, step.ok)
+			}
+			if f.CurrentState() != step.state {
+				t.Errorf("step %d: state %d, want %d", i, f.CurrentState(), step.state)
+			}
+		}
+	})
+}
+
+// brokenTable 错误地把StateInInit作为有效目标返回的状态转移表
+type brokenTable struct{}
+
+func (brokenTable) GetNextState(from fsm.State, event fsm.Event) (fsm.State, bool) {
+	return fsm.StateInInit, true
+}
+
+func (brokenTable) GetCallback(cbType fsm.CallbackType, state fsm.State, event fsm.Event) fsm.Handler {
+	return nil
+}
+
+// 测试状态机永远不会进入StateInInit
+func TestFSMNeverEntersStateInInit(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, brokenTable{})
+	if f.Trigger(EventStart) {
+		t.Error("Expected transition to StateInInit to be rejected")
+	}
+	if f.CurrentState() != StateIdle {
+		t.Errorf("Expected state %d, got %d", StateIdle, f.CurrentState())
+	}
+}
+
+// 测试未定义的单元格使用默认目标状态
+func TestArrayTransitionTableWithDefault(t *testing.T) {
+	const stateError fsm.State = 5
+	table := fsm.NewArrayTransitionTableWithDefault(testTransitions, stateError)
+
+	if next, ok := table.GetNextState(StateIdle, EventStart); !ok || next != StateRunning {
+		t.Errorf("Expected defined transition to be kept, got %d, %v", next, ok)
+	}
+	if next, ok := table.GetNextState(StateIdle, EventStop); !ok || next != stateError {
+		t.Errorf("Expected default state %d, got %d, %v", stateError, next, ok)
+	}
+	// 默认目标状态本身也是完整的状态，同样使用默认转移
+	if next, ok := table.GetNextState(stateError, EventStart); !ok || next != stateError {
+		t.Errorf("Expected default state from default state, got %d, %v", next, ok)
+	}
+	if next, ok := table.GetNextState(StateIdle, fsm.Event(100)); ok {
+		t.Errorf("Expected out-of-range event to be rejected, got %d", next)
+	}
+
+	f := fsm.NewFSM(0, StatePaused, table)
+	if !f.Trigger(EventStart) || f.CurrentState() != stateError {
+		t.Errorf("Expected FSM to move to default state, got %d", f.CurrentState())
+	}
+}
+
+// 测试枚举当前状态下的可用事件
+func TestAvailableEvents(t *testing.T) {
+	forEachTable(t, func(t *testing.T, table fsm.TransitionTable) {
+		f := fsm.NewFSM(0, StateRunning, table)
+		if got := f.AvailableEvents(); !slices.Equal(got, []fsm.Event{EventPause, EventStop}) {
+			t.Errorf("Unexpected events from Running: %v", got)
+		}
+		f.Trigger(EventStop)
+		if got := f.AvailableEvents(); len(got) != 0 {
+			t.Errorf("Expected no events from Stopped, got %v", got)
+		}
+	})
+
+	table := createTestTransitionTable()
+	if got := table.EventsFrom(StateIdle); !slices.Equal(got, []fsm.Event{EventStart}) {
+		t.Errorf("Unexpected events from Idle: %v", got)
+	}
+	if got := table.EventsFrom(-1); got != nil {
+		t.Errorf("Expected nil for invalid state, got %v", got)
+	}
+}
+
+// 测试map状态转移表支持稀疏和负数的状态
+func TestMapTransitionTableSparse(t *testing.T) {
+	const (
+		stateNegative fsm.State = -5
+		stateHuge     fsm.State = 1_000_000_000
+	)
+	table := fsm.NewMapTransitionTable([]fsm.Transition{
+		{From: stateNegative, Event: EventStart, To: stateHuge},
+		{From: stateHuge, Event: -1, To: stateNegative},
+		{From: stateHuge, Event: EventPause, Consume: true},
+	})
+	var entered []fsm.State
+	table.RegisterCallback(fsm.EnterState, stateNegative, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		entered = append(entered, to)
+	})
+
+	f := fsm.NewFSM(0, stateNegative, table)
+	if !f.Trigger(EventStart) || f.CurrentState() != stateHuge {
+		t.Errorf("Expected state %d, got %d", stateHuge, f.CurrentState())
+	}
+	if f.TriggerDetailed(EventPause) != fsm.Consumed {
+		t.Error("Expected EventPause to be consumed")
+	}
+	if !f.Trigger(-1) || f.CurrentState() != stateNegative || len(entered) != 1 {
+		t.Errorf("Expected to return to %d via negative event, got %d", stateNegative, f.CurrentState())
+	}
+	if f.Trigger(EventStop) {
+		t.Error("Expected undefined event to be rejected")
+	}
+}
+
+// 测试通配状态的转移规则
+func TestAnyStateTransition(t *testing.T) {
+	const eventReset fsm.Event = 4
+	transitions := append([]fsm.Transition{
+		{From: fsm.AnyState, Event: eventReset, To: StateIdle},
+		// 具体规则优先于通配规则，与先后顺序无关
+		{From: StateStopped, Event: eventReset, To: StateStopped},
+		{From: fsm.AnyState, Event: EventPause, Consume: true},
+	}, testTransitions...)
+
+	for name, build := range tableImpls {
+		t.Run(name, func(t *testing.T) {
+			table := build(transitions)
+			for _, state := range []fsm.State{StateIdle, StateRunning, StatePaused} {
+				if next, ok := table.GetNextState(state, eventReset); !ok || next != StateIdle {
+					t.Errorf("GetNextState(%d, reset) = %d, %v; want StateIdle", state, next, ok)
+				}
+			}
+			if next, ok := table.GetNextState(StateStopped, eventReset); !ok || next != StateStopped {
+				t.Errorf("Expected specific rule to win, got %d, %v", next, ok)
+			}
+
+			f := fsm.NewFSM(0, StateIdle, table)
+			if f.TriggerDetailed(EventPause) != fsm.Consumed {
+				t.Error("Expected wildcard consume in StateIdle")
+			}
+			f.Trigger(EventStart)
+			if f.TriggerDetailed(EventPause) != fsm.Transitioned || f.CurrentState() != StatePaused {
+				t.Errorf("Expected specific EventPause rule in StateRunning, got %d", f.CurrentState())
+			}
+			if !f.Trigger(eventReset) || f.CurrentState() != StateIdle {
+				t.Errorf("Expected reset to StateIdle, got %d", f.CurrentState())
+			}
+		})
+	}
+
+	if _, err := fsm.NewArrayTransitionTableChecked([]fsm.Transition{{From: StateIdle, Event: EventStart, To: fsm.AnyState}}); !errors.Is(err, fsm.ErrInvalidState) {
+		t.Errorf("Expected ErrInvalidState for AnyState target, got %v", err)
+	}
+}
+
+// 测试内部转移只执行事件回调，外部自转移会离开并重新进入状态
+func TestInternalTransition(t *testing.T) {
+	const (
+		eventTick    fsm.Event = 4
+		eventRefresh fsm.Event = 5
+		eventPing    fsm.Event = 6
+	)
+	transitions := append([]fsm.Transition{
+		{From: StateRunning, Event: eventTick, Internal: true},
+		{From: StateRunning, Event: eventRefresh, To: StateRunning},
+		{From: fsm.AnyState, Event: eventPing, Internal: true},
+	}, testTransitions...)
+
+	for name, build := range tableImpls {
+		t.Run(name, func(t *testing.T) {
+			f := fsm.NewFSM(0, StateRunning, build(transitions))
+			if r := f.TriggerDetailed(eventTick); r != fsm.InternalTransitioned || f.CurrentState() != StateRunning {
+				t.Errorf("Expected internal transition, got %d in state %d", r, f.CurrentState())
+			}
+			if r := f.TriggerDetailed(eventRefresh); r != fsm.SelfTransitioned {
+				t.Errorf("Expected external self-transition, got %d", r)
+			}
+			f.Trigger(EventStop)
+			if r := f.TriggerDetailed(eventPing); r != fsm.InternalTransitioned || f.CurrentState() != StateStopped {
+				t.Errorf("Expected wildcard internal transition in StateStopped, got %d in state %d", r, f.CurrentState())
+			}
+			if f.Seq() != 4 {
+				t.Errorf("Expected internal transitions to be counted, got seq %d", f.Seq())
+			}
+		})
+	}
+
+	table := fsm.NewArrayTransitionTable(transitions)
+	var calls []string
+	record := func(name string) fsm.Handler {
+		return func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) {
+			calls = append(calls, name)
+		}
+	}
+	for _, event := range []fsm.Event{eventTick, eventRefresh} {
+		table.RegisterCallback(fsm.BeforeEvent, StateRunning, event, record("before"))
+		table.RegisterCallback(fsm.AfterEvent, StateRunning, event, record("after"))
+	}
+	table.RegisterCallback(fsm.LeaveState, StateRunning, 0, record("leave"))
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, record("enter"))
+
+	f := fsm.NewFSM(0, StateRunning, table)
+	f.Trigger(eventTick)
+	if want := []string{"before", "after"}; !slices.Equal(calls, want) {
+		t.Errorf("Internal: expected %v, got %v", want, calls)
+	}
+	calls = nil
+	f.Trigger(eventRefresh)
+	if want := []string{"before", "leave", "enter", "after"}; !slices.Equal(calls, want) {
+		t.Errorf("External: expected %v, got %v", want, calls)
+	}
+
+	// 内部转移规则在枚举和编译后得到保留
+	if !slices.Contains(table.Transitions(), fsm.Transition{From: StateRunning, Event: eventTick, To: StateRunning, Internal: true}) {
+		t.Error("Expected Transitions to report the internal rule")
+	}
+	if !table.Compile().IsInternal(StateRunning, eventTick) || table.IsInternal(StateRunning, eventRefresh) {
+		t.Error("Unexpected IsInternal results")
+	}
+}
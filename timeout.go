@@ -0,0 +1,86 @@
+package fsm
+
+import (
+	"context"
+	"time"
+)
+
+// timeoutRule 状态超时规则：在状态中停留超过d后自动触发event
+type timeoutRule struct {
+	d     time.Duration
+	event Event
+}
+
+// SetTimeout 设置状态超时：进入state之后，如果停留时间达到d仍未离开，则自动触发event
+// 每次进入state（包括自转移和Reset）都会重新计时，任何一次离开并重新进入状态的转移或重置都会使之前的计时失效；
+// 内部转移不离开状态，计时继续。
+// 计时器在独立的goroutine上触发，与Trigger一样在Event锁内确认状态机仍停留在计时开始时的那次转移上，
+// 因此超时与并发的Trigger之间不会重复转移；状态机被Pause冻结时超时事件被丢弃。
+// 超时事件与Trigger一样先调用AttemptHook，在当前状态下被拒绝时同样执行延迟、拒绝回调和严格模式的处理。
+// d<=0表示取消该状态的超时规则。设置时状态机正处于state中的，从设置时开始计时。
+// 设置需要Event锁，不能在本状态机的回调中直接执行：在回调中调用时与Trigger一样放入重入队列，
+// 在当前转移完成之后生效，是否开始计时以那时的状态为准
+func (f *FSM) SetTimeout(state State, d time.Duration, event Event) {
+	if f.inOwnTransition() && f.deferReentrant(postedEvent{apply: func() { f.SetTimeout(state, d, event) }}) {
+		return
+	}
+	f.eventLock.Lock()
+	defer f.eventLock.Unlock()
+	if d <= 0 {
+		delete(f.timeouts, state)
+	} else {
+		if f.timeouts == nil {
+			f.timeouts = make(map[State]timeoutRule)
+		}
+		f.timeouts[state] = timeoutRule{d: d, event: event}
+	}
+	f.updateNeedsLock()
+	if f.CurrentState() == state {
+		f.armTimeout(state)
+	}
+}
+
+// armTimeout 进入state后重新计时，调用方必须持有Event锁
+// 无论state是否设置了超时规则都会递增timerGen，使已经到期、正在等待Event锁的计时失效
+func (f *FSM) armTimeout(state State) {
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	f.timerGen++
+	rule, ok := f.timeouts[state]
+	if !ok {
+		return
+	}
+	gen := f.timerGen
+	f.timer = time.AfterFunc(rule.d, func() {
+		f.triggerTimeout(gen, rule.event)
+	})
+}
+
+// triggerTimeout 计时到期后触发超时事件，期间重新计时过（状态被重新进入或重置）则不做处理
+// 与trigger一样先调用AttemptHook，事件被拒绝时在释放Event锁之后按reject处理；
+// 超时转移的回调中重入触发的事件在释放Event锁之后处理
+func (f *FSM) triggerTimeout(gen uint64, event Event) {
+	if hook := f.attemptHook.Load(); hook != nil {
+		(*hook)(f.CurrentState(), event)
+	}
+	if result, state := f.fireTimeout(gen, event); result == Rejected {
+		f.reject(state, event, nil)
+	}
+	f.drainReentrant(context.Background())
+}
+
+// fireTimeout 在Event锁内确认计时仍然有效后执行超时事件的转移，返回转移的结果和之后的状态
+// 计时已经失效或状态机被冻结时返回Canceled
+func (f *FSM) fireTimeout(gen uint64, event Event) (TriggerResult, State) {
+	f.lockTransition()
+	defer f.unlockTransition()
+	if f.timerGen != gen || f.paused.Load() {
+		return Canceled, f.CurrentState()
+	}
+	f.firing.Store(true)
+	defer f.endFiring()
+	result, _ := f.fire(context.Background(), event)
+	return result, f.CurrentState()
+}
@@ -0,0 +1,91 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试状态超时自动触发事件
+func TestSetTimeout(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	f.SetTimeout(StateRunning, 20*time.Millisecond, EventStop)
+
+	f.Trigger(EventStart)
+	deadline := time.Now().Add(time.Second)
+	for f.CurrentState() != StateStopped && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if f.CurrentState() != StateStopped {
+		t.Fatalf("Expected timeout to fire EventStop, got state %d", f.CurrentState())
+	}
+}
+
+// 测试离开状态后超时失效
+func TestSetTimeoutCanceledByTransition(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	f.SetTimeout(StateRunning, 20*time.Millisecond, EventStop)
+
+	f.Trigger(EventStart)
+	f.Trigger(EventPause)
+	time.Sleep(60 * time.Millisecond)
+	if f.CurrentState() != StatePaused {
+		t.Errorf("Expected stale timeout to be ignored, got state %d", f.CurrentState())
+	}
+
+	// 重新进入状态时重新计时；取消规则后不再触发
+	f.Trigger(EventResume)
+	f.SetTimeout(StateRunning, 0, EventStop)
+	time.Sleep(60 * time.Millisecond)
+	if f.CurrentState() != StateRunning {
+		t.Errorf("Expected removed timeout not to fire, got state %d", f.CurrentState())
+	}
+}
+
+// 测试在回调中设置超时，设置在转移完成后生效
+func TestSetTimeoutInCallback(t *testing.T) {
+	table := createTestTransitionTable()
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {
+		f.SetTimeout(StateRunning, 10*time.Millisecond, EventStop)
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.Trigger(EventStart)
+	if err := waitState(f, StateStopped); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 测试超时事件与Trigger一样调用AttemptHook并处理拒绝
+func TestTimeoutRejected(t *testing.T) {
+	table := createTestTransitionTable()
+	rejected := make(chan fsm.Event, 1)
+	table.RegisterRejectHandler(StateIdle, func(_ *fsm.FSM, _ fsm.State, event fsm.Event, _ ...any) {
+		rejected <- event
+	})
+	f := fsm.NewFSM(0, StateIdle, table)
+	attempts := make(chan fsm.Event, 1)
+	f.SetAttemptHook(func(_ fsm.State, event fsm.Event) {
+		attempts <- event
+	})
+	// EventStop在StateIdle下没有转移规则
+	f.SetTimeout(StateIdle, 10*time.Millisecond, EventStop)
+	for name, ch := range map[string]chan fsm.Event{"attempt hook": attempts, "reject handler": rejected} {
+		select {
+		case event := <-ch:
+			if event != EventStop {
+				t.Errorf("Unexpected %s event %v", name, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected the %s to see the timeout event", name)
+		}
+	}
+}
+
+// waitState 等待f进入state，最多等待一秒
+func waitState(f *fsm.FSM, state fsm.State) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return f.WaitForState(ctx, state)
+}
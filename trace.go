@@ -0,0 +1,19 @@
+package fsm
+
+import "context"
+
+// TraceFunc 转移追踪函数，在每次转移执行回调之前调用以开始一个span
+// 返回的上下文作为TransitionContext.Ctx传给本次转移的所有回调，便于在回调中创建子span；
+// 返回的end在回调执行完毕后调用（转移被ErrHandler中止时同样会调用），用于结束span。
+// 追踪函数在持有Event锁时调用，end不能为nil。被拒绝、被守卫否决和接受并忽略的事件不会开始span
+type TraceFunc func(ctx context.Context, from, to State, event Event) (context.Context, func())
+
+// SetTraceFunc 设置转移追踪函数，传入nil时关闭追踪（默认关闭，此时没有额外开销）
+// 可以将OpenTelemetry等追踪库适配为TraceFunc，本包不依赖任何追踪库
+func (f *FSM) SetTraceFunc(trace TraceFunc) {
+	if trace == nil {
+		f.trace.Store(nil)
+		return
+	}
+	f.trace.Store(&trace)
+}
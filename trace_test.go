@@ -0,0 +1,44 @@
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+type spanKey struct{}
+
+// 测试转移追踪
+func TestTraceFunc(t *testing.T) {
+	table := createTestTransitionTable()
+	var log []string
+	table.RegisterContextCallback(fsm.EnterState, StateRunning, 0, func(tc *fsm.TransitionContext) {
+		log = append(log, "enter:"+tc.Ctx.Value(spanKey{}).(string))
+	})
+	table.RegisterErrCallback(fsm.BeforeEvent, StateRunning, EventStop, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) error {
+		return errors.New("refused")
+	})
+
+	f := fsm.NewFSM(0, StateIdle, table)
+	f.SetTraceFunc(func(ctx context.Context, from, to fsm.State, event fsm.Event) (context.Context, func()) {
+		log = append(log, "start")
+		return context.WithValue(ctx, spanKey{}, "span"), func() { log = append(log, "end") }
+	})
+
+	f.TriggerCtx(context.Background(), EventStart)
+	f.Trigger(EventStart) // 被拒绝的事件不开始span
+	f.Trigger(EventStop)  // 被中止的转移同样结束span
+	if want := []string{"start", "enter:span", "end", "start", "end"}; !slices.Equal(log, want) {
+		t.Errorf("Expected %v, got %v", want, log)
+	}
+
+	f.SetTraceFunc(nil)
+	log = nil
+	f.Trigger(EventPause)
+	if len(log) != 0 {
+		t.Errorf("Expected no spans after removing trace func, got %v", log)
+	}
+}
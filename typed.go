@@ -0,0 +1,86 @@
+package fsm
+
+import "context"
+
+// TypedTransition 以具名状态和事件类型定义的转移规则，字段含义与Transition相同
+// S和E通常是以int32为底层类型的两种不同枚举，编译器会拒绝把事件写到状态的位置上
+type TypedTransition[S, E ~int32] struct {
+	From     S
+	Event    E
+	To       S
+	Consume  bool
+	Internal bool
+}
+
+// NewTypedTransitionTable 用类型安全的转移规则创建数组状态转移表
+// 生成的表与NewArrayTransitionTable完全相同，同样可以注册回调或编译为CompiledTable
+func NewTypedTransitionTable[S, E ~int32](transitions []TypedTransition[S, E]) *ArrayTransitionTable {
+	return NewArrayTransitionTable(untypedTransitions(transitions))
+}
+
+// untypedTransitions 将类型安全的转移规则转换为Transition
+func untypedTransitions[S, E ~int32](transitions []TypedTransition[S, E]) []Transition {
+	out := make([]Transition, len(transitions))
+	for i, t := range transitions {
+		out[i] = Transition{From: State(t.From), Event: Event(t.Event), To: State(t.To), Consume: t.Consume, Internal: t.Internal}
+	}
+	return out
+}
+
+// TypedFSM 类型安全的状态机，在FSM之上只做状态和事件类型的转换
+// 底层仍然是int32的状态字和数组状态转移表，不引入额外的内存分配；
+// 回调、队列、对象池等其余功能通过Untyped取得底层的FSM使用
+type TypedFSM[S, E ~int32] struct {
+	fsm *FSM
+}
+
+// NewTypedFSM 创建类型安全的状态机实例
+func NewTypedFSM[S, E ~int32](id uint32, initialState S, transitionTable TransitionTable) *TypedFSM[S, E] {
+	return &TypedFSM[S, E]{fsm: NewFSM(id, State(initialState), transitionTable)}
+}
+
+// Typed 为已有的状态机（例如从FsmPool分配的实例）创建类型安全的视图，两者共享同一个状态
+func Typed[S, E ~int32](fsm *FSM) *TypedFSM[S, E] {
+	return &TypedFSM[S, E]{fsm: fsm}
+}
+
+// Untyped 获取底层的状态机
+func (t *TypedFSM[S, E]) Untyped() *FSM {
+	return t.fsm
+}
+
+// ID 获取状态机ID
+func (t *TypedFSM[S, E]) ID() uint32 {
+	return t.fsm.ID()
+}
+
+// CurrentState 获取当前状态
+func (t *TypedFSM[S, E]) CurrentState() S {
+	return S(t.fsm.CurrentState())
+}
+
+// Trigger 触发事件，语义与FSM.Trigger相同
+func (t *TypedFSM[S, E]) Trigger(event E, args ...any) bool {
+	return t.fsm.Trigger(Event(event), args...)
+}
+
+// TriggerCtx 触发事件并支持取消，语义与FSM.TriggerCtx相同
+func (t *TypedFSM[S, E]) TriggerCtx(ctx context.Context, event E, args ...any) bool {
+	return t.fsm.TriggerCtx(ctx, Event(event), args...)
+}
+
+// TriggerE 触发事件并返回错误，语义与FSM.TriggerE相同
+func (t *TypedFSM[S, E]) TriggerE(event E, args ...any) (bool, error) {
+	return t.fsm.TriggerE(Event(event), args...)
+}
+
+// TriggerR 触发事件并返回本次转移的源状态和目标状态，语义与FSM.TriggerR相同
+func (t *TypedFSM[S, E]) TriggerR(event E, args ...any) (from S, to S, ok bool) {
+	rawFrom, rawTo, ok := t.fsm.TriggerR(Event(event), args...)
+	return S(rawFrom), S(rawTo), ok
+}
+
+// CanTrigger 判断当前状态下事件是否有定义的转移规则，语义与FSM.CanTrigger相同
+func (t *TypedFSM[S, E]) CanTrigger(event E) bool {
+	return t.fsm.CanTrigger(Event(event))
+}
@@ -0,0 +1,50 @@
+package fsm_test
+
+import (
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+type doorState int32
+
+const (
+	doorClosed doorState = iota
+	doorOpen
+	doorLocked
+)
+
+type doorEvent int32
+
+const (
+	doorOpenEvent doorEvent = iota
+	doorCloseEvent
+	doorLockEvent
+)
+
+// 测试类型安全的状态机
+func TestTypedFSM(t *testing.T) {
+	table := fsm.NewTypedTransitionTable([]fsm.TypedTransition[doorState, doorEvent]{
+		{From: doorClosed, Event: doorOpenEvent, To: doorOpen},
+		{From: doorOpen, Event: doorCloseEvent, To: doorClosed},
+		{From: doorClosed, Event: doorLockEvent, To: doorLocked},
+	})
+
+	door := fsm.NewTypedFSM[doorState, doorEvent](1, doorClosed, table)
+	if !door.Trigger(doorOpenEvent) || door.CurrentState() != doorOpen {
+		t.Fatalf("Expected door to open, got %v", door.CurrentState())
+	}
+	if door.CanTrigger(doorLockEvent) || door.Trigger(doorLockEvent) {
+		t.Error("Expected open door to reject lock")
+	}
+	if ok, err := door.TriggerE(doorCloseEvent); !ok || err != nil {
+		t.Errorf("Expected close to succeed, got %v, %v", ok, err)
+	}
+
+	// 类型安全的视图与底层状态机共享同一个状态
+	view := fsm.Typed[doorState, doorEvent](door.Untyped())
+	view.Trigger(doorLockEvent)
+	if door.CurrentState() != doorLocked || door.Untyped().CurrentState() != fsm.State(doorLocked) {
+		t.Errorf("Expected shared state doorLocked, got %v", door.CurrentState())
+	}
+}
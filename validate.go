@@ -0,0 +1,149 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrConflictingTransition 同一(From, Event)被定义了多次且定义内容不一致
+	ErrConflictingTransition = errors.New("conflicting transitions")
+	// ErrTableTooLarge 数组状态转移表的单元格数量超过MaxTableCells
+	ErrTableTooLarge = errors.New("transition table too large")
+	// ErrInvalidCallback 回调注册参数无效
+	ErrInvalidCallback = errors.New("invalid callback registration")
+	// ErrInvalidState 转移规则使用了保留的StateInInit
+	ErrInvalidState = errors.New("invalid state")
+	// ErrOutOfRange 状态或事件取值超出数组状态转移表能够表示的范围
+	ErrOutOfRange = errors.New("state or event out of range")
+	// ErrNoTransitions 没有任何转移规则，这样的表会拒绝所有事件，通常说明传错了参数
+	ErrNoTransitions = errors.New("no transitions")
+)
+
+// MaxTableCells 数组状态转移表允许的最大单元格数量(maxStates*maxEvents)，默认16M
+// 状态或事件取值过大时，稠密数组会占用大量内存甚至导致OOM。
+// 确实需要超大稠密表的用户可以在构造前调大该值
+var MaxTableCells int64 = 1 << 24
+
+// validateStates 检查转移规则没有使用保留的StateInInit，AnyState也只能出现在From中
+func validateStates(transitions []Transition) error {
+	var errs []error
+	for i, trans := range transitions {
+		if trans.From == StateInInit || trans.To == StateInInit {
+			errs = append(errs, fmt.Errorf("%w: #%d %+v uses reserved StateInInit (%d)",
+				ErrInvalidState, i, trans, StateInInit))
+		} else if trans.To == AnyState && !trans.keepsState() {
+			errs = append(errs, fmt.Errorf("%w: #%d %+v uses AnyState (%d) as target",
+				ErrInvalidState, i, trans, AnyState))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// transitionKey 状态转移表中一个单元格的键
+type transitionKey struct {
+	from  State
+	event Event
+}
+
+// ValidateNoConflicts 检查转移规则中同一(From, Event)的重复定义
+// 完全相同的重复定义是允许的；只要目标状态或任何附加字段不同，即视为冲突。
+// 每一处冲突都以一个包装了ErrConflictingTransition的错误报告，并指出与之冲突的首次定义，
+// 而不是像NewArrayTransitionTable那样静默地以后者覆盖前者。
+// NewArrayTransitionTableChecked已经包含这项检查，这里单独提供给MapTransitionTable等其他状态转移表，
+// 也便于在合并转移规则列表后尽早校验
+func ValidateNoConflicts(transitions []Transition) error {
+	var errs []error
+	first := make(map[transitionKey]int, len(transitions))
+	for i, trans := range transitions {
+		key := transitionKey{from: trans.From, event: trans.Event}
+		j, ok := first[key]
+		if !ok {
+			first[key] = i
+			continue
+		}
+		// Transition的所有字段都参与比较，新增字段时自动纳入冲突检查
+		if transitions[j] != trans {
+			errs = append(errs, fmt.Errorf("%w: #%d %+v and #%d %+v",
+				ErrConflictingTransition, j, transitions[j], i, trans))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateTableSize 检查转移规则能否放入数组状态转移表
+// 数组无法表示负数的状态或事件；单元格数量超过MaxTableCells或者int32范围时同样返回错误
+func validateTableSize(transitions []Transition) error {
+	for i, trans := range transitions {
+		if trans.From < 0 || trans.To < 0 || trans.Event < 0 {
+			return fmt.Errorf("%w: #%d %+v has negative values, consider MapTransitionTable",
+				ErrOutOfRange, i, trans)
+		}
+	}
+	maxStates, maxEvents := tableDims(transitions)
+	if cells := maxStates * maxEvents; cells > min(MaxTableCells, math.MaxInt32) {
+		return fmt.Errorf("%w: %d states x %d events = %d cells exceeds limit %d, consider MapTransitionTable for sparse states",
+			ErrTableTooLarge, maxStates, maxEvents, cells, MaxTableCells)
+	}
+	return nil
+}
+
+// RegisterCallbackChecked 以严格模式注册回调函数，参数有误时返回错误而不是静默忽略
+// 以下情况会返回错误：未知的回调类型；(state, event)超出状态转移表范围；
+// 为LeaveState/EnterState传入了非零的event（状态回调不区分事件，event会被忽略）
+func (t *ArrayTransitionTable) RegisterCallbackChecked(cbType CallbackType, state State, event Event, handler Handler) error {
+	if (cbType == LeaveState || cbType == EnterState) && event != 0 {
+		return fmt.Errorf("%w: state callback type %d ignores event %v, pass 0 instead",
+			ErrInvalidCallback, cbType, event)
+	}
+	_, size, ok := t.callbackIndex(cbType, state, event)
+	if size == 0 {
+		return fmt.Errorf("%w: unknown callback type %d", ErrInvalidCallback, cbType)
+	}
+	if !ok {
+		return fmt.Errorf("%w: state %v event %v out of table range", ErrInvalidCallback, state, event)
+	}
+	t.RegisterCallback(cbType, state, event, handler)
+	return nil
+}
+
+// CallbackSlot 回调注册位置
+type CallbackSlot struct {
+	Type  CallbackType
+	State State
+	Event Event
+}
+
+// String 返回注册位置的可读形式，例如"BeforeEvent(Idle, Stop)"
+// 状态和事件优先使用RegisterStateNames、RegisterEventNames注册的名称，见State.String
+func (s CallbackSlot) String() string {
+	return s.Type.String() + "(" + s.State.String() + ", " + s.Event.String() + ")"
+}
+
+// ValidateCallbacks 检查注册在不存在转移规则的(state, event)上的BeforeEvent/AfterEvent回调
+// 这类回调永远不会被触发，通常是删除转移规则时遗漏了对应的回调。
+// 返回所有无效的注册位置，按回调类型、状态、事件排序，打印时使用已注册的状态和事件名称；
+// Handler、ContextHandler和ErrHandler都会被检查
+func (t *ArrayTransitionTable) ValidateCallbacks() []CallbackSlot {
+	var orphans []CallbackSlot
+	for _, cbType := range []CallbackType{BeforeEvent, AfterEvent} {
+		handlers := t.beforeEvents
+		if cbType == AfterEvent {
+			handlers = t.afterEvents
+		}
+		ctxHandlers, errHandlers := t.ctxCallbacks[cbType], t.errCallbacks[cbType]
+		for i := range t.table {
+			registered := handlers[i] != nil || (i < len(ctxHandlers) && ctxHandlers[i] != nil) ||
+				(i < len(errHandlers) && errHandlers[i] != nil)
+			if registered && t.table[i] == StateInInit {
+				orphans = append(orphans, CallbackSlot{
+					Type:  cbType,
+					State: State(int32(i) / t.maxEvents),
+					Event: Event(int32(i) % t.maxEvents),
+				})
+			}
+		}
+	}
+	return orphans
+}
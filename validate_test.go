@@ -0,0 +1,215 @@
+package fsm_test
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试重复定义冲突检测
+func TestCheckedConflicts(t *testing.T) {
+	// 完全相同的重复定义是允许的
+	transitions := []fsm.Transition{
+		{From: StateIdle, Event: EventStart, To: StateRunning},
+		{From: StateIdle, Event: EventStart, To: StateRunning},
+	}
+	if _, err := fsm.NewArrayTransitionTableChecked(transitions); err != nil {
+		t.Errorf("Unexpected error for identical duplicates: %v", err)
+	}
+
+	// 目标状态不同的重复定义需要报错
+	transitions = append(transitions, fsm.Transition{From: StateIdle, Event: EventStart, To: StatePaused})
+	table, err := fsm.NewArrayTransitionTableChecked(transitions)
+	if !errors.Is(err, fsm.ErrConflictingTransition) {
+		t.Errorf("Expected ErrConflictingTransition, got %v", err)
+	}
+	if table != nil {
+		t.Error("Expected nil table on conflict")
+	}
+}
+
+// 测试单独校验转移规则列表中的冲突
+func TestValidateNoConflicts(t *testing.T) {
+	transitions := []fsm.Transition{
+		{From: StateIdle, Event: EventStart, To: StateRunning},
+		{From: StateRunning, Event: EventStop, To: StateStopped},
+		{From: StateIdle, Event: EventStart, To: StatePaused},
+		{From: StateRunning, Event: EventStop, To: StateStopped},
+		{From: StateRunning, Event: EventStop, To: StateStopped, Consume: true},
+		{From: -5, Event: 1, To: 2},
+		{From: -5, Event: 1, To: 3},
+	}
+	err := fsm.ValidateNoConflicts(transitions)
+	if !errors.Is(err, fsm.ErrConflictingTransition) {
+		t.Fatalf("Expected ErrConflictingTransition, got %v", err)
+	}
+	// 每一处冲突都单独报告，完全相同的重复定义不算冲突
+	if got := len(err.(interface{ Unwrap() []error }).Unwrap()); got != 3 {
+		t.Errorf("Expected 3 conflicts, got %d: %v", got, err)
+	}
+	if err := fsm.ValidateNoConflicts(transitions[:2]); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+// 测试空的转移规则列表
+func TestEmptyTransitions(t *testing.T) {
+	if _, err := fsm.NewArrayTransitionTableChecked(nil); !errors.Is(err, fsm.ErrNoTransitions) {
+		t.Errorf("Expected ErrNoTransitions, got %v", err)
+	}
+	if _, err := fsm.NewTableBuilder().Build(); !errors.Is(err, fsm.ErrNoTransitions) {
+		t.Errorf("Expected ErrNoTransitions from empty builder, got %v", err)
+	}
+
+	// 不带校验的构造函数得到一个拒绝所有事件的退化表，之后可以用AddTransition填充
+	table := fsm.NewArrayTransitionTable(nil)
+	if err := table.AddTransition(0, 1, 0); !errors.Is(err, fsm.ErrOutOfRange) {
+		t.Errorf("Expected 1x1 table to reject event 1, got %v", err)
+	}
+	f := fsm.NewFSM(0, 0, table)
+	if f.Trigger(0) {
+		t.Error("Expected empty table to reject every event")
+	}
+	if err := table.AddTransition(0, 0, 0); err != nil || !f.Trigger(0) {
+		t.Errorf("Expected added transition to be accepted, got %v", err)
+	}
+}
+
+// 测试StateInInit在带校验的构造函数中返回错误
+func TestCheckedInvalidState(t *testing.T) {
+	transitions := []fsm.Transition{
+		{From: StateIdle, Event: EventStart, To: StateRunning},
+		{From: StateRunning, Event: EventStop, To: fsm.StateInInit},
+	}
+	table, err := fsm.NewArrayTransitionTableChecked(transitions)
+	if !errors.Is(err, fsm.ErrInvalidState) || table != nil {
+		t.Fatalf("Expected ErrInvalidState, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "#1") {
+		t.Errorf("Expected error to name the transition index, got %v", err)
+	}
+}
+
+// 测试超大转移表的拒绝
+func TestCheckedTableSize(t *testing.T) {
+	transitions := []fsm.Transition{
+		{From: StateIdle, Event: EventStart, To: fsm.State(2_000_000_000)},
+	}
+	if _, err := fsm.NewArrayTransitionTableChecked(transitions); !errors.Is(err, fsm.ErrTableTooLarge) {
+		t.Errorf("Expected ErrTableTooLarge, got %v", err)
+	}
+
+	// 上限调到最大也不能在int32乘法上溢出
+	defer func(limit int64) { fsm.MaxTableCells = limit }(fsm.MaxTableCells)
+	fsm.MaxTableCells = math.MaxInt64
+	overflow := []fsm.Transition{{From: fsm.State(math.MaxInt32 - 1), Event: fsm.Event(math.MaxInt32), To: StateIdle}}
+	if _, err := fsm.NewArrayTransitionTableChecked(overflow); !errors.Is(err, fsm.ErrTableTooLarge) {
+		t.Errorf("Expected ErrTableTooLarge on overflow, got %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected unchecked constructor to panic on overflow")
+			}
+		}()
+		fsm.NewArrayTransitionTable(overflow)
+	}()
+
+	negative := []fsm.Transition{{From: -1, Event: EventStart, To: StateIdle}}
+	if _, err := fsm.NewArrayTransitionTableChecked(negative); !errors.Is(err, fsm.ErrOutOfRange) {
+		t.Errorf("Expected ErrOutOfRange for negative state, got %v", err)
+	}
+	// 放不进表的规则在不带校验的构造函数中panic，而不是被静默丢弃
+	for _, trans := range []fsm.Transition{negative[0], {From: StateIdle, Event: -1, To: StateIdle}, {From: StateIdle, Event: EventStart, To: -1}} {
+		func() {
+			defer func() {
+				if msg, _ := recover().(string); !strings.Contains(msg, "does not fit") {
+					t.Errorf("Expected panic for %+v, got %q", trans, msg)
+				}
+			}()
+			fsm.NewArrayTransitionTable([]fsm.Transition{trans})
+		}()
+	}
+
+	// 调小上限后普通大小的表也会被拒绝
+	fsm.MaxTableCells = 4
+	if _, err := fsm.NewArrayTransitionTableChecked([]fsm.Transition{{From: StatePaused, Event: EventStop, To: StateStopped}}); !errors.Is(err, fsm.ErrTableTooLarge) {
+		t.Errorf("Expected ErrTableTooLarge with lowered limit, got %v", err)
+	}
+}
+
+// 测试严格模式的回调注册
+func TestRegisterCallbackChecked(t *testing.T) {
+	table := createTestTransitionTable()
+	handler := func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {}
+
+	if err := table.RegisterCallbackChecked(fsm.BeforeEvent, StateIdle, EventStart, handler); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := table.RegisterCallbackChecked(fsm.EnterState, StateRunning, 0, handler); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if table.GetCallback(fsm.EnterState, StateRunning, 0) == nil {
+		t.Error("Expected checked registration to store the handler")
+	}
+
+	// 状态回调传入非零事件
+	if err := table.RegisterCallbackChecked(fsm.EnterState, StateRunning, EventPause, handler); !errors.Is(err, fsm.ErrInvalidCallback) {
+		t.Errorf("Expected ErrInvalidCallback for state callback with event, got %v", err)
+	}
+	// 超出范围
+	if err := table.RegisterCallbackChecked(fsm.AfterEvent, StateStopped, fsm.Event(10), handler); !errors.Is(err, fsm.ErrInvalidCallback) {
+		t.Errorf("Expected ErrInvalidCallback for out of range event, got %v", err)
+	}
+	// 未知类型
+	if err := table.RegisterCallbackChecked(fsm.CallbackType(42), StateIdle, EventStart, handler); !errors.Is(err, fsm.ErrInvalidCallback) {
+		t.Errorf("Expected ErrInvalidCallback for unknown type, got %v", err)
+	}
+}
+
+// 测试无效回调注册的检测
+func TestValidateCallbacks(t *testing.T) {
+	table := createTestTransitionTable()
+	handler := func(f *fsm.FSM, from, to fsm.State, event fsm.Event, args ...any) {}
+
+	table.RegisterCallback(fsm.BeforeEvent, StateIdle, EventStart, handler)
+	table.RegisterCallback(fsm.EnterState, StateRunning, 0, handler)
+	if orphans := table.ValidateCallbacks(); len(orphans) != 0 {
+		t.Errorf("Expected no orphans, got %v", orphans)
+	}
+
+	table.RegisterCallback(fsm.BeforeEvent, StateIdle, EventStop, handler)
+	table.RegisterContextCallback(fsm.AfterEvent, StateStopped, EventResume, func(tc *fsm.TransitionContext) {})
+	want := []fsm.CallbackSlot{
+		{Type: fsm.BeforeEvent, State: StateIdle, Event: EventStop},
+		{Type: fsm.AfterEvent, State: StateStopped, Event: EventResume},
+	}
+	if got := table.ValidateCallbacks(); !slices.Equal(got, want) {
+		t.Errorf("Expected orphans %v, got %v", want, got)
+	}
+
+	// ErrHandler同样会被检查
+	table.RegisterErrCallback(fsm.BeforeEvent, StateRunning, EventResume, func(*fsm.FSM, fsm.State, fsm.State, fsm.Event, ...any) error {
+		return nil
+	})
+	got := table.ValidateCallbacks()
+	if len(got) != 3 || got[1] != (fsm.CallbackSlot{Type: fsm.BeforeEvent, State: StateRunning, Event: EventResume}) {
+		t.Fatalf("Expected the ErrHandler to be reported, got %v", got)
+	}
+	if s := got[1].String(); s != "BeforeEvent(state(1), event(2))" {
+		t.Errorf("Unexpected slot name %q", s)
+	}
+	// 报告使用注册的名称，名称注册表是全局的，使用names_test.go中的取值
+	fsm.RegisterStateNames(map[fsm.State]string{namedIdle: "Idle"})
+	fsm.RegisterEventNames(map[fsm.Event]string{namedGo: "Go"})
+	if s := (fsm.CallbackSlot{Type: fsm.AfterEvent, State: namedIdle, Event: namedGo}).String(); s != "AfterEvent(Idle, Go)" {
+		t.Errorf("Unexpected slot name %q", s)
+	}
+	if s := fsm.CallbackType(42).String(); s != "CallbackType(42)" {
+		t.Errorf("Unexpected unknown callback type name %q", s)
+	}
+}
@@ -0,0 +1,71 @@
+package fsm
+
+import (
+	"context"
+	"slices"
+)
+
+// stateWaiter 等待状态机进入target的WaitForState调用，进入时关闭done
+type stateWaiter struct {
+	target State
+	done   chan struct{}
+}
+
+// WaitForState 阻塞直到状态机进入target，或者ctx被取消
+// 已经处于target时立即返回nil；否则在之后的任意一次进入target（包括超时触发的转移、自转移、
+// Reset以及TriggerSequence失败后的回滚）完成时返回nil，即使状态随即又被改变也不会错过。
+// 转移触发的等待在该次转移的回调和实例级监听器执行完之后才被唤醒。ctx被取消或超时时返回ctx.Err()。
+// 不能在本状态机的回调中等待由本状态机后续转移进入的状态，否则会因为Event锁而永远阻塞
+func (f *FSM) WaitForState(ctx context.Context, target State) error {
+	w := &stateWaiter{target: target, done: make(chan struct{})}
+	f.waitLock.Lock()
+	f.waiters = append(f.waiters, w)
+	f.waiterCount.Add(1)
+	f.waitLock.Unlock()
+	// 先登记再读取状态，与notifyWaiters先写入状态再检查登记数相对应，两者之中至少有一方能看到对方
+	if f.CurrentState() == target {
+		f.removeWaiter(w)
+		return nil
+	}
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		if !f.removeWaiter(w) {
+			// 取消的同时已经被唤醒
+			return nil
+		}
+		return ctx.Err()
+	}
+}
+
+// removeWaiter 移除尚未被唤醒的w，返回是否找到
+func (f *FSM) removeWaiter(w *stateWaiter) bool {
+	f.waitLock.Lock()
+	defer f.waitLock.Unlock()
+	i := slices.Index(f.waiters, w)
+	if i < 0 {
+		return false
+	}
+	f.waiters = slices.Delete(f.waiters, i, i+1)
+	f.waiterCount.Add(-1)
+	return true
+}
+
+// notifyWaiters 唤醒所有等待进入state的WaitForState调用，调用方已经将状态切换为state
+// 没有等待者时只有一次原子读取
+func (f *FSM) notifyWaiters(state State) {
+	if f.waiterCount.Load() == 0 {
+		return
+	}
+	f.waitLock.Lock()
+	defer f.waitLock.Unlock()
+	f.waiters = slices.DeleteFunc(f.waiters, func(w *stateWaiter) bool {
+		if w.target != state {
+			return false
+		}
+		close(w.done)
+		f.waiterCount.Add(-1)
+		return true
+	})
+}
@@ -0,0 +1,63 @@
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	fsm "github.com/cuitpanfei/lowgcfsm"
+)
+
+// 测试等待其他goroutine驱动状态机进入目标状态
+func TestWaitForState(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	if err := f.WaitForState(context.Background(), StateIdle); err != nil {
+		t.Errorf("Expected immediate return for current state, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f.WaitForState(context.Background(), StatePaused)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// 经过StatePaused之后立即离开，等待者也不会错过
+	if _, ok := f.TriggerSequence([]fsm.Event{EventStart, EventPause, EventResume}); !ok {
+		t.Fatal("Expected sequence to succeed")
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForState did not return after passing through StatePaused")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := f.WaitForState(ctx, StateStopped); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+// 测试超时转移和Reset都会唤醒等待者
+func TestWaitForStateTimeoutAndReset(t *testing.T) {
+	f := fsm.NewFSM(0, StateIdle, createTestTransitionTable())
+	f.Trigger(EventStart)
+	f.SetTimeout(StateRunning, 10*time.Millisecond, EventStop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := f.WaitForState(ctx, StateStopped); err != nil {
+		t.Fatalf("Expected timeout transition to reach StateStopped, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		f.Reset(StateIdle)
+	}()
+	if err := f.WaitForState(ctx, StateIdle); err != nil {
+		t.Errorf("Expected Reset to wake the waiter, got %v", err)
+	}
+}